@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"internship_project/config"
+	"internship_project/logging"
+	"internship_project/metrics"
+	"internship_project/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Channel is the Postgres notification channel the access triggers notify.
+// A payload is "employee:<id>" or "company:<id of the receiving company>",
+// an empty id stands for every entry of that kind.
+const Channel = "access_changes"
+
+const (
+	employeeKind = "employee"
+	companyKind  = "company"
+)
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// AccessCache keeps employees and the read constraints of receiving
+// companies, which every product request resolves, in memory. Entries are
+// dropped when Postgres notifies a change of them. While the cache is not
+// listening for notifications every lookup goes to the database.
+type AccessCache struct {
+	Config config.AccessCacheConfig
+	DB     *pgxpool.Pool
+	Logger *logrus.Entry
+
+	mu        sync.Mutex
+	listening bool
+	// generation changes with every invalidation, a value loaded across an
+	// invalidation may be stale and is not stored.
+	generation uint64
+	entries    map[string]entry
+	now        func() time.Time
+}
+
+func NewAccessCache(conf config.AccessCacheConfig, db *pgxpool.Pool, logger *logrus.Logger) *AccessCache {
+	return &AccessCache{
+		Config:  conf,
+		DB:      db,
+		Logger:  logging.Component(logger, "accessCache"),
+		entries: map[string]entry{},
+		now:     time.Now,
+	}
+}
+
+// Employee returns the employee with the given ID, calling load on a miss.
+func (cache *AccessCache) Employee(id string, load func() (models.Employee, error)) (models.Employee, error) {
+	value, err := cache.get(employeeKind, id, func() (interface{}, error) {
+		return load()
+	})
+	if err != nil {
+		return models.Employee{}, err
+	}
+	return value.(models.Employee), nil
+}
+
+// ReadConstraints returns the read constraints of the receiving company with
+// the given ID, calling load on a miss.
+func (cache *AccessCache) ReadConstraints(companyID string, load func() ([]models.EarConstraint, error)) ([]models.EarConstraint, error) {
+	value, err := cache.get(companyKind, companyID, func() (interface{}, error) {
+		constraints, err := load()
+		return copyConstraints(constraints), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyConstraints(value.([]models.EarConstraint)), nil
+}
+
+func (cache *AccessCache) get(kind string, id string, load func() (interface{}, error)) (interface{}, error) {
+	key := kind + ":" + id
+
+	cache.mu.Lock()
+	if !cache.listening {
+		cache.mu.Unlock()
+		metrics.ObserveAccessCache(kind, "bypass")
+		return load()
+	}
+	cached, ok := cache.entries[key]
+	if ok && cache.now().Before(cached.expires) {
+		cache.mu.Unlock()
+		metrics.ObserveAccessCache(kind, "hit")
+		return cached.value, nil
+	}
+	generation := cache.generation
+	cache.mu.Unlock()
+
+	metrics.ObserveAccessCache(kind, "miss")
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.listening && cache.generation == generation {
+		if len(cache.entries) >= cache.Config.MaxEntries {
+			cache.entries = map[string]entry{}
+		}
+		cache.entries[key] = entry{
+			value:   value,
+			expires: cache.now().Add(time.Duration(cache.Config.TTL) * time.Second),
+		}
+	}
+	return value, nil
+}
+
+// Invalidate drops the entries named by a notification payload. A payload
+// the cache does not understand drops every entry.
+func (cache *AccessCache) Invalidate(payload string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.generation++
+
+	parts := strings.SplitN(payload, ":", 2)
+	if len(parts) != 2 || (parts[0] != employeeKind && parts[0] != companyKind) {
+		cache.entries = map[string]entry{}
+		return
+	}
+	if parts[1] != "" {
+		delete(cache.entries, payload)
+		return
+	}
+	for key := range cache.entries {
+		if strings.HasPrefix(key, payload) {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// Listen invalidates entries on notifications until ctx is done. It holds
+// one connection of the pool, and after losing it the cache is emptied and
+// bypassed until listening again, since notifications may have been missed.
+func (cache *AccessCache) Listen(ctx context.Context) {
+	for {
+		err := cache.listen(ctx)
+		cache.setListening(false)
+		if ctx.Err() != nil {
+			return
+		}
+		cache.Logger.WithError(err).Error("Stopped listening for access changes, retrying")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(cache.Config.RetryInterval) * time.Millisecond):
+		}
+	}
+}
+
+func (cache *AccessCache) listen(ctx context.Context) error {
+	conn, err := cache.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	// The connection is still subscribed to the channel, closing it keeps
+	// the pool from handing it out again.
+	defer conn.Conn().Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+Channel)
+	if err != nil {
+		return err
+	}
+	cache.setListening(true)
+	cache.Logger.Info("Listening for access changes")
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		cache.Logger.WithField("payload", notification.Payload).Debug("Access changed")
+		cache.Invalidate(notification.Payload)
+	}
+}
+
+func (cache *AccessCache) setListening(listening bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.listening = listening
+	cache.generation++
+	cache.entries = map[string]entry{}
+}
+
+func copyConstraints(constraints []models.EarConstraint) []models.EarConstraint {
+	if constraints == nil {
+		return nil
+	}
+	return append([]models.EarConstraint{}, constraints...)
+}
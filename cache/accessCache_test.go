@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"internship_project/config"
+	"internship_project/logging"
+	"internship_project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessCache(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2020, 11, 2, 10, 0, 0, 0, time.UTC)
+	newCache := func() *AccessCache {
+		cache := NewAccessCache(config.Default().AccessCache, nil, logging.Discard())
+		cache.listening = true
+		cache.now = func() time.Time { return now }
+		return cache
+	}
+
+	// employee looks up an employee and counts the loads.
+	loads := 0
+	employee := func(cache *AccessCache, id string) models.Employee {
+		result, err := cache.Employee(id, func() (models.Employee, error) {
+			loads++
+			return models.Employee{ID: id, CompanyID: "company-1"}, nil
+		})
+		assert.NoError(err)
+		return result
+	}
+	constraints := func(cache *AccessCache, companyID string) []models.EarConstraint {
+		result, err := cache.ReadConstraints(companyID, func() ([]models.EarConstraint, error) {
+			loads++
+			return []models.EarConstraint{{IDEAR: "ear-1", IDRC: companyID, IDSC: "company-2"}}, nil
+		})
+		assert.NoError(err)
+		return result
+	}
+
+	t.Run("entries are loaded once", func(t *testing.T) {
+		cache := newCache()
+		loads = 0
+
+		assert.Equal("employee-1", employee(cache, "employee-1").ID)
+		assert.Equal("employee-1", employee(cache, "employee-1").ID)
+		constraints(cache, "company-1")
+		constraints(cache, "company-1")
+
+		assert.Equal(2, loads)
+	})
+
+	t.Run("without notifications nothing is cached", func(t *testing.T) {
+		cache := newCache()
+		cache.setListening(false)
+		loads = 0
+
+		employee(cache, "employee-1")
+		employee(cache, "employee-1")
+
+		assert.Equal(2, loads)
+		assert.Empty(cache.entries)
+	})
+
+	t.Run("notifications drop their entries", func(t *testing.T) {
+		cache := newCache()
+		employee(cache, "employee-1")
+		employee(cache, "employee-2")
+		constraints(cache, "company-1")
+		constraints(cache, "company-2")
+
+		cache.Invalidate("employee:employee-1")
+		cache.Invalidate("company:company-2")
+
+		assert.Len(cache.entries, 2)
+		assert.Contains(cache.entries, "employee:employee-2")
+		assert.Contains(cache.entries, "company:company-1")
+	})
+
+	t.Run("an empty id drops every entry of its kind", func(t *testing.T) {
+		cache := newCache()
+		employee(cache, "employee-1")
+		constraints(cache, "company-1")
+		constraints(cache, "company-2")
+
+		cache.Invalidate("company:")
+
+		assert.Len(cache.entries, 1)
+		assert.Contains(cache.entries, "employee:employee-1")
+	})
+
+	t.Run("an unknown payload drops everything", func(t *testing.T) {
+		cache := newCache()
+		employee(cache, "employee-1")
+		constraints(cache, "company-1")
+
+		cache.Invalidate("shop:shop-1")
+
+		assert.Empty(cache.entries)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		cache := newCache()
+		loads = 0
+
+		employee(cache, "employee-1")
+		cache.now = func() time.Time { return now.Add(time.Duration(cache.Config.TTL) * time.Second) }
+		employee(cache, "employee-1")
+
+		assert.Equal(2, loads)
+	})
+
+	t.Run("a full cache starts over", func(t *testing.T) {
+		cache := newCache()
+		cache.Config.MaxEntries = 2
+
+		employee(cache, "employee-1")
+		employee(cache, "employee-2")
+		employee(cache, "employee-3")
+
+		assert.Len(cache.entries, 1)
+		assert.Contains(cache.entries, "employee:employee-3")
+	})
+
+	t.Run("a load across a notification is not stored", func(t *testing.T) {
+		cache := newCache()
+
+		_, err := cache.Employee("employee-1", func() (models.Employee, error) {
+			cache.Invalidate("employee:employee-1")
+			return models.Employee{ID: "employee-1"}, nil
+		})
+
+		assert.NoError(err)
+		assert.Empty(cache.entries)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		cache := newCache()
+
+		_, err := cache.Employee("employee-1", func() (models.Employee, error) {
+			return models.Employee{}, errors.New("There is no employee with this id")
+		})
+
+		assert.Error(err)
+		assert.Empty(cache.entries)
+	})
+
+	t.Run("callers get their own constraints", func(t *testing.T) {
+		cache := newCache()
+
+		constraints(cache, "company-1")[0].IDSC = "changed"
+
+		assert.Equal("company-2", constraints(cache, "company-1")[0].IDSC)
+	})
+}
@@ -0,0 +1,269 @@
+package cdc
+
+import (
+	"fmt"
+	"internship_project/events"
+	"internship_project/models"
+	"strconv"
+	"time"
+)
+
+// Change is the net change of a row within a transaction. Old is the row
+// before the transaction, New the row after it.
+type Change struct {
+	Table     string
+	Operation Operation
+	Old       Row
+	New       Row
+}
+
+// ID returns the primary key of the changed row.
+func (change Change) ID() string {
+	if change.New != nil {
+		return change.New["id"]
+	}
+	return change.Old["id"]
+}
+
+type changeKey struct {
+	table string
+	id    string
+}
+
+// transaction merges the changes of every row within a transaction into one,
+// e.g. the update of a product and the increment of its version, and keeps
+// the rows in the order of their first change.
+type transaction struct {
+	order   []changeKey
+	changes map[changeKey]*Change
+	// actor caused the changes, if the transaction named it.
+	actor string
+}
+
+func newTransaction() *transaction {
+	return &transaction{changes: map[changeKey]*Change{}}
+}
+
+func (tx *transaction) add(change Change) {
+	key := changeKey{table: change.Table, id: change.ID()}
+	previous, ok := tx.changes[key]
+	if !ok || previous == nil {
+		if !ok {
+			tx.order = append(tx.order, key)
+		}
+		tx.changes[key] = &change
+		return
+	}
+
+	switch {
+	case previous.Operation == Insert && change.Operation == Delete:
+		// The row never existed outside of the transaction.
+		tx.changes[key] = nil
+	case previous.Operation == Insert:
+		previous.New = change.New
+	case previous.Operation == Delete && change.Operation == Insert:
+		previous.Operation = Update
+		previous.New = change.New
+	default:
+		previous.Operation = change.Operation
+		previous.New = change.New
+		if change.Operation == Delete {
+			// The old row of the delete holds the latest version.
+			previous.Old = change.Old
+		}
+	}
+}
+
+// Changes returns the net changes in order.
+func (tx *transaction) Changes() []Change {
+	changes := []Change{}
+	for _, key := range tx.order {
+		if change := tx.changes[key]; change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes
+}
+
+// KeyedEvent is an event with the key it is published under and the company
+// it concerns.
+type KeyedEvent struct {
+	Key    string
+	Tenant string
+	Event  events.Event
+}
+
+// Events turns a change into the events the repositories publish for it.
+// Changes of other tables have none.
+func Events(change Change) ([]KeyedEvent, error) {
+	switch change.Table {
+	case "products":
+		return productEvents(change)
+	case "companies":
+		return companyEvents(change)
+	case "external_access_rights":
+		return externalRightEvents(change)
+	default:
+		return nil, nil
+	}
+}
+
+func productEvents(change Change) ([]KeyedEvent, error) {
+	if change.Operation == Delete {
+		version, err := parseInt(change.Old, "version")
+		if err != nil {
+			return nil, err
+		}
+		return []KeyedEvent{{
+			Key:    change.ID(),
+			Tenant: change.Old["idc"],
+			Event:  &events.ProductDeleted{ID: change.ID(), Version: version},
+		}}, nil
+	}
+
+	product, err := productFromRow(change.New)
+	if err != nil {
+		return nil, err
+	}
+	version, err := parseInt(change.New, "version")
+	if err != nil {
+		return nil, err
+	}
+	event := KeyedEvent{Key: product.ID, Tenant: product.IDC}
+	if change.Operation == Insert {
+		event.Event = &events.ProductCreated{Product: product, Version: version}
+	} else {
+		event.Event = &events.ProductUpdated{Product: product, Version: version}
+	}
+	return []KeyedEvent{event}, nil
+}
+
+func productFromRow(row Row) (models.Product, error) {
+	price, err := strconv.ParseFloat(row["price"], 32)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("Invalid product price %q: %w", row["price"], err)
+	}
+	quantity, err := strconv.ParseInt(row["quantity"], 10, 32)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("Invalid product quantity %q: %w", row["quantity"], err)
+	}
+	return models.Product{
+		ID:       row["id"],
+		Name:     row["name"],
+		Price:    float32(price),
+		Quantity: int32(quantity),
+		IDC:      row["idc"],
+	}, nil
+}
+
+func companyEvents(change Change) ([]KeyedEvent, error) {
+	id := change.ID()
+	switch change.Operation {
+	case Insert:
+		return []KeyedEvent{{Key: id, Tenant: id, Event: &events.CompanyCreated{Company: companyFromRow(change.New)}}}, nil
+	case Delete:
+		return []KeyedEvent{{Key: id, Tenant: id, Event: &events.CompanyDeleted{ID: id}}}, nil
+	}
+
+	keyedEvents := []KeyedEvent{}
+	if changed(change, "name", "ismain") {
+		keyedEvents = append(keyedEvents, KeyedEvent{Key: id, Tenant: id, Event: &events.CompanyUpdated{Company: companyFromRow(change.New)}})
+	}
+	if change.Old != nil && changed(change, "deleted_at") {
+		deletedAt, archived := change.New["deleted_at"]
+		if !archived {
+			keyedEvents = append(keyedEvents, KeyedEvent{Key: id, Tenant: id, Event: &events.CompanyRestored{ID: id}})
+		} else {
+			archivedAt, err := parseTimestamp(deletedAt)
+			if err != nil {
+				return nil, err
+			}
+			keyedEvents = append(keyedEvents,
+				KeyedEvent{Key: id, Tenant: id, Event: &events.CompanyArchived{ID: id, ArchivedAt: archivedAt}},
+				KeyedEvent{Key: id, Tenant: id, Event: &events.ProductsArchivedForCompany{CompanyID: id}},
+			)
+		}
+	}
+	return keyedEvents, nil
+}
+
+func companyFromRow(row Row) models.Company {
+	return models.Company{
+		ID:     row["id"],
+		Name:   row["name"],
+		IsMain: row["ismain"] == "t",
+	}
+}
+
+func externalRightEvents(change Change) ([]KeyedEvent, error) {
+	id := change.ID()
+	switch change.Operation {
+	case Insert:
+		ear := externalRightFromRow(change.New)
+		return []KeyedEvent{{Key: id, Tenant: ear.IDSC, Event: &events.ExternalRightCreated{ExternalRight: ear}}}, nil
+	case Delete:
+		return []KeyedEvent{{Key: id, Tenant: change.Old["idsc"], Event: &events.ExternalRightDeleted{ID: id}}}, nil
+	}
+
+	ear := externalRightFromRow(change.New)
+	keyedEvents := []KeyedEvent{}
+	if changed(change, "idsc", "idrc", "r", "u", "d") {
+		keyedEvents = append(keyedEvents, KeyedEvent{Key: id, Tenant: ear.IDSC, Event: &events.ExternalRightUpdated{ExternalRight: ear}})
+	}
+	if change.Old != nil && changed(change, "approved") {
+		keyedEvents = append(keyedEvents, KeyedEvent{Key: id, Tenant: ear.IDSC, Event: &events.ExternalRightApprovalChanged{ID: id, Approved: ear.Approved}})
+	}
+	return keyedEvents, nil
+}
+
+func externalRightFromRow(row Row) models.ExternalRights {
+	return models.ExternalRights{
+		ID:       row["id"],
+		Read:     row["r"] == "t",
+		Update:   row["u"] == "t",
+		Delete:   row["d"] == "t",
+		Approved: row["approved"] == "t",
+		IDSC:     row["idsc"],
+		IDRC:     row["idrc"],
+	}
+}
+
+// changed reports whether any of columns differs between the old and the new
+// row. Without the old row, i.e. without full replica identity, every column
+// counts as changed.
+func changed(change Change, columns ...string) bool {
+	if change.Old == nil {
+		return true
+	}
+	for _, column := range columns {
+		oldValue, oldOk := change.Old[column]
+		newValue, newOk := change.New[column]
+		if oldOk != newOk || oldValue != newValue {
+			return true
+		}
+	}
+	return false
+}
+
+func parseInt(row Row, column string) (int64, error) {
+	value, ok := row[column]
+	if !ok {
+		// Missing from the old row without full replica identity.
+		return 0, nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s %q: %w", column, value, err)
+	}
+	return number, nil
+}
+
+// parseTimestamp parses the text format of timestamptz in the UTC session
+// time zone of the listener.
+func parseTimestamp(value string) (time.Time, error) {
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999-07", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid timestamp %q: %w", value, err)
+	}
+	return parsed.UTC(), nil
+}
@@ -0,0 +1,145 @@
+package cdc
+
+import (
+	"internship_project/events"
+	"internship_project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func productRow(name string, version string) Row {
+	return Row{"id": "product-1", "name": name, "price": "1.5", "quantity": "10", "idc": "company-1", "version": version}
+}
+
+func TestTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("an update and its version increment are one change", func(t *testing.T) {
+		tx := newTransaction()
+		tx.add(Change{Table: "products", Operation: Update, Old: productRow("Milk", "1"), New: productRow("Bread", "1")})
+		tx.add(Change{Table: "products", Operation: Update, Old: productRow("Bread", "1"), New: productRow("Bread", "2")})
+
+		assert.Equal([]Change{
+			{Table: "products", Operation: Update, Old: productRow("Milk", "1"), New: productRow("Bread", "2")},
+		}, tx.Changes())
+	})
+
+	t.Run("a delete keeps the latest version", func(t *testing.T) {
+		tx := newTransaction()
+		tx.add(Change{Table: "products", Operation: Update, Old: productRow("Milk", "1"), New: productRow("Milk", "2")})
+		tx.add(Change{Table: "products", Operation: Delete, Old: productRow("Milk", "2")})
+
+		assert.Equal([]Change{
+			{Table: "products", Operation: Delete, Old: productRow("Milk", "2")},
+		}, tx.Changes())
+	})
+
+	t.Run("a row inserted and deleted has no change", func(t *testing.T) {
+		tx := newTransaction()
+		tx.add(Change{Table: "products", Operation: Insert, New: productRow("Milk", "1")})
+		tx.add(Change{Table: "products", Operation: Update, Old: productRow("Milk", "1"), New: productRow("Bread", "1")})
+		tx.add(Change{Table: "products", Operation: Delete, Old: productRow("Bread", "1")})
+
+		assert.Empty(tx.Changes())
+	})
+
+	t.Run("rows keep the order of their first change", func(t *testing.T) {
+		tx := newTransaction()
+		tx.add(Change{Table: "products", Operation: Delete, Old: productRow("Milk", "1")})
+		tx.add(Change{Table: "companies", Operation: Delete, Old: Row{"id": "company-1"}})
+		tx.add(Change{Table: "products", Operation: Insert, New: productRow("Bread", "1")})
+
+		changes := tx.Changes()
+
+		assert.Len(changes, 2)
+		assert.Equal("products", changes[0].Table)
+		assert.Equal(Update, changes[0].Operation)
+		assert.Equal("companies", changes[1].Table)
+	})
+}
+
+func TestEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	product := models.Product{ID: "product-1", Name: "Milk", Price: 1.5, Quantity: 10, IDC: "company-1"}
+	company := Row{"id": "company-1", "name": "Ava", "ismain": "t"}
+	ear := Row{"id": "ear-1", "idsc": "company-1", "idrc": "company-2", "r": "t", "u": "f", "d": "f", "approved": "f"}
+
+	with := func(row Row, column string, value string) Row {
+		copied := Row{}
+		for key, existing := range row {
+			copied[key] = existing
+		}
+		copied[column] = value
+		return copied
+	}
+
+	t.Run("products carry their version", func(t *testing.T) {
+		created, err := Events(Change{Table: "products", Operation: Insert, New: productRow("Milk", "1")})
+		assert.NoError(err)
+		assert.Equal([]KeyedEvent{{Key: "product-1", Tenant: "company-1", Event: &events.ProductCreated{Product: product, Version: 1}}}, created)
+
+		updated, err := Events(Change{Table: "products", Operation: Update, Old: productRow("Milk", "1"), New: productRow("Milk", "2")})
+		assert.NoError(err)
+		assert.Equal(&events.ProductUpdated{Product: product, Version: 2}, updated[0].Event)
+
+		deleted, err := Events(Change{Table: "products", Operation: Delete, Old: productRow("Milk", "3")})
+		assert.NoError(err)
+		assert.Equal(&events.ProductDeleted{ID: "product-1", Version: 3}, deleted[0].Event)
+	})
+
+	t.Run("invalid product row", func(t *testing.T) {
+		_, err := Events(Change{Table: "products", Operation: Insert, New: with(productRow("Milk", "1"), "price", "free")})
+
+		assert.Error(err)
+	})
+
+	t.Run("archiving and restoring a company", func(t *testing.T) {
+		archived, err := Events(Change{Table: "companies", Operation: Update, Old: company, New: with(company, "deleted_at", "2020-11-02 10:15:00.5+00")})
+		assert.NoError(err)
+		assert.Equal([]KeyedEvent{{
+			Key:    "company-1",
+			Tenant: "company-1",
+			Event:  &events.CompanyArchived{ID: "company-1", ArchivedAt: time.Date(2020, 11, 2, 10, 15, 0, 500000000, time.UTC)},
+		}, {
+			Key:    "company-1",
+			Tenant: "company-1",
+			Event:  &events.ProductsArchivedForCompany{CompanyID: "company-1"},
+		}}, archived)
+
+		restored, err := Events(Change{Table: "companies", Operation: Update, Old: with(company, "deleted_at", "2020-11-02 10:15:00+00"), New: company})
+		assert.NoError(err)
+		assert.Equal([]KeyedEvent{{Key: "company-1", Tenant: "company-1", Event: &events.CompanyRestored{ID: "company-1"}}}, restored)
+	})
+
+	t.Run("renaming a company", func(t *testing.T) {
+		updated, err := Events(Change{Table: "companies", Operation: Update, Old: company, New: with(company, "name", "Ava d.o.o.")})
+
+		assert.NoError(err)
+		assert.Equal(&events.CompanyUpdated{Company: models.Company{ID: "company-1", Name: "Ava d.o.o.", IsMain: true}}, updated[0].Event)
+	})
+
+	t.Run("approving an external right", func(t *testing.T) {
+		approved, err := Events(Change{Table: "external_access_rights", Operation: Update, Old: ear, New: with(ear, "approved", "t")})
+
+		assert.NoError(err)
+		assert.Equal([]KeyedEvent{{Key: "ear-1", Tenant: "company-1", Event: &events.ExternalRightApprovalChanged{ID: "ear-1", Approved: true}}}, approved)
+	})
+
+	t.Run("without the old row every update is published", func(t *testing.T) {
+		updated, err := Events(Change{Table: "external_access_rights", Operation: Update, New: with(ear, "approved", "t")})
+
+		assert.NoError(err)
+		assert.Len(updated, 1)
+		assert.Equal(events.ExternalRightUpdatedType, updated[0].Event.EventType())
+	})
+
+	t.Run("other tables", func(t *testing.T) {
+		none, err := Events(Change{Table: "employees", Operation: Delete, Old: Row{"id": "employee-1"}})
+
+		assert.NoError(err)
+		assert.Empty(none)
+	})
+}
@@ -0,0 +1,322 @@
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// eventNamespace derives the IDs of the published events from the position
+// of their transaction in the WAL, so that a transaction that is streamed
+// again after a restart gets the same event IDs and the consumer skips it.
+var eventNamespace = uuid.NewV5(uuid.NamespaceURL, "internship_project/cdc")
+
+// Listener streams the changes of the captured tables from a logical
+// replication slot and publishes the events of the configured aggregates.
+// The position of a transaction is confirmed to Postgres once all of its
+// events have been published, so every change is published at least once.
+type Listener struct {
+	Config     config.CDCConfig
+	URL        string
+	publisher  *kafka_helpers.EventPublisher
+	aggregates map[string]bool
+	Logger     *logrus.Entry
+
+	relations map[uint32]relationMessage
+	tx        *transaction
+	// confirmed is the WAL position up to which every change was published.
+	confirmed uint64
+}
+
+func NewListener(conf config.CDCConfig, url string, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) *Listener {
+	aggregates := map[string]bool{}
+	for _, aggregate := range conf.Aggregates {
+		aggregates[aggregate] = true
+	}
+	return &Listener{
+		Config:     conf,
+		URL:        url,
+		publisher:  publisher,
+		aggregates: aggregates,
+		Logger:     logging.Component(logger, "cdcListener"),
+	}
+}
+
+// Run streams changes until ctx is done. After a failure it reconnects and
+// continues at the last confirmed position.
+func (listener *Listener) Run(ctx context.Context) {
+	for {
+		err := listener.stream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		listener.Logger.WithError(err).Error("Change data capture stopped, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(listener.Config.RetryInterval) * time.Millisecond):
+		}
+	}
+}
+
+func (listener *Listener) stream(ctx context.Context) error {
+	connConfig, err := pgconn.ParseConfig(listener.URL)
+	if err != nil {
+		return err
+	}
+	connConfig.RuntimeParams["replication"] = "database"
+	connConfig.RuntimeParams["TimeZone"] = "UTC"
+
+	conn, err := pgconn.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	err = listener.ensureSlot(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	// Streaming starts at the position the slot confirmed last.
+	listener.relations = map[uint32]relationMessage{}
+	listener.tx = nil
+	options := fmt.Sprintf("proto_version '1', publication_names '%s'", listener.Config.Publication)
+	if listener.Config.ActorMessages {
+		options += ", messages 'true'"
+	}
+	err = startReplication(ctx, conn, fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL 0/0 (%s)", listener.Config.Slot, options))
+	if err != nil {
+		return err
+	}
+	listener.Logger.WithField("slot", listener.Config.Slot).Info("Streaming changes")
+
+	interval := time.Duration(listener.Config.StatusInterval) * time.Millisecond
+	nextStatus := time.Now().Add(interval)
+	for {
+		if !time.Now().Before(nextStatus) {
+			err = sendStatus(ctx, conn, listener.confirmed)
+			if err != nil {
+				return err
+			}
+			nextStatus = time.Now().Add(interval)
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		message, err := conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.CopyData:
+			replyRequested, err := listener.handle(ctx, message.Data)
+			if err != nil {
+				return err
+			}
+			if replyRequested {
+				nextStatus = time.Time{}
+			}
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(message)
+		case *pgproto3.CopyDone:
+			return errors.New("Postgres ended the replication stream")
+		}
+	}
+}
+
+// ensureSlot creates the replication slot on first start.
+func (listener *Listener) ensureSlot(ctx context.Context, conn *pgconn.PgConn) error {
+	results, err := conn.Exec(ctx, fmt.Sprintf(
+		"SELECT 1 FROM pg_replication_slots WHERE slot_name = '%s'", listener.Config.Slot,
+	)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(results) == 1 && len(results[0].Rows) == 1 {
+		return nil
+	}
+
+	_, err = conn.Exec(ctx, fmt.Sprintf(
+		"CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT", listener.Config.Slot,
+	)).ReadAll()
+	if err != nil {
+		return err
+	}
+	listener.Logger.WithField("slot", listener.Config.Slot).Info("Created replication slot")
+	return nil
+}
+
+// handle processes a message of the replication stream and reports whether
+// Postgres asked for a status update.
+func (listener *Listener) handle(ctx context.Context, data []byte) (bool, error) {
+	if len(data) == 0 {
+		return false, errShortMessage
+	}
+	decoder := &decoder{data: data[1:]}
+
+	switch data[0] {
+	case 'k':
+		// Keepalive: the end of the WAL, the server time and whether a reply
+		// is requested. Without an open transaction everything up to the end
+		// of the WAL has been published.
+		walEnd := decoder.uint64()
+		decoder.uint64()
+		replyRequested := decoder.byte() == 1
+		if decoder.err != nil {
+			return false, decoder.err
+		}
+		if listener.tx == nil && walEnd > listener.confirmed {
+			listener.confirmed = walEnd
+		}
+		return replyRequested, nil
+	case 'w':
+		// WAL data: start and end of the WAL, the server time and a pgoutput
+		// message.
+		decoder.next(24)
+		if decoder.err != nil {
+			return false, decoder.err
+		}
+		return false, listener.apply(ctx, decoder.data)
+	default:
+		return false, nil
+	}
+}
+
+func (listener *Listener) apply(ctx context.Context, data []byte) error {
+	message, err := parseMessage(data, listener.relations)
+	if err != nil {
+		return err
+	}
+
+	switch message := message.(type) {
+	case relationMessage:
+		listener.relations[message.ID] = message
+	case beginMessage:
+		listener.tx = newTransaction()
+	case changeMessage:
+		if listener.tx == nil {
+			return errors.New("Change outside of a transaction")
+		}
+		listener.tx.add(Change{
+			Table:     listener.relations[message.RelationID].Name,
+			Operation: message.Operation,
+			Old:       message.Old,
+			New:       message.New,
+		})
+	case logicalMessage:
+		// The repositories name the actor of their transactions, see
+		// events.ActorMessagePrefix.
+		if !message.Transactional || message.Prefix != events.ActorMessagePrefix || listener.tx == nil {
+			return nil
+		}
+		var actor events.ActorMessage
+		err = json.Unmarshal(message.Content, &actor)
+		if err != nil {
+			listener.Logger.WithError(err).Warn("Ignored an invalid actor message")
+			return nil
+		}
+		listener.tx.actor = actor.Actor
+	case commitMessage:
+		if listener.tx == nil {
+			return errors.New("Commit outside of a transaction")
+		}
+		err = listener.publish(ctx, listener.tx, message.CommitLSN)
+		if err != nil {
+			return err
+		}
+		listener.tx = nil
+		listener.confirmed = message.EndLSN
+	}
+	return nil
+}
+
+// publish publishes the events of a committed transaction in the order of
+// its changes.
+func (listener *Listener) publish(ctx context.Context, tx *transaction, commitLSN uint64) error {
+	// Events are numbered including the ones of other aggregates, so that
+	// their IDs do not depend on the configuration.
+	number := 0
+	published := 0
+	for _, change := range tx.Changes() {
+		keyedEvents, err := Events(change)
+		if err != nil {
+			return err
+		}
+		for _, keyedEvent := range keyedEvents {
+			number++
+			if !listener.aggregates[events.Aggregate(keyedEvent.Event.EventType())] {
+				continue
+			}
+			eventID := uuid.NewV5(eventNamespace, fmt.Sprintf("%s/%s/%d", listener.Config.Slot, formatLSN(commitLSN), number))
+			eventCtx := events.WithEventID(events.WithActor(ctx, tx.actor, keyedEvent.Tenant), eventID.String())
+			err = listener.publisher.Publish(eventCtx, keyedEvent.Key, keyedEvent.Event)
+			if err != nil {
+				return err
+			}
+			published++
+		}
+	}
+	if published > 0 {
+		listener.Logger.WithFields(logrus.Fields{
+			"lsn":    formatLSN(commitLSN),
+			"events": published,
+		}).Debug("Published changes")
+	}
+	return nil
+}
+
+// startReplication sends the START_REPLICATION command and waits until
+// Postgres switches to streaming.
+func startReplication(ctx context.Context, conn *pgconn.PgConn, command string) error {
+	err := conn.SendBytes(ctx, (&pgproto3.Query{String: command}).Encode(nil))
+	if err != nil {
+		return err
+	}
+	for {
+		message, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		switch message := message.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(message)
+		}
+	}
+}
+
+// sendStatus confirms that every change up to lsn has been handled, which
+// lets Postgres remove the WAL before it.
+func sendStatus(ctx context.Context, conn *pgconn.PgConn, lsn uint64) error {
+	data := make([]byte, 34)
+	data[0] = 'r'
+	binary.BigEndian.PutUint64(data[1:], lsn)  // written
+	binary.BigEndian.PutUint64(data[9:], lsn)  // flushed
+	binary.BigEndian.PutUint64(data[17:], lsn) // applied
+	binary.BigEndian.PutUint64(data[25:], uint64(time.Since(postgresEpoch)/time.Microsecond))
+	data[33] = 0 // no reply requested
+	return conn.SendBytes(ctx, (&pgproto3.CopyData{Data: data}).Encode(nil))
+}
+
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
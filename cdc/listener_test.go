@@ -0,0 +1,135 @@
+package cdc
+
+import (
+	"context"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerApply(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	kafkaConf := config.Default().Kafka
+
+	newListener := func(broker *kafka_helpers.MemoryBroker, aggregates ...string) *Listener {
+		conf := config.Default().CDC
+		conf.Aggregates = aggregates
+		listener := NewListener(conf, "", kafka_helpers.NewEventPublisher(kafkaConf, broker, logging.Discard()), logging.Discard())
+		listener.relations = map[uint32]relationMessage{}
+		return listener
+	}
+
+	// stream applies a transaction that creates a company with a product.
+	stream := func(listener *Listener, lsn uint64) {
+		messages := [][]byte{
+			relationData(1, "companies", "id", "name", "ismain", "deleted_at"),
+			relationData(2, "products", "id", "name", "price", "quantity", "idc", "version"),
+			beginData(lsn),
+			messageBuilder{}.byte('I').uint32(1).byte('N').tuple(text("company-1"), text("Ava"), text("t"), nil),
+			messageBuilder{}.byte('I').uint32(2).byte('N').tuple(text("product-1"), text("Milk"), text("1.5"), text("10"), text("company-1"), text("1")),
+			commitData(lsn),
+		}
+		for _, message := range messages {
+			assert.NoError(listener.apply(ctx, message))
+		}
+	}
+
+	published := func(broker *kafka_helpers.MemoryBroker, topic string) []events.Envelope {
+		offsets, err := broker.Offsets(ctx, topic)
+		assert.NoError(err)
+		reader, err := broker.PartitionReader(topic, 0, 0)
+		assert.NoError(err)
+		envelopes := []events.Envelope{}
+		for i := int64(0); i < offsets[0].LastOffset; i++ {
+			message, err := reader.ReadMessage(ctx)
+			assert.NoError(err)
+			envelope, _, err := events.Unmarshal(message.Value)
+			assert.NoError(err)
+			envelopes = append(envelopes, envelope)
+		}
+		return envelopes
+	}
+
+	t.Run("committed changes are published", func(t *testing.T) {
+		broker := kafka_helpers.NewMemoryBroker(1)
+		listener := newListener(broker, "product", "company")
+
+		stream(listener, 0x100)
+
+		companies := published(broker, kafkaConf.CompanyTopic)
+		products := published(broker, kafkaConf.MainTopic)
+		assert.Len(companies, 1)
+		assert.Len(products, 1)
+		assert.Equal(events.CompanyCreatedType, companies[0].Type)
+		assert.Equal(events.ProductCreatedType, products[0].Type)
+		assert.Equal("company-1", products[0].Tenant)
+		assert.Equal(uint64(0x101), listener.confirmed)
+	})
+
+	t.Run("only configured aggregates are published", func(t *testing.T) {
+		broker := kafka_helpers.NewMemoryBroker(1)
+		listener := newListener(broker, "product")
+
+		stream(listener, 0x100)
+
+		assert.Empty(published(broker, kafkaConf.CompanyTopic))
+		assert.Len(published(broker, kafkaConf.MainTopic), 1)
+	})
+
+	t.Run("a transaction streamed again gets the same event ids", func(t *testing.T) {
+		broker := kafka_helpers.NewMemoryBroker(1)
+
+		stream(newListener(broker, "product"), 0x100)
+		stream(newListener(broker, "product"), 0x100)
+		stream(newListener(broker, "product"), 0x200)
+
+		products := published(broker, kafkaConf.MainTopic)
+		assert.Len(products, 3)
+		assert.Equal(products[0].ID, products[1].ID)
+		assert.NotEqual(products[0].ID, products[2].ID)
+	})
+
+	t.Run("the actor of the transaction is named", func(t *testing.T) {
+		broker := kafka_helpers.NewMemoryBroker(1)
+		listener := newListener(broker, "product", "company")
+		content := `{"actor":"employee-1","tenant":"company-1"}`
+		actorData := messageBuilder{}.byte('M').byte(1).uint64(0x100).string(events.ActorMessagePrefix).uint32(uint32(len(content))).raw(content)
+
+		for _, message := range [][]byte{
+			relationData(1, "companies", "id", "name", "ismain", "deleted_at"),
+			beginData(0x100),
+			actorData,
+			messageBuilder{}.byte('I').uint32(1).byte('N').tuple(text("company-1"), text("Ava"), text("t"), nil),
+			commitData(0x100),
+		} {
+			assert.NoError(listener.apply(ctx, message))
+		}
+		stream(listener, 0x200)
+
+		companies := published(broker, kafkaConf.CompanyTopic)
+		assert.Len(companies, 2)
+		assert.Equal("employee-1", companies[0].Actor)
+		// The next transaction did not name one.
+		assert.Empty(companies[1].Actor)
+	})
+
+	t.Run("nothing is confirmed before the commit", func(t *testing.T) {
+		broker := kafka_helpers.NewMemoryBroker(1)
+		listener := newListener(broker, "product")
+		assert.NoError(listener.apply(ctx, relationData(2, "products", "id", "name", "price", "quantity", "idc", "version")))
+		assert.NoError(listener.apply(ctx, beginData(0x100)))
+
+		replyRequested, err := listener.handle(ctx, messageBuilder{}.byte('k').uint64(0x300).uint64(0).byte(1))
+
+		assert.NoError(err)
+		assert.True(replyRequested)
+		assert.Equal(uint64(0), listener.confirmed)
+		assert.Empty(published(broker, kafkaConf.MainTopic))
+	})
+}
@@ -0,0 +1,220 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The messages of the pgoutput plugin, protocol version 1, that the listener
+// needs. See https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html.
+
+// Row holds the text representation of every column of a row by name. NULL
+// columns are missing.
+type Row map[string]string
+
+// Operation is the kind of a row change.
+type Operation byte
+
+const (
+	Insert Operation = 'I'
+	Update Operation = 'U'
+	Delete Operation = 'D'
+)
+
+type beginMessage struct {
+	FinalLSN   uint64
+	CommitTime time.Time
+	XID        uint32
+}
+
+type commitMessage struct {
+	CommitLSN  uint64
+	EndLSN     uint64
+	CommitTime time.Time
+}
+
+type relationMessage struct {
+	ID        uint32
+	Namespace string
+	Name      string
+	Columns   []string
+}
+
+// changeMessage is an insert, update or delete. Old is only set for updates
+// and deletes, and only holds every column with full replica identity.
+type changeMessage struct {
+	Operation  Operation
+	RelationID uint32
+	Old        Row
+	New        Row
+}
+
+// logicalMessage is a message written with pg_logical_emit_message. It is
+// only streamed with the messages option, Postgres 14 and later.
+type logicalMessage struct {
+	Transactional bool
+	Prefix        string
+	Content       []byte
+}
+
+var errShortMessage = errors.New("pgoutput message is too short")
+
+// postgresEpoch is the origin of the timestamps of the replication protocol.
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// parseMessage decodes a pgoutput message. Messages the listener does not
+// need, e.g. origins, types and truncates, are returned as nil.
+func parseMessage(data []byte, relations map[uint32]relationMessage) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+	decoder := &decoder{data: data[1:]}
+
+	switch data[0] {
+	case 'B':
+		message := beginMessage{
+			FinalLSN:   decoder.uint64(),
+			CommitTime: decoder.time(),
+			XID:        decoder.uint32(),
+		}
+		return message, decoder.err
+	case 'C':
+		decoder.byte() // flags, unused
+		message := commitMessage{
+			CommitLSN:  decoder.uint64(),
+			EndLSN:     decoder.uint64(),
+			CommitTime: decoder.time(),
+		}
+		return message, decoder.err
+	case 'R':
+		message := relationMessage{
+			ID:        decoder.uint32(),
+			Namespace: decoder.string(),
+			Name:      decoder.string(),
+		}
+		decoder.byte() // replica identity
+		columns := int(decoder.uint16())
+		for i := 0; i < columns && decoder.err == nil; i++ {
+			decoder.byte() // flags
+			message.Columns = append(message.Columns, decoder.string())
+			decoder.uint32() // type OID
+			decoder.uint32() // type modifier
+		}
+		return message, decoder.err
+	case 'I', 'U', 'D':
+		message := changeMessage{Operation: Operation(data[0]), RelationID: decoder.uint32()}
+		relation, ok := relations[message.RelationID]
+		if decoder.err == nil && !ok {
+			return nil, fmt.Errorf("pgoutput change of unknown relation %d", message.RelationID)
+		}
+		for decoder.err == nil && len(decoder.data) > 0 {
+			switch kind := decoder.byte(); kind {
+			case 'K', 'O':
+				message.Old = decoder.row(relation, nil)
+			case 'N':
+				message.New = decoder.row(relation, message.Old)
+			default:
+				return nil, fmt.Errorf("unknown pgoutput tuple kind %q", kind)
+			}
+		}
+		return message, decoder.err
+	case 'M':
+		message := logicalMessage{Transactional: decoder.byte()&1 != 0}
+		decoder.uint64() // LSN
+		message.Prefix = decoder.string()
+		message.Content = decoder.next(int(decoder.uint32()))
+		return message, decoder.err
+	default:
+		return nil, nil
+	}
+}
+
+// decoder reads the big endian fields of a message. After the first error
+// every read returns zero values and err stays set.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (decoder *decoder) next(n int) []byte {
+	if decoder.err == nil && len(decoder.data) < n {
+		decoder.err = errShortMessage
+	}
+	if decoder.err != nil {
+		// Zeros for the fixed size fields, nothing for column values.
+		if n > 8 {
+			return nil
+		}
+		return make([]byte, n)
+	}
+	field := decoder.data[:n]
+	decoder.data = decoder.data[n:]
+	return field
+}
+
+func (decoder *decoder) byte() byte {
+	return decoder.next(1)[0]
+}
+
+func (decoder *decoder) uint16() uint16 {
+	return binary.BigEndian.Uint16(decoder.next(2))
+}
+
+func (decoder *decoder) uint32() uint32 {
+	return binary.BigEndian.Uint32(decoder.next(4))
+}
+
+func (decoder *decoder) uint64() uint64 {
+	return binary.BigEndian.Uint64(decoder.next(8))
+}
+
+// time reads microseconds since the Postgres epoch.
+func (decoder *decoder) time() time.Time {
+	micros := int64(decoder.uint64())
+	return postgresEpoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+// string reads a null terminated string.
+func (decoder *decoder) string() string {
+	if decoder.err != nil {
+		return ""
+	}
+	for i, b := range decoder.data {
+		if b == 0 {
+			value := string(decoder.data[:i])
+			decoder.data = decoder.data[i+1:]
+			return value
+		}
+	}
+	decoder.err = errShortMessage
+	return ""
+}
+
+// row reads tuple data. Unchanged TOASTed values are taken from old.
+func (decoder *decoder) row(relation relationMessage, old Row) Row {
+	row := Row{}
+	columns := int(decoder.uint16())
+	for i := 0; i < columns && decoder.err == nil; i++ {
+		name := fmt.Sprintf("column%d", i)
+		if i < len(relation.Columns) {
+			name = relation.Columns[i]
+		}
+		switch kind := decoder.byte(); kind {
+		case 'n':
+		case 'u':
+			if value, ok := old[name]; ok {
+				row[name] = value
+			}
+		case 't':
+			length := int(decoder.uint32())
+			row[name] = string(decoder.next(length))
+		default:
+			if decoder.err == nil {
+				decoder.err = fmt.Errorf("unknown pgoutput column kind %q", kind)
+			}
+		}
+	}
+	return row
+}
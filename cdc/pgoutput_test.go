@@ -0,0 +1,171 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// messageBuilder encodes pgoutput messages like Postgres does.
+type messageBuilder []byte
+
+func (builder messageBuilder) byte(b byte) messageBuilder {
+	return append(builder, b)
+}
+
+func (builder messageBuilder) uint16(n uint16) messageBuilder {
+	return append(builder, byte(n>>8), byte(n))
+}
+
+func (builder messageBuilder) uint32(n uint32) messageBuilder {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, n)
+	return append(builder, buf...)
+}
+
+func (builder messageBuilder) uint64(n uint64) messageBuilder {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, n)
+	return append(builder, buf...)
+}
+
+func (builder messageBuilder) raw(s string) messageBuilder {
+	return append(builder, s...)
+}
+
+func (builder messageBuilder) string(s string) messageBuilder {
+	return append(append(builder, s...), 0)
+}
+
+// tuple encodes values in column order, nil as NULL.
+func (builder messageBuilder) tuple(values ...*string) messageBuilder {
+	builder = builder.uint16(uint16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			builder = builder.byte('n')
+			continue
+		}
+		builder = builder.byte('t').uint32(uint32(len(*value))).raw(*value)
+	}
+	return builder
+}
+
+func text(s string) *string {
+	return &s
+}
+
+func relationData(id uint32, name string, columns ...string) []byte {
+	builder := messageBuilder{}.byte('R').uint32(id).string("public").string(name).byte('f').uint16(uint16(len(columns)))
+	for _, column := range columns {
+		builder = builder.byte(0).string(column).uint32(25).uint32(0xFFFFFFFF)
+	}
+	return builder
+}
+
+func beginData(lsn uint64) []byte {
+	return messageBuilder{}.byte('B').uint64(lsn).uint64(0).uint32(1)
+}
+
+func commitData(lsn uint64) []byte {
+	return messageBuilder{}.byte('C').byte(0).uint64(lsn).uint64(lsn + 1).uint64(0)
+}
+
+func TestParseMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	relations := map[uint32]relationMessage{}
+
+	t.Run("relation", func(t *testing.T) {
+		message, err := parseMessage(relationData(16384, "companies", "id", "name", "ismain", "deleted_at"), relations)
+
+		assert.NoError(err)
+		assert.Equal(relationMessage{
+			ID:        16384,
+			Namespace: "public",
+			Name:      "companies",
+			Columns:   []string{"id", "name", "ismain", "deleted_at"},
+		}, message)
+		relations[16384] = message.(relationMessage)
+	})
+
+	t.Run("begin and commit", func(t *testing.T) {
+		begin, err := parseMessage(messageBuilder{}.byte('B').uint64(0x16B3748).uint64(1000000).uint32(7), relations)
+		assert.NoError(err)
+		assert.Equal(beginMessage{FinalLSN: 0x16B3748, CommitTime: postgresEpoch.Add(time.Second), XID: 7}, begin)
+
+		commit, err := parseMessage(commitData(0x16B3748), relations)
+		assert.NoError(err)
+		assert.Equal(uint64(0x16B3749), commit.(commitMessage).EndLSN)
+	})
+
+	t.Run("insert with null", func(t *testing.T) {
+		data := messageBuilder{}.byte('I').uint32(16384).byte('N').tuple(text("company-1"), text("Ava"), text("t"), nil)
+
+		message, err := parseMessage(data, relations)
+
+		assert.NoError(err)
+		assert.Equal(changeMessage{
+			Operation:  Insert,
+			RelationID: 16384,
+			New:        Row{"id": "company-1", "name": "Ava", "ismain": "t"},
+		}, message)
+	})
+
+	t.Run("update with old row", func(t *testing.T) {
+		data := messageBuilder{}.byte('U').uint32(16384).
+			byte('O').tuple(text("company-1"), text("Ava"), text("t"), nil).
+			byte('N').tuple(text("company-1"), text("Ava"), text("t"), text("2020-11-02 10:15:00.5+00"))
+
+		message, err := parseMessage(data, relations)
+
+		assert.NoError(err)
+		change := message.(changeMessage)
+		assert.Equal(Update, change.Operation)
+		assert.Equal(Row{"id": "company-1", "name": "Ava", "ismain": "t"}, change.Old)
+		assert.Equal("2020-11-02 10:15:00.5+00", change.New["deleted_at"])
+	})
+
+	t.Run("unchanged values are taken from the old row", func(t *testing.T) {
+		data := messageBuilder{}.byte('U').uint32(16384).
+			byte('O').tuple(text("company-1"), text("Ava"), text("t"), nil).
+			byte('N').uint16(2).byte('t').uint32(9).raw("company-1").byte('u')
+
+		message, err := parseMessage(data, relations)
+
+		assert.NoError(err)
+		assert.Equal(Row{"id": "company-1", "name": "Ava"}, message.(changeMessage).New)
+	})
+
+	t.Run("change of an unknown relation", func(t *testing.T) {
+		_, err := parseMessage(messageBuilder{}.byte('D').uint32(1).byte('K').tuple(text("id")), relations)
+
+		assert.Error(err)
+	})
+
+	t.Run("truncated message", func(t *testing.T) {
+		data := messageBuilder{}.byte('I').uint32(16384).byte('N').tuple(text("company-1"), text("Ava"))
+
+		_, err := parseMessage(data[:len(data)-2], relations)
+
+		assert.Equal(errShortMessage, err)
+	})
+
+	t.Run("logical message", func(t *testing.T) {
+		content := `{"actor":"employee-1"}`
+		data := messageBuilder{}.byte('M').byte(1).uint64(0x100).string("ava.actor").uint32(uint32(len(content))).raw(content)
+
+		message, err := parseMessage(data, relations)
+
+		assert.NoError(err)
+		assert.Equal(logicalMessage{Transactional: true, Prefix: "ava.actor", Content: []byte(content)}, message)
+	})
+
+	t.Run("other messages are skipped", func(t *testing.T) {
+		message, err := parseMessage(messageBuilder{}.byte('O').uint64(1).string("origin"), relations)
+
+		assert.NoError(err)
+		assert.Nil(message)
+	})
+}
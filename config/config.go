@@ -0,0 +1,630 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lytics/confl"
+)
+
+// Config is the single typed configuration tree of the application.
+// Values are read from a confl file and can be overridden with the
+// environment variables named in the `env` tags.
+type Config struct {
+	Server        ServerConfig        `json:"server"`
+	Database      DatabaseConfig      `json:"database"`
+	Kafka         KafkaConfig         `json:"kafka"`
+	Elasticsearch ElasticsearchConfig `json:"elasticsearch"`
+	Nominatim     NominatimConfig     `json:"nominatim"`
+	GoogleAuth    GoogleAuthConfig    `json:"google_auth"`
+	Health        HealthConfig        `json:"health"`
+	Logging       LoggingConfig       `json:"logging"`
+	Tracing       TracingConfig       `json:"tracing"`
+	Companies     CompaniesConfig     `json:"companies"`
+	Products      ProductsConfig      `json:"products"`
+	CDC           CDCConfig           `json:"cdc"`
+	AccessCache   AccessCacheConfig   `json:"access_cache"`
+}
+
+type ServerConfig struct {
+	Address string `json:"address" env:"AVA_SERVER_ADDRESS"`
+}
+
+type DatabaseConfig struct {
+	URL     string `json:"url" env:"AVA_DATABASE_URL" secret:"url"`
+	TestURL string `json:"test_url" env:"AVA_TEST_DATABASE_URL" secret:"url"`
+}
+
+type KafkaConfig struct {
+	Brokers        []string `json:"brokers" env:"AVA_KAFKA_BROKERS"`
+	GroupID        string   `json:"group_id" env:"AVA_KAFKA_GROUP_ID"`
+	MainTopic      string   `json:"main_topic" env:"AVA_KAFKA_MAIN_TOPIC"`
+	RetryTopic     string   `json:"retry_topic" env:"AVA_KAFKA_RETRY_TOPIC"`
+	MainTopicTime  int      `json:"main_topic_time" env:"AVA_KAFKA_MAIN_TOPIC_TIME"`
+	RetryTopicTime int      `json:"retry_topic_time" env:"AVA_KAFKA_RETRY_TOPIC_TIME"`
+
+	RetryAttempts   int    `json:"retry_attempts" env:"AVA_KAFKA_RETRY_ATTEMPTS"`
+	RetryBackoff    int    `json:"retry_backoff" env:"AVA_KAFKA_RETRY_BACKOFF"`
+	DeadLetterTopic string `json:"dead_letter_topic" env:"AVA_KAFKA_DEAD_LETTER_TOPIC"`
+	// DeadLetterOperators are the IDs of the users who may inspect, replay
+	// and discard dead letters.
+	DeadLetterOperators []string `json:"dead_letter_operators" env:"AVA_KAFKA_DEAD_LETTER_OPERATORS"`
+
+	DedupWindow   int `json:"dedup_window" env:"AVA_KAFKA_DEDUP_WINDOW"`
+	BatchSize     int `json:"batch_size" env:"AVA_KAFKA_BATCH_SIZE"`
+	FlushInterval int `json:"flush_interval" env:"AVA_KAFKA_FLUSH_INTERVAL"`
+	Workers       int `json:"workers" env:"AVA_KAFKA_WORKERS"`
+
+	// RequiredAcks is all, one or none, Compression none, gzip, snappy, lz4
+	// or zstd.
+	RequiredAcks      string `json:"required_acks" env:"AVA_KAFKA_REQUIRED_ACKS"`
+	Compression       string `json:"compression" env:"AVA_KAFKA_COMPRESSION"`
+	WriteBatchSize    int    `json:"write_batch_size" env:"AVA_KAFKA_WRITE_BATCH_SIZE"`
+	WriteBatchTimeout int    `json:"write_batch_timeout" env:"AVA_KAFKA_WRITE_BATCH_TIMEOUT"`
+	WriteAttempts     int    `json:"write_attempts" env:"AVA_KAFKA_WRITE_ATTEMPTS"`
+
+	TLS  KafkaTLSConfig  `json:"tls"`
+	SASL KafkaSASLConfig `json:"sasl"`
+
+	CompanyTopic       string `json:"company_topic" env:"AVA_KAFKA_COMPANY_TOPIC"`
+	EmployeeTopic      string `json:"employee_topic" env:"AVA_KAFKA_EMPLOYEE_TOPIC"`
+	ShopTopic          string `json:"shop_topic" env:"AVA_KAFKA_SHOP_TOPIC"`
+	ExternalRightTopic string `json:"external_right_topic" env:"AVA_KAFKA_EXTERNAL_RIGHT_TOPIC"`
+	ConstraintTopic    string `json:"constraint_topic" env:"AVA_KAFKA_CONSTRAINT_TOPIC"`
+}
+
+type KafkaTLSConfig struct {
+	Enabled bool `json:"enabled" env:"AVA_KAFKA_TLS_ENABLED"`
+	// CAFile replaces the system certificate pool, CertFile and KeyFile
+	// are the client certificate for mutual TLS.
+	CAFile             string `json:"ca_file" env:"AVA_KAFKA_TLS_CA_FILE"`
+	CertFile           string `json:"cert_file" env:"AVA_KAFKA_TLS_CERT_FILE"`
+	KeyFile            string `json:"key_file" env:"AVA_KAFKA_TLS_KEY_FILE"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" env:"AVA_KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+}
+
+type KafkaSASLConfig struct {
+	// Mechanism is empty, plain, scram-sha-256 or scram-sha-512.
+	Mechanism string `json:"mechanism" env:"AVA_KAFKA_SASL_MECHANISM"`
+	Username  string `json:"username" env:"AVA_KAFKA_SASL_USERNAME"`
+	Password  string `json:"password" env:"AVA_KAFKA_SASL_PASSWORD" secret:"true"`
+}
+
+type ElasticsearchConfig struct {
+	Address string `json:"address" env:"AVA_ES_ADDRESS"`
+}
+
+type NominatimConfig struct {
+	Key string `json:"key" env:"AVA_NOMINATIM_KEY" secret:"true"`
+}
+
+type GoogleAuthConfig struct {
+	ClientID     string `json:"client_id" env:"AVA_GOOGLE_CLIENT_ID"`
+	ClientSecret string `json:"client_secret" env:"AVA_GOOGLE_CLIENT_SECRET" secret:"true"`
+}
+
+type HealthConfig struct {
+	CheckTimeout   int `json:"check_timeout" env:"AVA_HEALTH_CHECK_TIMEOUT"`
+	MaxConsumerLag int `json:"max_consumer_lag" env:"AVA_HEALTH_MAX_CONSUMER_LAG"`
+}
+
+type LoggingConfig struct {
+	Level  string `json:"level" env:"AVA_LOG_LEVEL"`
+	Format string `json:"format" env:"AVA_LOG_FORMAT"`
+}
+
+type TracingConfig struct {
+	Exporter      string `json:"exporter" env:"AVA_TRACING_EXPORTER"`
+	Endpoint      string `json:"endpoint" env:"AVA_TRACING_ENDPOINT"`
+	TLS           bool   `json:"tls" env:"AVA_TRACING_TLS"`
+	ServiceName   string `json:"service_name" env:"AVA_TRACING_SERVICE_NAME"`
+	SamplePercent int    `json:"sample_percent" env:"AVA_TRACING_SAMPLE_PERCENT"`
+}
+
+type CompaniesConfig struct {
+	DeleteMode         string `json:"delete_mode" env:"AVA_COMPANIES_DELETE_MODE"`
+	RestoreGracePeriod int    `json:"restore_grace_period" env:"AVA_COMPANIES_RESTORE_GRACE_PERIOD"`
+	PurgeInterval      int    `json:"purge_interval" env:"AVA_COMPANIES_PURGE_INTERVAL"`
+}
+
+// ProductsConfig limits bulk product imports. Every row of an import is kept
+// in memory until all of them are validated.
+type ProductsConfig struct {
+	ImportBatchSize int `json:"import_batch_size" env:"AVA_PRODUCTS_IMPORT_BATCH_SIZE"`
+	ImportMaxRows   int `json:"import_max_rows" env:"AVA_PRODUCTS_IMPORT_MAX_ROWS"`
+}
+
+// maxImportBatchSize keeps the parameters of a batch insert below the
+// Postgres limit of 65535.
+const maxImportBatchSize = 10000
+
+// CDCConfig configures change data capture. The listener reads the changes
+// of the captured tables from a logical replication slot and publishes them
+// as the events of Aggregates, which the repositories stop publishing.
+type CDCConfig struct {
+	Enabled bool `json:"enabled" env:"AVA_CDC_ENABLED"`
+	// URL connects as a role with the REPLICATION attribute. database.url is
+	// used when it is empty.
+	URL            string   `json:"url" env:"AVA_CDC_URL" secret:"url"`
+	Slot           string   `json:"slot" env:"AVA_CDC_SLOT"`
+	Publication    string   `json:"publication" env:"AVA_CDC_PUBLICATION"`
+	Aggregates     []string `json:"aggregates" env:"AVA_CDC_AGGREGATES"`
+	StatusInterval int      `json:"status_interval" env:"AVA_CDC_STATUS_INTERVAL"`
+	RetryInterval  int      `json:"retry_interval" env:"AVA_CDC_RETRY_INTERVAL"`
+	// ActorMessages makes the repositories write the actor of each
+	// transaction into the WAL and the listener stream it, which needs
+	// Postgres 14 or later.
+	ActorMessages bool `json:"actor_messages" env:"AVA_CDC_ACTOR_MESSAGES"`
+}
+
+// AccessCacheConfig limits the in-process cache of employees and resolved
+// access constraints. Entries are invalidated by Postgres notifications, TTL
+// only bounds how long an entry lives if a notification is lost.
+type AccessCacheConfig struct {
+	TTL           int `json:"ttl" env:"AVA_ACCESS_CACHE_TTL"`
+	MaxEntries    int `json:"max_entries" env:"AVA_ACCESS_CACHE_MAX_ENTRIES"`
+	RetryInterval int `json:"retry_interval" env:"AVA_ACCESS_CACHE_RETRY_INTERVAL"`
+}
+
+const redacted = "REDACTED"
+
+// Default returns the configuration used for every value that is not set
+// in the config file or in the environment.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address: ":8000",
+		},
+		Kafka: KafkaConfig{
+			Brokers:        []string{"localhost:9092"},
+			GroupID:        "group_id",
+			MainTopic:      "ava-internship",
+			RetryTopic:     "retry",
+			MainTopicTime:  500,
+			RetryTopicTime: 15000,
+
+			RetryAttempts:       3,
+			RetryBackoff:        5000,
+			DeadLetterTopic:     "dead-letter",
+			DeadLetterOperators: []string{},
+
+			DedupWindow:   10000,
+			BatchSize:     100,
+			FlushInterval: 1000,
+			Workers:       4,
+
+			RequiredAcks:      "all",
+			Compression:       "snappy",
+			WriteBatchSize:    100,
+			WriteBatchTimeout: 10,
+			WriteAttempts:     10,
+
+			CompanyTopic:       "companies",
+			EmployeeTopic:      "employees",
+			ShopTopic:          "shops",
+			ExternalRightTopic: "external-rights",
+			ConstraintTopic:    "constraints",
+		},
+		Elasticsearch: ElasticsearchConfig{
+			Address: "http://localhost:9200",
+		},
+		Health: HealthConfig{
+			CheckTimeout:   2000,
+			MaxConsumerLag: 1000,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:      "none",
+			Endpoint:      "localhost:4317",
+			ServiceName:   "internship_project",
+			SamplePercent: 100,
+		},
+		Companies: CompaniesConfig{
+			DeleteMode:         "hard",
+			RestoreGracePeriod: 720,
+			PurgeInterval:      60,
+		},
+		Products: ProductsConfig{
+			ImportBatchSize: 500,
+			ImportMaxRows:   10000,
+		},
+		CDC: CDCConfig{
+			Slot:           "ava_internship",
+			Publication:    "ava_internship",
+			Aggregates:     []string{"product", "company", "external_right"},
+			StatusInterval: 10000,
+			RetryInterval:  5000,
+		},
+		AccessCache: AccessCacheConfig{
+			TTL:           300,
+			MaxEntries:    10000,
+			RetryInterval: 5000,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the file at path (if it
+// exists) and the environment, in that order of precedence.
+func Load(path string) (Config, error) {
+	conf := Config{}
+	var file confl.MetaData
+
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			if file, err = confl.DecodeFile(path, &conf); err != nil {
+				return conf, fmt.Errorf("unable to decode config file %s: %w", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return conf, err
+		}
+	}
+
+	applyDefaults(reflect.ValueOf(&conf).Elem(), reflect.ValueOf(Default()), &file, nil)
+
+	if err := applyEnv(reflect.ValueOf(&conf).Elem()); err != nil {
+		return conf, err
+	}
+
+	return conf, nil
+}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validate checks the values needed to start the application.
+func (conf Config) Validate() error {
+	var problems ValidationError
+
+	if conf.Server.Address == "" {
+		problems = append(problems, "server.address (AVA_SERVER_ADDRESS) is required")
+	}
+
+	if conf.Database.URL == "" {
+		problems = append(problems, "database.url (AVA_DATABASE_URL) is required")
+	} else if _, err := pgxpool.ParseConfig(conf.Database.URL); err != nil {
+		problems = append(problems, "database.url (AVA_DATABASE_URL) is not a valid connection string")
+	}
+
+	if len(conf.Kafka.Brokers) == 0 {
+		problems = append(problems, "kafka.brokers (AVA_KAFKA_BROKERS) needs at least one broker")
+	}
+	for _, broker := range conf.Kafka.Brokers {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			problems = append(problems, fmt.Sprintf("kafka.brokers (AVA_KAFKA_BROKERS) has an invalid address %q, expected host:port", broker))
+		}
+	}
+	if conf.Kafka.GroupID == "" {
+		problems = append(problems, "kafka.group_id (AVA_KAFKA_GROUP_ID) is required")
+	}
+	if conf.Kafka.MainTopic == "" {
+		problems = append(problems, "kafka.main_topic (AVA_KAFKA_MAIN_TOPIC) is required")
+	}
+	if conf.Kafka.RetryTopic == "" {
+		problems = append(problems, "kafka.retry_topic (AVA_KAFKA_RETRY_TOPIC) is required")
+	}
+	if conf.Kafka.DeadLetterTopic == "" {
+		problems = append(problems, "kafka.dead_letter_topic (AVA_KAFKA_DEAD_LETTER_TOPIC) is required")
+	}
+	topics := map[string]string{}
+	for _, topic := range []struct{ name, value string }{
+		{"kafka.main_topic", conf.Kafka.MainTopic},
+		{"kafka.retry_topic", conf.Kafka.RetryTopic},
+		{"kafka.dead_letter_topic", conf.Kafka.DeadLetterTopic},
+		{"kafka.company_topic", conf.Kafka.CompanyTopic},
+		{"kafka.employee_topic", conf.Kafka.EmployeeTopic},
+		{"kafka.shop_topic", conf.Kafka.ShopTopic},
+		{"kafka.external_right_topic", conf.Kafka.ExternalRightTopic},
+		{"kafka.constraint_topic", conf.Kafka.ConstraintTopic},
+	} {
+		if topic.value == "" {
+			continue
+		}
+		if other, ok := topics[topic.value]; ok {
+			problems = append(problems, fmt.Sprintf("%s and %s must be different topics", other, topic.name))
+		}
+		topics[topic.value] = topic.name
+	}
+	if conf.Kafka.MainTopicTime <= 0 {
+		problems = append(problems, "kafka.main_topic_time (AVA_KAFKA_MAIN_TOPIC_TIME) must be a positive number of milliseconds")
+	}
+	if conf.Kafka.RetryTopicTime <= 0 {
+		problems = append(problems, "kafka.retry_topic_time (AVA_KAFKA_RETRY_TOPIC_TIME) must be a positive number of milliseconds")
+	}
+	if conf.Kafka.RetryAttempts < 0 {
+		problems = append(problems, "kafka.retry_attempts (AVA_KAFKA_RETRY_ATTEMPTS) cannot be negative")
+	}
+	if conf.Kafka.RetryBackoff <= 0 {
+		problems = append(problems, "kafka.retry_backoff (AVA_KAFKA_RETRY_BACKOFF) must be a positive number of milliseconds")
+	}
+	if conf.Kafka.DedupWindow <= 0 {
+		problems = append(problems, "kafka.dedup_window (AVA_KAFKA_DEDUP_WINDOW) must be a positive number of events")
+	}
+	if conf.Kafka.BatchSize <= 0 {
+		problems = append(problems, "kafka.batch_size (AVA_KAFKA_BATCH_SIZE) must be a positive number of messages")
+	}
+	if conf.Kafka.FlushInterval <= 0 {
+		problems = append(problems, "kafka.flush_interval (AVA_KAFKA_FLUSH_INTERVAL) must be a positive number of milliseconds")
+	}
+	if conf.Kafka.Workers <= 0 {
+		problems = append(problems, "kafka.workers (AVA_KAFKA_WORKERS) must be a positive number of goroutines")
+	}
+	switch conf.Kafka.RequiredAcks {
+	case "all", "one", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("kafka.required_acks (AVA_KAFKA_REQUIRED_ACKS) must be all, one or none, got %q", conf.Kafka.RequiredAcks))
+	}
+	switch conf.Kafka.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		problems = append(problems, fmt.Sprintf("kafka.compression (AVA_KAFKA_COMPRESSION) must be none, gzip, snappy, lz4 or zstd, got %q", conf.Kafka.Compression))
+	}
+	if conf.Kafka.WriteBatchSize <= 0 {
+		problems = append(problems, "kafka.write_batch_size (AVA_KAFKA_WRITE_BATCH_SIZE) must be a positive number of messages")
+	}
+	if conf.Kafka.WriteBatchTimeout <= 0 {
+		problems = append(problems, "kafka.write_batch_timeout (AVA_KAFKA_WRITE_BATCH_TIMEOUT) must be a positive number of milliseconds")
+	}
+	if conf.Kafka.WriteAttempts <= 0 {
+		problems = append(problems, "kafka.write_attempts (AVA_KAFKA_WRITE_ATTEMPTS) must be a positive number")
+	}
+	if (conf.Kafka.TLS.CertFile == "") != (conf.Kafka.TLS.KeyFile == "") {
+		problems = append(problems, "kafka.tls.cert_file (AVA_KAFKA_TLS_CERT_FILE) and kafka.tls.key_file (AVA_KAFKA_TLS_KEY_FILE) must be set together")
+	}
+	switch conf.Kafka.SASL.Mechanism {
+	case "":
+	case "plain", "scram-sha-256", "scram-sha-512":
+		if conf.Kafka.SASL.Username == "" || conf.Kafka.SASL.Password == "" {
+			problems = append(problems, "kafka.sasl.username (AVA_KAFKA_SASL_USERNAME) and kafka.sasl.password (AVA_KAFKA_SASL_PASSWORD) are required with kafka.sasl.mechanism")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("kafka.sasl.mechanism (AVA_KAFKA_SASL_MECHANISM) must be plain, scram-sha-256 or scram-sha-512, got %q", conf.Kafka.SASL.Mechanism))
+	}
+
+	if esURL, err := url.Parse(conf.Elasticsearch.Address); err != nil || (esURL.Scheme != "http" && esURL.Scheme != "https") || esURL.Host == "" {
+		problems = append(problems, fmt.Sprintf("elasticsearch.address (AVA_ES_ADDRESS) must be an http(s) URL, got %q", conf.Elasticsearch.Address))
+	}
+
+	if conf.Nominatim.Key == "" {
+		problems = append(problems, "nominatim.key (AVA_NOMINATIM_KEY) is required")
+	}
+
+	if conf.GoogleAuth.ClientID == "" {
+		problems = append(problems, "google_auth.client_id (AVA_GOOGLE_CLIENT_ID) is required")
+	}
+
+	if conf.Health.CheckTimeout <= 0 {
+		problems = append(problems, "health.check_timeout (AVA_HEALTH_CHECK_TIMEOUT) must be a positive number of milliseconds")
+	}
+	if conf.Health.MaxConsumerLag < 0 {
+		problems = append(problems, "health.max_consumer_lag (AVA_HEALTH_MAX_CONSUMER_LAG) cannot be negative")
+	}
+
+	switch conf.Logging.Level {
+	case "panic", "fatal", "error", "warn", "warning", "info", "debug", "trace":
+	default:
+		problems = append(problems, fmt.Sprintf("logging.level (AVA_LOG_LEVEL) must be one of error, warn, info, debug or trace, got %q", conf.Logging.Level))
+	}
+	if conf.Logging.Format != "json" && conf.Logging.Format != "text" {
+		problems = append(problems, fmt.Sprintf("logging.format (AVA_LOG_FORMAT) must be json or text, got %q", conf.Logging.Format))
+	}
+
+	switch conf.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if _, _, err := net.SplitHostPort(conf.Tracing.Endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("tracing.endpoint (AVA_TRACING_ENDPOINT) has an invalid address %q, expected host:port", conf.Tracing.Endpoint))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter (AVA_TRACING_EXPORTER) must be none, stdout or otlp, got %q", conf.Tracing.Exporter))
+	}
+	if conf.Tracing.SamplePercent < 0 || conf.Tracing.SamplePercent > 100 {
+		problems = append(problems, "tracing.sample_percent (AVA_TRACING_SAMPLE_PERCENT) must be between 0 and 100")
+	}
+
+	if conf.Companies.DeleteMode != "hard" && conf.Companies.DeleteMode != "soft" {
+		problems = append(problems, fmt.Sprintf("companies.delete_mode (AVA_COMPANIES_DELETE_MODE) must be hard or soft, got %q", conf.Companies.DeleteMode))
+	}
+	if conf.Companies.RestoreGracePeriod <= 0 {
+		problems = append(problems, "companies.restore_grace_period (AVA_COMPANIES_RESTORE_GRACE_PERIOD) must be a positive number of hours")
+	}
+	if conf.Companies.PurgeInterval <= 0 {
+		problems = append(problems, "companies.purge_interval (AVA_COMPANIES_PURGE_INTERVAL) must be a positive number of minutes")
+	}
+
+	if conf.CDC.Enabled {
+		if conf.CDC.URL != "" {
+			if _, err := pgxpool.ParseConfig(conf.CDC.URL); err != nil {
+				problems = append(problems, "cdc.url (AVA_CDC_URL) is not a valid connection string")
+			}
+		}
+		if !identifierPattern.MatchString(conf.CDC.Slot) {
+			problems = append(problems, fmt.Sprintf("cdc.slot (AVA_CDC_SLOT) must consist of lower case letters, digits and underscores, got %q", conf.CDC.Slot))
+		}
+		if !identifierPattern.MatchString(conf.CDC.Publication) {
+			problems = append(problems, fmt.Sprintf("cdc.publication (AVA_CDC_PUBLICATION) must consist of lower case letters, digits and underscores, got %q", conf.CDC.Publication))
+		}
+		for _, aggregate := range conf.CDC.Aggregates {
+			if aggregate != "product" && aggregate != "company" && aggregate != "external_right" {
+				problems = append(problems, fmt.Sprintf("cdc.aggregates (AVA_CDC_AGGREGATES) may only contain product, company and external_right, got %q", aggregate))
+			}
+		}
+		if conf.CDC.StatusInterval <= 0 {
+			problems = append(problems, "cdc.status_interval (AVA_CDC_STATUS_INTERVAL) must be a positive number of milliseconds")
+		}
+		if conf.CDC.RetryInterval <= 0 {
+			problems = append(problems, "cdc.retry_interval (AVA_CDC_RETRY_INTERVAL) must be a positive number of milliseconds")
+		}
+	}
+
+	if conf.Products.ImportBatchSize <= 0 || conf.Products.ImportBatchSize > maxImportBatchSize {
+		problems = append(problems, fmt.Sprintf("products.import_batch_size (AVA_PRODUCTS_IMPORT_BATCH_SIZE) must be between 1 and %d", maxImportBatchSize))
+	}
+	if conf.Products.ImportMaxRows <= 0 {
+		problems = append(problems, "products.import_max_rows (AVA_PRODUCTS_IMPORT_MAX_ROWS) must be a positive number")
+	}
+
+	if conf.AccessCache.TTL <= 0 {
+		problems = append(problems, "access_cache.ttl (AVA_ACCESS_CACHE_TTL) must be a positive number of seconds")
+	}
+	if conf.AccessCache.MaxEntries <= 0 {
+		problems = append(problems, "access_cache.max_entries (AVA_ACCESS_CACHE_MAX_ENTRIES) must be a positive number")
+	}
+	if conf.AccessCache.RetryInterval <= 0 {
+		problems = append(problems, "access_cache.retry_interval (AVA_ACCESS_CACHE_RETRY_INTERVAL) must be a positive number of milliseconds")
+	}
+
+	if len(problems) != 0 {
+		return problems
+	}
+	return nil
+}
+
+// identifierPattern matches the names of replication slots and publications
+// that need no quoting.
+var identifierPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// ReplicationURL returns cdc.url, or database.url when it is not set.
+func (conf Config) ReplicationURL() string {
+	if conf.CDC.URL != "" {
+		return conf.CDC.URL
+	}
+	return conf.Database.URL
+}
+
+// Redacted returns a copy of the configuration with every secret value
+// hidden, so it can be printed or logged.
+func (conf Config) Redacted() Config {
+	copied := conf
+	copied.Kafka.Brokers = append([]string(nil), conf.Kafka.Brokers...)
+	redact(reflect.ValueOf(&copied).Elem())
+	return copied
+}
+
+// Print writes the redacted configuration as indented JSON.
+func (conf Config) Print(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(conf.Redacted())
+}
+
+// applyDefaults sets every field whose key the config file does not set to
+// its default value. Keys are the json names of the fields below path, so a
+// zero written in the file is kept.
+func applyDefaults(v reflect.Value, defaults reflect.Value, file *confl.MetaData, path []string) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		key := append(path[:len(path):len(path)], strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			applyDefaults(field, defaults.Field(i), file, key)
+			continue
+		}
+		if !file.IsDefined(key...) {
+			field.Set(defaults.Field(i))
+		}
+	}
+}
+
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("environment variable %s must be an integer, got %q", name, value)
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("environment variable %s must be a boolean, got %q", name, value)
+			}
+			field.SetBool(b)
+		case reflect.Slice:
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("environment variable %s targets an unsupported field type %s", name, field.Kind())
+		}
+	}
+	return nil
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+
+		switch t.Field(i).Tag.Get("secret") {
+		case "true":
+			field.SetString(redacted)
+		case "url":
+			field.SetString(redactURL(field.String()))
+		}
+	}
+}
+
+// redactURL hides the password of a connection URL. Connection strings that
+// are not URLs (key=value DSNs) are hidden completely.
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" {
+		return redacted
+	}
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+	}
+	if query := parsed.Query(); query.Get("password") != "" {
+		query.Set("password", redacted)
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.String()
+}
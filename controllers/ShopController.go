@@ -8,10 +8,12 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type ShopController struct {
 	Service services.ShopService
+	Logger  *logrus.Entry
 }
 
 func (controller *ShopController) GetAllShops(w http.ResponseWriter, r *http.Request) {
@@ -21,12 +23,13 @@ func (controller *ShopController) GetAllShops(w http.ResponseWriter, r *http.Req
 	var err error
 
 	if address != "" {
-		shops, err = controller.Service.SearchShopsByAddress(address)
+		shops, err = controller.Service.SearchShopsByAddress(r.Context(), address)
 	} else {
-		shops, err = controller.Service.GetAllShops()
+		shops, err = controller.Service.GetAllShops(r.Context())
 	}
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all shops")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -37,8 +40,9 @@ func (controller *ShopController) GetAllShops(w http.ResponseWriter, r *http.Req
 func (controller *ShopController) GetShopById(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	shop, err := controller.Service.GetShop(idParam)
+	shop, err := controller.Service.GetShop(r.Context(), idParam)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get shop")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -49,8 +53,9 @@ func (controller *ShopController) GetShopById(w http.ResponseWriter, r *http.Req
 func (controller *ShopController) AddShop(w http.ResponseWriter, r *http.Request) {
 	var newShop models.Shop
 	json.NewDecoder(r.Body).Decode(&newShop)
-	err := controller.Service.AddNewShop(&newShop)
+	err := controller.Service.AddNewShop(r.Context(), &newShop)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add shop")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -62,9 +67,10 @@ func (controller *ShopController) UpdateShop(w http.ResponseWriter, r *http.Requ
 	var updateShop models.Shop
 	json.NewDecoder(r.Body).Decode(&updateShop)
 
-	err := controller.Service.UpdateShop(updateShop)
+	err := controller.Service.UpdateShop(r.Context(), updateShop)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update shop")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -75,9 +81,10 @@ func (controller *ShopController) UpdateShop(w http.ResponseWriter, r *http.Requ
 func (controller *ShopController) DeleteShop(w http.ResponseWriter, r *http.Request) {
 	var idParam string = mux.Vars(r)["id"]
 
-	err := controller.Service.DeleteShop(idParam)
+	err := controller.Service.DeleteShop(r.Context(), idParam)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete shop")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -87,8 +94,9 @@ func (controller *ShopController) DeleteShop(w http.ResponseWriter, r *http.Requ
 func (controller *ShopController) GetAddress(w http.ResponseWriter, r *http.Request) {
 	var shopId string = mux.Vars(r)["id"]
 
-	address, err := controller.Service.GetAddress(shopId)
+	address, err := controller.Service.GetAddress(r.Context(), shopId)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get address")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -8,15 +8,18 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type CompanyController struct {
 	Service services.CompanyService
+	Logger  *logrus.Entry
 }
 
 func (controller *CompanyController) GetAllCompanies(w http.ResponseWriter, r *http.Request) {
-	companies, err := controller.Service.GetAllCompanies()
+	companies, err := controller.Service.GetAllCompanies(r.Context())
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all companies")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -27,8 +30,9 @@ func (controller *CompanyController) GetAllCompanies(w http.ResponseWriter, r *h
 func (controller *CompanyController) GetCompanyById(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	company, err := controller.Service.GetCompany(idParam)
+	company, err := controller.Service.GetCompany(r.Context(), idParam)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get company")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -39,8 +43,9 @@ func (controller *CompanyController) GetCompanyById(w http.ResponseWriter, r *ht
 func (controller *CompanyController) AddCompany(w http.ResponseWriter, r *http.Request) {
 	var newCompany models.Company
 	json.NewDecoder(r.Body).Decode(&newCompany)
-	err := controller.Service.AddNewCompany(&newCompany)
+	err := controller.Service.AddNewCompany(r.Context(), &newCompany)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add company")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -52,9 +57,10 @@ func (controller *CompanyController) UpdateCompany(w http.ResponseWriter, r *htt
 	var updateCompany models.Company
 	json.NewDecoder(r.Body).Decode(&updateCompany)
 
-	err := controller.Service.UpdateCompany(updateCompany)
+	err := controller.Service.UpdateCompany(r.Context(), updateCompany)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update company")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -65,22 +71,38 @@ func (controller *CompanyController) UpdateCompany(w http.ResponseWriter, r *htt
 func (controller *CompanyController) DeleteCompany(w http.ResponseWriter, r *http.Request) {
 	var idParam string = mux.Vars(r)["id"]
 
-	err := controller.Service.DeleteCompany(idParam)
+	err := controller.Service.DeleteCompany(r.Context(), idParam)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete company")
 		utils.WriteErrToClient(w, err)
 		return
 	}
 	w.WriteHeader(204)
 }
 
+func (controller *CompanyController) RestoreCompany(w http.ResponseWriter, r *http.Request) {
+	var idParam string = mux.Vars(r)["id"]
+
+	company, err := controller.Service.RestoreCompany(r.Context(), idParam)
+
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to restore company")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(company)
+}
+
 func (controller *CompanyController) ChangeExternalRightApproveStatus(w http.ResponseWriter, r *http.Request, status bool) {
 	var idear string = mux.Vars(r)["idear"]
 	companyID := r.Header.Get("companyID")
 
-	err := controller.Service.ChangeExternalRightApproveStatus(companyID, idear, status)
+	err := controller.Service.ChangeExternalRightApproveStatus(r.Context(), companyID, idear, status)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to change external right approve status")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -8,15 +8,18 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type ConstraintController struct {
 	Service services.ConstraintService
+	Logger  *logrus.Entry
 }
 
 func (controller *ConstraintController) GetAllConstraints(w http.ResponseWriter, r *http.Request) {
-	constraints, err := controller.Service.GetAllConstraints()
+	constraints, err := controller.Service.GetAllConstraints(r.Context())
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all constraints")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -27,8 +30,9 @@ func (controller *ConstraintController) GetAllConstraints(w http.ResponseWriter,
 func (controller *ConstraintController) GetConstraintById(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	constraint, err := controller.Service.GetConstraint(idParam)
+	constraint, err := controller.Service.GetConstraint(r.Context(), idParam)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get constraint")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -39,8 +43,9 @@ func (controller *ConstraintController) GetConstraintById(w http.ResponseWriter,
 func (controller *ConstraintController) AddConstraint(w http.ResponseWriter, r *http.Request) {
 	var newConstraint models.AccessConstraint
 	json.NewDecoder(r.Body).Decode(&newConstraint)
-	err := controller.Service.AddNewConstraint(&newConstraint)
+	err := controller.Service.AddNewConstraint(r.Context(), &newConstraint)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add constraint")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -52,9 +57,10 @@ func (controller *ConstraintController) UpdateConstraint(w http.ResponseWriter,
 	var updateConstraint models.AccessConstraint
 	json.NewDecoder(r.Body).Decode(&updateConstraint)
 
-	err := controller.Service.UpdateConstraint(updateConstraint)
+	err := controller.Service.UpdateConstraint(r.Context(), updateConstraint)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update constraint")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -66,9 +72,10 @@ func (controller *ConstraintController) UpdateConstraint(w http.ResponseWriter,
 func (controller *ConstraintController) DeleteConstraint(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	err := controller.Service.DeleteConstraint(idParam)
+	err := controller.Service.DeleteConstraint(r.Context(), idParam)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete constraint")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -3,8 +3,10 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"github.com/segmentio/kafka-go"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
 	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/repositories"
 	"internship_project/services"
 	"internship_project/utils"
@@ -12,7 +14,7 @@ import (
 	"testing"
 
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/lytics/confl"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -25,38 +27,35 @@ var (
 	ExternalRightCont ExternalRightController
 )
 
-type Config struct {
-	Username        string `json:"username"`
-	Password        string `json:"password"`
-	DatabaseURL     string `json:"database_url"`
-	TestDatabaseURL string `json:"test_database_url"`
-}
-
 func TestMain(m *testing.M) {
-	connpool = GetTestConnectionPool()
+	conf, err := config.Load("./../config.conf")
+	if err != nil {
+		panic(err)
+	}
+
+	connpool = GetTestConnectionPool(conf.Database)
 	defer connpool.Close()
 
-	kafkaWriter := kafka_helpers.GetWriter("ava-internship")
-	defer kafkaWriter.Close()
+	// Events go through an in-memory broker to an in-memory search index.
+	searchIndex := elasticsearch_helpers.NewMemoryIndex()
+	pipeline := kafka_helpers.NewPipeline(conf.Kafka, kafka_helpers.NewMemoryBroker(1), searchIndex, nil, logging.Discard())
+	pipeline.Start()
+	defer pipeline.Close()
+	publisher := pipeline.Publisher
 
-	CompanyCont = GetCompanyController(connpool, kafkaWriter)
-	EmployeeCont = GetEmployeeController(connpool)
-	ProductCont = getProductController(connpool, kafkaWriter, &EmployeeCont.Service.Repository)
-	ConstraintCont = GetConstraintController(connpool)
-	ExternalRightCont = GetExternalRightController(connpool)
+	CompanyCont = GetCompanyController(connpool, publisher)
+	EmployeeCont = GetEmployeeController(connpool, publisher)
+	ProductCont = getProductController(connpool, publisher, searchIndex, &EmployeeCont.Service.Repository)
+	ConstraintCont = GetConstraintController(connpool, publisher)
+	ExternalRightCont = GetExternalRightController(connpool, publisher)
 
 	utils.SetUpTables(connpool)
 
 	os.Exit(m.Run())
 }
 
-func GetTestConnectionPool() *pgxpool.Pool {
-	var conf Config
-	if _, err := confl.DecodeFile("./../dbconfig.conf", &conf); err != nil {
-		panic(err)
-	}
-
-	poolConfig, _ := pgxpool.ParseConfig(conf.TestDatabaseURL)
+func GetTestConnectionPool(conf config.DatabaseConfig) *pgxpool.Pool {
+	poolConfig, _ := pgxpool.ParseConfig(conf.TestURL)
 
 	connection, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
 	if err != nil {
@@ -69,54 +68,62 @@ func GetTestConnectionPool() *pgxpool.Pool {
 	return connection
 }
 
-func GetCompanyController(connpool *pgxpool.Pool, kafkaWriter *kafka.Writer) CompanyController {
-	companyRepository := repositories.NewCompanyRepo(connpool, kafkaWriter)
-	companyService := services.CompanyService{Repository: companyRepository}
-	companyController := CompanyController{Service: companyService}
+func GetCompanyController(connpool *pgxpool.Pool, publisher *kafka_helpers.EventPublisher) CompanyController {
+	companyRepository := repositories.NewCompanyRepo(connpool, repositories.NewUnitOfWork(connpool, false), publisher, logging.Discard())
+	companyService := services.CompanyService{Repository: companyRepository, Logger: logrus.NewEntry(logging.Discard())}
+	companyController := CompanyController{Service: companyService, Logger: logrus.NewEntry(logging.Discard())}
 
 	fmt.Println("Company controller up and running.")
 
 	return companyController
 }
 
-func GetConstraintController(connpool *pgxpool.Pool) ConstraintController {
-	constraintRepository := repositories.NewConstraintRepo(connpool)
-	constraintService := services.ConstraintService{Repository: constraintRepository}
-	constraintController := ConstraintController{Service: constraintService}
+func GetConstraintController(connpool *pgxpool.Pool, publisher *kafka_helpers.EventPublisher) ConstraintController {
+	constraintRepository := repositories.NewConstraintRepo(connpool, repositories.NewUnitOfWork(connpool, false), publisher, logging.Discard())
+	constraintService := services.ConstraintService{Repository: constraintRepository, Logger: logrus.NewEntry(logging.Discard())}
+	constraintController := ConstraintController{Service: constraintService, Logger: logrus.NewEntry(logging.Discard())}
 
 	fmt.Println("Constraint controller up and running.")
 
 	return constraintController
 }
 
-func GetExternalRightController(connpool *pgxpool.Pool) ExternalRightController {
-	externalRightRepository := repositories.NewExternalRightRepo(connpool)
-	externalRightService := services.ExternalRightService{Repository: externalRightRepository}
-	externalRightController := ExternalRightController{Service: externalRightService}
+func GetExternalRightController(connpool *pgxpool.Pool, publisher *kafka_helpers.EventPublisher) ExternalRightController {
+	externalRightRepository := repositories.NewExternalRightRepo(connpool, repositories.NewUnitOfWork(connpool, false), publisher, logging.Discard())
+	externalRightService := services.ExternalRightService{Repository: externalRightRepository, Logger: logrus.NewEntry(logging.Discard())}
+	externalRightController := ExternalRightController{Service: externalRightService, Logger: logrus.NewEntry(logging.Discard())}
 
 	fmt.Println("ExternalRight controller up and running.")
 
 	return externalRightController
 }
 
-func GetEmployeeController(connpool *pgxpool.Pool) EmployeeController {
-	employeeRepository := repositories.NewEmployeeRepo(connpool)
-	employeeService := services.EmployeeService{Repository: employeeRepository}
-	employeeController := EmployeeController{Service: employeeService}
+func GetEmployeeController(connpool *pgxpool.Pool, publisher *kafka_helpers.EventPublisher) EmployeeController {
+	employeeRepository := repositories.NewEmployeeRepo(connpool, repositories.NewUnitOfWork(connpool, false), publisher, logging.Discard())
+	employeeService := services.EmployeeService{Repository: employeeRepository, Logger: logrus.NewEntry(logging.Discard())}
+	employeeController := EmployeeController{Service: employeeService, Logger: logrus.NewEntry(logging.Discard())}
 
 	fmt.Println("Employee controller up and running.")
 
 	return employeeController
 }
 
-func getProductController(connpool *pgxpool.Pool, kafkaWriter *kafka.Writer, employeeRepo *repositories.EmployeeRepository) ProductController {
-
-	productRepository := repositories.NewProductRepo(connpool, kafkaWriter)
-	productService := services.ProductService{ProductRepository: productRepository, EmployeeRepository: *employeeRepo}
-	productController := ProductController{Service: productService}
+func getProductController(connpool *pgxpool.Pool, publisher *kafka_helpers.EventPublisher, searchIndex elasticsearch_helpers.SearchIndex, employeeRepo *repositories.EmployeeRepository) ProductController {
+
+	unitOfWork := repositories.NewUnitOfWork(connpool, false)
+	productRepository := repositories.NewProductRepo(connpool, unitOfWork, publisher, logging.Discard())
+	productService := services.ProductService{
+		ProductRepository:  productRepository,
+		EmployeeRepository: *employeeRepo,
+		SearchIndex:        searchIndex,
+		UnitOfWork:         unitOfWork,
+		ImportBatchSize:    2,
+		ImportMaxRows:      5,
+		Logger:             logrus.NewEntry(logging.Discard()),
+	}
+	productController := ProductController{Service: productService, Logger: logrus.NewEntry(logging.Discard())}
 
 	fmt.Println("Product controller up and running.")
 
 	return productController
 }
-
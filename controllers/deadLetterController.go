@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"internship_project/models"
+	"internship_project/services"
+	"internship_project/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type DeadLetterController struct {
+	Service services.DeadLetterService
+	Logger  *logrus.Entry
+}
+
+func (controller *DeadLetterController) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.DeadLetterPending, models.DeadLetterReplayed, models.DeadLetterDiscarded:
+	default:
+		utils.WriteErrToClient(w, errors.New("Unknown status, expected pending, replayed or discarded"))
+		return
+	}
+
+	deadLetters, err := controller.Service.GetDeadLetters(r.Context(), status)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get dead letters")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deadLetters)
+}
+
+func (controller *DeadLetterController) GetDeadLetterById(w http.ResponseWriter, r *http.Request) {
+	idParam := mux.Vars(r)["id"]
+
+	deadLetter, err := controller.Service.GetDeadLetter(r.Context(), idParam)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get dead letter")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deadLetter)
+}
+
+func (controller *DeadLetterController) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	idParam := mux.Vars(r)["id"]
+
+	deadLetter, err := controller.Service.Replay(r.Context(), utils.JWTSubject(r.Header.Get("jwt")), idParam)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to replay dead letter")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deadLetter)
+}
+
+func (controller *DeadLetterController) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var filter models.DeadLetterFilter
+	err := json.NewDecoder(r.Body).Decode(&filter)
+	if err != nil {
+		utils.WriteErrToClient(w, err)
+		return
+	}
+
+	replayed, err := controller.Service.ReplayMatching(r.Context(), utils.JWTSubject(r.Header.Get("jwt")), filter)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).WithField("replayed", len(replayed)).Warn("Unable to replay dead letters")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replayed)
+}
+
+func (controller *DeadLetterController) DiscardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	idParam := mux.Vars(r)["id"]
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	err := controller.Service.Discard(r.Context(), utils.JWTSubject(r.Header.Get("jwt")), idParam, body.Reason)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to discard dead letter")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
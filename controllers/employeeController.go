@@ -8,19 +8,22 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 //EmployeeController .
 type EmployeeController struct {
 	Service services.EmployeeService
+	Logger  *logrus.Entry
 }
 
 // GetAllEmployees is used for getting all employees from the database
 func (controller *EmployeeController) GetAllEmployees(w http.ResponseWriter, r *http.Request) {
 	idEmployee := r.Header.Get("employeeID")
-	allEmployees, err := controller.Service.GetAllEmployees(idEmployee)
+	allEmployees, err := controller.Service.GetAllEmployees(r.Context(), idEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all employees")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -35,9 +38,10 @@ func (controller *EmployeeController) AddNewEmployee(w http.ResponseWriter, r *h
 	var newEmployee models.Employee
 	json.NewDecoder(r.Body).Decode(&newEmployee)
 
-	err := controller.Service.AddNewEmployee(&newEmployee)
+	err := controller.Service.AddNewEmployee(r.Context(), &newEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add new employee")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -52,9 +56,10 @@ func (controller *EmployeeController) GetEmployeeByID(w http.ResponseWriter, r *
 	idEmployee := r.Header.Get("employeeID")
 	id := mux.Vars(r)["id"] // Because ID is string in database
 
-	employee, err := controller.Service.GetEmployeeByID(id, idEmployee)
+	employee, err := controller.Service.GetEmployeeByID(r.Context(), id, idEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get employee")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -69,9 +74,10 @@ func (controller *EmployeeController) UpdateEmployee(w http.ResponseWriter, r *h
 	var updatedEmployee models.Employee
 	json.NewDecoder(r.Body).Decode(&updatedEmployee)
 
-	err := controller.Service.UpdateEmployee(updatedEmployee)
+	err := controller.Service.UpdateEmployee(r.Context(), updatedEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update employee")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -82,9 +88,10 @@ func (controller *EmployeeController) UpdateEmployee(w http.ResponseWriter, r *h
 func (controller *EmployeeController) DeleteEmployee(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	err := controller.Service.DeleteEmployee(id)
+	err := controller.Service.DeleteEmployee(r.Context(), id)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete employee")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -8,15 +8,18 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type ExternalRightController struct {
 	Service services.ExternalRightService
+	Logger  *logrus.Entry
 }
 
 func (controller *ExternalRightController) GetAllEars(w http.ResponseWriter, r *http.Request) {
-	ears, err := controller.Service.GetAllEars()
+	ears, err := controller.Service.GetAllEars(r.Context())
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all ears")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -27,8 +30,9 @@ func (controller *ExternalRightController) GetAllEars(w http.ResponseWriter, r *
 func (controller *ExternalRightController) GetEarById(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	ear, err := controller.Service.GetEar(idParam)
+	ear, err := controller.Service.GetEar(r.Context(), idParam)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get ear")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -39,8 +43,9 @@ func (controller *ExternalRightController) GetEarById(w http.ResponseWriter, r *
 func (controller *ExternalRightController) AddEar(w http.ResponseWriter, r *http.Request) {
 	var newEar models.ExternalRights
 	json.NewDecoder(r.Body).Decode(&newEar)
-	err := controller.Service.AddNewEar(&newEar)
+	err := controller.Service.AddNewEar(r.Context(), &newEar)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add ear")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -52,9 +57,10 @@ func (controller *ExternalRightController) UpdateEar(w http.ResponseWriter, r *h
 	var updateEar models.ExternalRights
 	json.NewDecoder(r.Body).Decode(&updateEar)
 
-	err := controller.Service.UpdateEar(updateEar)
+	err := controller.Service.UpdateEar(r.Context(), updateEar)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update ear")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -65,9 +71,10 @@ func (controller *ExternalRightController) UpdateEar(w http.ResponseWriter, r *h
 func (controller *ExternalRightController) DeleteEar(w http.ResponseWriter, r *http.Request) {
 	idParam := mux.Vars(r)["id"]
 
-	err := controller.Service.DeleteEar(idParam)
+	err := controller.Service.DeleteEar(r.Context(), idParam)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete ear")
 		utils.WriteErrToClient(w, err)
 		return
 	}
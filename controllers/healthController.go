@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"encoding/json"
+	"internship_project/health"
+	"net/http"
+	"time"
+)
+
+type HealthController struct {
+	Checks  []health.Check
+	Timeout time.Duration
+}
+
+// Liveness only reports that the process is able to answer requests.
+func (controller *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health.Report{Status: health.StatusUp})
+}
+
+// Readiness checks every dependency and answers with 503 when a critical
+// one is down.
+func (controller *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := health.Run(r.Context(), controller.Checks, controller.Timeout)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
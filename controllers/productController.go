@@ -2,24 +2,30 @@ package controllers
 
 import (
 	"encoding/json"
-	"internship_project/elasticsearch_helpers"
+	"errors"
+	"fmt"
 	"internship_project/models"
 	"internship_project/services"
 	"internship_project/utils"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type ProductController struct {
-	Service             services.ProductService
-	ElasticsearchClient elasticsearch_helpers.ElasticsearchClient
+	Service services.ProductService
+	Logger  *logrus.Entry
 }
 
 func (controller *ProductController) GetAllProducts(w http.ResponseWriter, r *http.Request) {
 	idEmployee := r.Header.Get("employeeID")
-	products, err := controller.Service.GetAllProducts(idEmployee)
+	products, err := controller.Service.GetAllProducts(r.Context(), idEmployee)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get all products")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -31,9 +37,10 @@ func (controller *ProductController) GetProductById(w http.ResponseWriter, r *ht
 	idParam := mux.Vars(r)["id"]
 	idEmployee := r.Header.Get("employeeID")
 
-	product, err := controller.Service.GetProduct(idParam, idEmployee)
+	product, err := controller.Service.GetProduct(r.Context(), idParam, idEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to get product")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -46,8 +53,9 @@ func (controller *ProductController) AddProduct(w http.ResponseWriter, r *http.R
 
 	var newProduct models.Product
 	json.NewDecoder(r.Body).Decode(&newProduct)
-	err := controller.Service.AddNewProduct(&newProduct, idEmployee)
+	err := controller.Service.AddNewProduct(r.Context(), &newProduct, idEmployee)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to add product")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -61,9 +69,10 @@ func (controller *ProductController) UpdateProduct(w http.ResponseWriter, r *htt
 	var updateProduct models.Product
 	json.NewDecoder(r.Body).Decode(&updateProduct)
 
-	err := controller.Service.UpdateProduct(updateProduct, idEmployee)
+	err := controller.Service.UpdateProduct(r.Context(), updateProduct, idEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to update product")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -76,9 +85,10 @@ func (controller *ProductController) DeleteProduct(w http.ResponseWriter, r *htt
 	var idParam string = mux.Vars(r)["id"]
 	idEmployee := r.Header.Get("employeeID")
 
-	err := controller.Service.DeleteProduct(idParam, idEmployee)
+	err := controller.Service.DeleteProduct(r.Context(), idParam, idEmployee)
 
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to delete product")
 		utils.WriteErrToClient(w, err)
 		return
 	}
@@ -86,14 +96,204 @@ func (controller *ProductController) DeleteProduct(w http.ResponseWriter, r *htt
 }
 
 func (controller *ProductController) SearchProducts(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
-	json, err := controller.ElasticsearchClient.SearchDocument(name)
+	request, err := parseSearchRequest(r.URL.Query())
+	if err != nil {
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	idEmployee := r.Header.Get("employeeID")
 
+	result, err := controller.Service.SearchProducts(r.Context(), request, idEmployee)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to search products")
 		utils.WriteErrToClient(w, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
 
+func (controller *ProductController) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	size := 10
+	if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+		var err error
+		size, err = strconv.Atoi(sizeParam)
+		if err != nil {
+			utils.WriteErrToClient(w, errors.New("size must be a whole number"))
+			return
+		}
+	}
+	idEmployee := r.Header.Get("employeeID")
+
+	suggestions, err := controller.Service.SuggestProducts(r.Context(), r.URL.Query().Get("prefix"), size, idEmployee)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to suggest products")
+		utils.WriteErrToClient(w, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(json)
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// ImportProducts creates the products of a CSV or NDJSON body. The format is
+// the format query parameter, or else the Content-Type of the body. Rows
+// that cannot be imported are reported with 422 and nothing is imported.
+func (controller *ProductController) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := productFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	if format == "" {
+		utils.WriteErrToClient(w, errors.New("Send the products as text/csv or application/x-ndjson, or set format to csv or ndjson"))
+		return
+	}
+	dryRun := false
+	if dryRunParam := r.URL.Query().Get("dry_run"); dryRunParam != "" {
+		dryRun, err = strconv.ParseBool(dryRunParam)
+		if err != nil {
+			utils.WriteErrToClient(w, errors.New("dry_run must be true or false"))
+			return
+		}
+	}
+	idEmployee := r.Header.Get("employeeID")
+
+	result, err := controller.Service.ImportProducts(r.Context(), models.NewProductDecoder(format, r.Body), dryRun, idEmployee)
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to import products")
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if result.Failed > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportProducts streams the products the employee may see as CSV or NDJSON.
+// The format is the format query parameter, or else taken from the Accept
+// header, CSV by default.
+func (controller *ProductController) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := productFormat(r.URL.Query().Get("format"), strings.Split(r.Header.Get("Accept"), ",")...)
+	if err != nil {
+		utils.WriteErrToClient(w, err)
+		return
+	}
+	if format == "" {
+		format = models.ProductFormatCSV
+	}
+	idEmployee := r.Header.Get("employeeID")
+
+	writer := &exportWriter{w: w, format: format}
+	encoder := models.NewProductEncoder(format, writer)
+	err = controller.Service.ExportProducts(r.Context(), idEmployee, encoder.Encode)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to export products")
+		// Once products were written the status is sent, the client only
+		// sees a cut off export.
+		if !writer.started {
+			utils.WriteErrToClient(w, err)
+		}
+	}
+}
+
+// exportWriter sets the headers of an export with its first write, so that
+// an export that fails before can still answer with an error.
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  models.ProductFormat
+	started bool
+}
+
+func (writer *exportWriter) Write(p []byte) (int, error) {
+	if !writer.started {
+		writer.started = true
+		writer.w.Header().Set("Content-Type", writer.format.ContentType())
+		writer.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, writer.format))
+	}
+	return writer.w.Write(p)
+}
+
+// productFormat returns the format of the format query parameter, or else
+// of the first media type that names one. It is empty when none does.
+func productFormat(formatParam string, mediaTypes ...string) (models.ProductFormat, error) {
+	if formatParam != "" {
+		return models.ParseProductFormat(formatParam)
+	}
+	for _, mediaType := range mediaTypes {
+		if format, ok := models.ProductFormatOf(strings.TrimSpace(mediaType)); ok {
+			return format, nil
+		}
+	}
+	return "", nil
+}
+
+// parseSearchRequest reads a search request from the query string. name is
+// accepted in place of q, and search_after is the JSON array returned with
+// the previous page.
+func parseSearchRequest(query url.Values) (models.ProductSearchRequest, error) {
+	request := models.ProductSearchRequest{
+		Query:      query.Get("q"),
+		CompanyIDs: query["company"],
+		Sort:       query.Get("sort"),
+		Order:      query.Get("order"),
+	}
+	if request.Query == "" {
+		request.Query = query.Get("name")
+	}
+
+	var err error
+	float32Param := func(name string) *float32 {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return nil
+		}
+		var parsed float64
+		parsed, err = strconv.ParseFloat(value, 32)
+		if err != nil {
+			err = fmt.Errorf("%s must be a number", name)
+			return nil
+		}
+		result := float32(parsed)
+		return &result
+	}
+	int32Param := func(name string) *int32 {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return nil
+		}
+		var parsed int64
+		parsed, err = strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			err = fmt.Errorf("%s must be a whole number", name)
+			return nil
+		}
+		result := int32(parsed)
+		return &result
+	}
+
+	request.MinPrice = float32Param("min_price")
+	request.MaxPrice = float32Param("max_price")
+	request.MinQuantity = int32Param("min_quantity")
+	request.MaxQuantity = int32Param("max_quantity")
+	if size := int32Param("size"); size != nil {
+		request.Size = int(*size)
+	}
+	if interval := float32Param("price_interval"); interval != nil {
+		request.PriceInterval = float64(*interval)
+	}
+	if err != nil {
+		return request, err
+	}
+
+	if searchAfter := query.Get("search_after"); searchAfter != "" {
+		if json.Unmarshal([]byte(searchAfter), &request.SearchAfter) != nil {
+			return request, errors.New("search_after must be the JSON array returned with the previous page")
+		}
+	}
+	return request, nil
 }
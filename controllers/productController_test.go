@@ -2,12 +2,14 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"internship_project/models"
 	"internship_project/utils"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -517,3 +519,175 @@ func TestUpdateProduct(t *testing.T) {
 		assert.Equal(http.StatusOK, rr.Code, "Response code is not correct")
 	})
 }
+
+func TestImportProducts(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(ProductCont.ImportProducts)
+
+	importProducts := func(employeeID string, target string, contentType string, body string) (*httptest.ResponseRecorder, models.ProductImportResult) {
+		req, err := http.NewRequest("POST", target, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Add("employeeID", employeeID)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		result := models.ProductImportResult{}
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		return rr, result
+	}
+	productCount := func() int {
+		products, err := ProductCont.Service.GetAllProducts(context.Background(), utils.AdminCompany1.ID)
+		assert.NoError(err)
+		return len(products)
+	}
+
+	t.Run("employee with no create permissions tries to import", func(t *testing.T) {
+		utils.SetUpTables(connpool)
+
+		rr, _ := importProducts(utils.Employee1Company1.ID, "/product/import", "text/csv", "name,price,quantity\nMilk,1.5,10\n")
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "Response code is not correct")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		rr, _ := importProducts(utils.AdminCompany1.ID, "/product/import", "application/json", `[{"name":"Milk"}]`)
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "Response code is not correct")
+	})
+
+	t.Run("invalid rows are reported and nothing is imported", func(t *testing.T) {
+		before := productCount()
+		body := "name,price,quantity,idc\n" +
+			"Milk,1.5,10,\n" +
+			"Bread,free,10,\n" +
+			"Eggs,2,5," + utils.TestCompany2.ID + "\n"
+
+		rr, result := importProducts(utils.AdminCompany1.ID, "/product/import", "text/csv", body)
+
+		assert.Equal(http.StatusUnprocessableEntity, rr.Code, "Response code is not correct")
+		assert.Equal(3, result.Rows)
+		assert.Equal(0, result.Imported)
+		assert.Equal([]models.ProductRowError{
+			{Row: 2, Message: "price must be a number"},
+			{Row: 3, Message: "You can't create products for other companies"},
+		}, result.Errors)
+		assert.Equal(before, productCount())
+	})
+
+	t.Run("an invalid row after the first batch rolls back the inserted rows", func(t *testing.T) {
+		before := productCount()
+		body := "name,price,quantity\n" +
+			"Milk,1.5,10\n" +
+			"Bread,2,3\n" +
+			"Eggs,3,0\n" +
+			"Butter,cheap,1\n"
+
+		rr, result := importProducts(utils.AdminCompany1.ID, "/product/import", "text/csv", body)
+
+		assert.Equal(http.StatusUnprocessableEntity, rr.Code, "Response code is not correct")
+		assert.Equal(4, result.Rows)
+		assert.Equal(0, result.Imported)
+		assert.Equal([]models.ProductRowError{{Row: 4, Message: "price must be a number"}}, result.Errors)
+		assert.Equal(before, productCount())
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		before := productCount()
+
+		rr, result := importProducts(utils.AdminCompany1.ID, "/product/import?dry_run=true", "application/x-ndjson", `{"name":"Milk","price":1.5,"quantity":10}`)
+
+		assert.Equal(http.StatusOK, rr.Code, "Response code is not correct")
+		assert.Equal(models.ProductImportResult{DryRun: true, Rows: 1, Errors: []models.ProductRowError{}}, result)
+		assert.Equal(before, productCount())
+	})
+
+	t.Run("too many rows", func(t *testing.T) {
+		body := "name,price,quantity\n" + strings.Repeat("Milk,1.5,10\n", 6)
+
+		rr, _ := importProducts(utils.AdminCompany1.ID, "/product/import", "text/csv", body)
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "Response code is not correct")
+	})
+
+	t.Run("successful import", func(t *testing.T) {
+		defer utils.SetUpTables(connpool)
+		before := productCount()
+		body := `{"name":"Milk","price":1.5,"quantity":10}` + "\n" +
+			`{"name":"Bread","price":2,"quantity":3,"idc":"` + utils.TestCompany1.ID + `"}` + "\n" +
+			`{"name":"Eggs","price":3,"quantity":0}` + "\n"
+
+		rr, result := importProducts(utils.AdminCompany1.ID, "/product/import?format=ndjson", "text/plain", body)
+
+		assert.Equal(http.StatusOK, rr.Code, "Response code is not correct")
+		assert.Equal(3, result.Imported)
+		assert.Equal(before+3, productCount())
+	})
+}
+
+func TestExportProducts(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(ProductCont.ExportProducts)
+
+	exportProducts := func(employeeID string, target string, accept string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		req.Header.Add("employeeID", employeeID)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("table does not exist", func(t *testing.T) {
+		utils.DropTables(connpool)
+		defer utils.SetUpTables(connpool)
+
+		rr := exportProducts(utils.AdminCompany1.ID, "/product/export", "")
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "Response code is not correct")
+	})
+
+	t.Run("csv holds the products the employee can see", func(t *testing.T) {
+		utils.SetUpTables(connpool)
+
+		rr := exportProducts(utils.Employee1Company2.ID, "/product/export", "")
+
+		assert.Equal(http.StatusOK, rr.Code, "Response code is not correct")
+		assert.Equal("text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		assert.Len(lines, 3)
+		assert.Equal("id,name,price,quantity,idc", lines[0])
+		assert.Contains(lines[1], utils.Product1Company1.ID)
+		assert.Contains(lines[2], utils.Product1Company2.ID)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		rr := exportProducts(utils.Employee1Company2.ID, "/product/export", "application/x-ndjson")
+
+		assert.Equal(http.StatusOK, rr.Code, "Response code is not correct")
+		assert.Equal("application/x-ndjson", rr.Header().Get("Content-Type"))
+		actual := []models.Product{}
+		decoder := json.NewDecoder(rr.Body)
+		for decoder.More() {
+			var product models.Product
+			assert.NoError(decoder.Decode(&product))
+			actual = append(actual, product)
+		}
+		assert.Equal([]models.Product{utils.Product1Company1, utils.Product1Company2}, actual)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		rr := exportProducts(utils.AdminCompany1.ID, "/product/export?format=xlsx", "")
+
+		assert.Equal(http.StatusBadRequest, rr.Code, "Response code is not correct")
+	})
+}
@@ -6,10 +6,13 @@ import (
 	"internship_project/services"
 	"internship_project/utils"
 	"net/http"
+
+	"github.com/sirupsen/logrus"
 )
 
 type UserController struct {
 	Service services.UserService
+	Logger  *logrus.Entry
 }
 
 func (controller *UserController) GoogleAuth(w http.ResponseWriter, r *http.Request) {
@@ -24,8 +27,9 @@ func (controller *UserController) GoogleAuth(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	u, err := controller.Service.GoogleSignIn(params.Token)
+	u, err := controller.Service.GoogleSignIn(r.Context(), params.Token)
 	if err != nil {
+		controller.Logger.WithContext(r.Context()).WithError(err).Warn("Unable to sign in with Google")
 		utils.WriteErrToClient(w, err)
 		return
 	}
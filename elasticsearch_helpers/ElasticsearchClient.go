@@ -6,157 +6,254 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"internship_project/models"
-	"log"
+	"internship_project/config"
+	"internship_project/logging"
+	"internship_project/metrics"
+	"internship_project/tracing"
 	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
 )
 
+// ProductIndex is the alias every product document is read from and written
+// to. It points to one versioned index, e.g. product_v2.
+const ProductIndex = "product"
+
 type ElasticsearchClient struct {
 	client *elasticsearch.Client
+	// Index is the index or alias the client works on.
+	Index  string
+	Logger *logrus.Entry
 }
 
-func GetElasticsearchClient(address string) ElasticsearchClient {
+func GetElasticsearchClient(conf config.ElasticsearchConfig, logger *logrus.Logger) ElasticsearchClient {
 	cfg := elasticsearch.Config{
 		Addresses: []string{
-			address,
+			conf.Address,
 		},
 	}
 	es, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		log.Fatalf("Error getting client: %s", err)
+		logger.WithError(err).Fatal("Error getting Elasticsearch client")
 	}
 	return ElasticsearchClient{
 		client: es,
+		Index:  ProductIndex,
+		Logger: logging.Component(logger, "elasticsearchClient"),
 	}
 }
 
-func (esclient *ElasticsearchClient) SearchDocument(term string) ([]byte, error) {
-	var buf bytes.Buffer
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"wildcard": map[string]interface{}{
-				"name": "*" + term + "*",
-			},
-		},
-	}
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, err
-	}
+// WithIndex returns a client that works on index instead.
+func (esclient ElasticsearchClient) WithIndex(index string) ElasticsearchClient {
+	esclient.Index = index
+	esclient.Logger = esclient.Logger.WithField("index", index)
+	return esclient
+}
 
-	res, err := esclient.client.Search(
-		esclient.client.Search.WithContext(context.Background()),
-		esclient.client.Search.WithIndex("product"),
-		esclient.client.Search.WithBody(&buf),
-		esclient.client.Search.WithTrackTotalHits(true),
-		esclient.client.Search.WithPretty(),
+func startSpan(ctx context.Context, index string, operation string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "elasticsearch."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String("elasticsearch"),
+			semconv.DBOperationKey.String(operation),
+			label.String("elasticsearch.index", index),
+		),
 	)
+}
+
+// ErrVersionConflict is returned when the document already has the version of
+// a change, or a newer one.
+var ErrVersionConflict = errors.New("document already has this or a newer version")
+
+// IndexDocument stores body as the document with id. A version greater than 0
+// is used as external version, so that the document is only replaced by a
+// newer version of it. Otherwise the document is always replaced.
+func (esclient *ElasticsearchClient) IndexDocument(ctx context.Context, id string, body string, version int64) (err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "index")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("index", start, err)
+		tracing.End(ctx, span, err)
+	}()
+
+	req := esapi.IndexRequest{
+		Index:      esclient.Index,
+		DocumentID: id,
+		Body:       strings.NewReader(body),
+	}
+	setVersion(&req.Version, &req.VersionType, version)
+
+	logger := esclient.Logger.WithContext(ctx).WithField("document_id", id)
+
+	res, err := req.Do(ctx, esclient.client)
 	if err != nil {
-		return nil, err
+		logger.WithError(err).Error("Error getting response")
+		return err
 	}
 	defer res.Body.Close()
-
+	if res.StatusCode == 409 {
+		return ErrVersionConflict
+	}
 	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return nil, err
-		} else {
-			return nil, errors.New(fmt.Sprintf("[%s] %s: %s",
-				res.Status(),
-				e["error"].(map[string]interface{})["type"],
-				e["error"].(map[string]interface{})["reason"]))
-		}
+		logger.WithField("status", res.Status()).Error("Error indexing document")
+		return fmt.Errorf("[%s] Error indexing document ID=%s", res.Status(), id)
 	}
 
 	var r map[string]interface{}
-
 	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, err
+		logger.WithError(err).Error("Error parsing the response body")
+		return err
+	} else {
+		logger.WithFields(logrus.Fields{
+			"status":  res.Status(),
+			"result":  r["result"],
+			"version": int(r["_version"].(float64)),
+		}).Info("Indexed document")
 	}
+	return nil
+}
 
-	resultSet, resultSetOkay := r["hits"].(map[string]interface{})["hits"].([]interface{})
+// DeleteDocument deletes the document with id. A version greater than 0 is
+// used as external version like in IndexDocument. Deleting a document that
+// does not exist succeeds.
+func (esclient *ElasticsearchClient) DeleteDocument(ctx context.Context, id string, version int64) (err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "delete")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("delete", start, err)
+		tracing.End(ctx, span, err)
+	}()
 
-	if !resultSetOkay {
-		return []byte{}, errors.New("An error occurred when extracting the result set")
+	req := esapi.DeleteRequest{
+		Index:      esclient.Index,
+		DocumentID: id,
 	}
+	setVersion(&req.Version, &req.VersionType, version)
+	logger := esclient.Logger.WithContext(ctx).WithField("document_id", id)
 
-	var final []models.Product
-	for _, hit := range resultSet {
-		jsonProduct, err := json.Marshal(hit.(map[string]interface{})["_source"])
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-
-		product := models.Product{}
-		if err := json.Unmarshal(jsonProduct, &product); err != nil {
-			fmt.Println(err)
-			continue
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		logger.WithError(err).Error("Error getting response")
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 404:
+		logger.Debug("Document was already deleted")
+		return nil
+	case 409:
+		return ErrVersionConflict
+	}
+	if res.IsError() {
+		logger.WithField("status", res.Status()).Error("Error deleting document")
+		return fmt.Errorf("[%s] Error deleting document ID=%s", res.Status(), id)
+	} else {
+		var r map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+			logger.WithError(err).Error("Error parsing the response body")
+			return err
+		} else {
+			logger.WithFields(logrus.Fields{
+				"status":  res.Status(),
+				"result":  r["result"],
+				"version": int(r["_version"].(float64)),
+			}).Info("Deleted document")
 		}
+	}
+	return nil
+}
 
-		final = append(final, product)
+func setVersion(version **int, versionType *string, v int64) {
+	if v <= 0 {
+		return
 	}
+	external := int(v)
+	*version = &external
+	*versionType = "external"
+}
 
-	json, _ := json.MarshalIndent(final, "", "    ")
+// DeleteCompanyDocuments removes the documents of every product of a company
+// with a single delete-by-query.
+func (esclient *ElasticsearchClient) DeleteCompanyDocuments(ctx context.Context, companyID string) (err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "delete_by_query")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("delete_by_query", start, err)
+		tracing.End(ctx, span, err)
+	}()
 
-	return json, nil
-}
+	var buf bytes.Buffer
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{
+				"idc": companyID,
+			},
+		},
+	}
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return err
+	}
 
-func (esclient *ElasticsearchClient) IndexDocument(id string, body string) error {
-	req := esapi.IndexRequest{
-		Index:      "product",
-		DocumentID: id,
-		Body:       strings.NewReader(body),
-		Refresh:    "true",
+	refresh := true
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{esclient.Index},
+		Body:      &buf,
+		Conflicts: "proceed",
+		Refresh:   &refresh,
 	}
+	logger := esclient.Logger.WithContext(ctx).WithField("company_id", companyID)
 
-	res, err := req.Do(context.Background(), esclient.client)
+	res, err := req.Do(ctx, esclient.client)
 	if err != nil {
-		log.Printf("Error getting response: %s", err)
+		logger.WithError(err).Error("Error getting response")
 		return err
 	}
 	defer res.Body.Close()
 	if res.IsError() {
-		log.Printf("[%s] Error indexing document ID=%s", res.Status(), id)
-		return err
+		logger.WithField("status", res.Status()).Error("Error deleting company documents")
+		return fmt.Errorf("[%s] Error deleting documents of company ID=%s", res.Status(), companyID)
 	}
 
 	var r map[string]interface{}
 	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Printf("Error parsing the response body: %s", err)
+		logger.WithError(err).Error("Error parsing the response body")
 		return err
-	} else {
-		log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
 	}
+	logger.WithFields(logrus.Fields{
+		"status":  res.Status(),
+		"deleted": r["deleted"],
+	}).Info("Deleted company documents")
 	return nil
 }
 
-func (esclient *ElasticsearchClient) DeleteDocument(id string) error {
-	req := esapi.DeleteRequest{
-		Index:      "product",
-		DocumentID: id,
-		Refresh:    "true",
-	}
-	res, err := req.Do(context.Background(), esclient.client)
+// ClusterHealth returns an error when the cluster is unreachable or its
+// status is red.
+func (esclient *ElasticsearchClient) ClusterHealth(ctx context.Context) error {
+	res, err := esclient.client.Cluster.Health(
+		esclient.client.Cluster.Health.WithContext(ctx),
+	)
 	if err != nil {
-		log.Printf("Error getting response: %s", err)
 		return err
 	}
 	defer res.Body.Close()
+
 	if res.IsError() {
-		err := fmt.Sprint("[%s] Error deleting document ID=%s", res.Status(), id)
-		log.Printf(err)
-		return errors.New(err)
-	} else {
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return err
-		} else {
-			log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-		}
+		return fmt.Errorf("[%s] Error reading cluster health", res.Status())
+	}
+
+	var r map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return err
+	}
+
+	if r["status"] == "red" {
+		return errors.New("Elasticsearch cluster status is red")
 	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package elasticsearch_helpers
+
+import (
+	"fmt"
+	"internship_project/models"
+)
+
+// rangeOperators maps the operators of access constraints to range queries.
+var rangeOperators = map[string]string{
+	">":  "gt",
+	">=": "gte",
+	"<":  "lt",
+	"<=": "lte",
+}
+
+// constrainedProperties are the product fields access constraints can limit.
+var constrainedProperties = map[string]bool{
+	"price":    true,
+	"quantity": true,
+}
+
+// accessFilter matches the products of companyID and the products of other
+// companies that companyID may read, the same as
+// ProductRepository.GetAllProducts: each constraint row grants the products
+// of its company that satisfy it.
+func accessFilter(companyID string, constraints []models.EarConstraint) (map[string]interface{}, error) {
+	should := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"idc": companyID}},
+	}
+	for _, constraint := range constraints {
+		company := map[string]interface{}{"term": map[string]interface{}{"idc": constraint.IDSC}}
+		if constraint.Operator == "" {
+			should = append(should, company)
+			continue
+		}
+
+		// Unknown operators and properties deny access instead of being
+		// ignored, which would grant more than the right allows.
+		operator, ok := rangeOperators[constraint.Operator]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported access constraint operator %q", constraint.Operator)
+		}
+		if !constrainedProperties[constraint.Property] {
+			return nil, fmt.Errorf("Unsupported access constraint property %q", constraint.Property)
+		}
+		should = append(should, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					company,
+					map[string]interface{}{"range": map[string]interface{}{
+						constraint.Property: map[string]interface{}{operator: constraint.PropertyValue},
+					}},
+				},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should":               should,
+			"minimum_should_match": 1,
+		},
+	}, nil
+}
+
+// readable reports whether companyID may read product, following the same
+// rules as accessFilter.
+func readable(product models.Product, companyID string, constraints []models.EarConstraint) bool {
+	if product.IDC == companyID {
+		return true
+	}
+	for _, constraint := range constraints {
+		if constraint.IDSC != product.IDC {
+			continue
+		}
+		if constraint.Operator == "" {
+			return true
+		}
+
+		var value float64
+		switch constraint.Property {
+		case "price":
+			value = float64(product.Price)
+		case "quantity":
+			value = float64(product.Quantity)
+		default:
+			continue
+		}
+		limit := float64(constraint.PropertyValue)
+		switch constraint.Operator {
+		case ">":
+			if value > limit {
+				return true
+			}
+		case ">=":
+			if value >= limit {
+				return true
+			}
+		case "<":
+			if value < limit {
+				return true
+			}
+		case "<=":
+			if value <= limit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readableCompanies returns companyID and every company it may read some
+// products of.
+func readableCompanies(companyID string, constraints []models.EarConstraint) []string {
+	companies := []string{companyID}
+	seen := map[string]bool{companyID: true}
+	for _, constraint := range constraints {
+		if !seen[constraint.IDSC] {
+			seen[constraint.IDSC] = true
+			companies = append(companies, constraint.IDSC)
+		}
+	}
+	return companies
+}
@@ -0,0 +1,64 @@
+package elasticsearch_helpers
+
+import (
+	"encoding/json"
+	"internship_project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("own company only", func(t *testing.T) {
+		filter, err := accessFilter("c1", nil)
+		assert.NoError(err)
+
+		body, _ := json.Marshal(filter)
+		assert.JSONEq(`{"bool":{"minimum_should_match":1,"should":[{"term":{"idc":"c1"}}]}}`, string(body))
+	})
+
+	t.Run("rights with and without constraints", func(t *testing.T) {
+		filter, err := accessFilter("c1", []models.EarConstraint{
+			{IDSC: "c2"},
+			{IDSC: "c3", Property: "price", Operator: "<=", PropertyValue: 100},
+		})
+		assert.NoError(err)
+
+		body, _ := json.Marshal(filter)
+		assert.JSONEq(`{"bool":{"minimum_should_match":1,"should":[
+			{"term":{"idc":"c1"}},
+			{"term":{"idc":"c2"}},
+			{"bool":{"filter":[{"term":{"idc":"c3"}},{"range":{"price":{"lte":100}}}]}}
+		]}}`, string(body))
+	})
+
+	t.Run("unknown constraints deny access", func(t *testing.T) {
+		_, err := accessFilter("c1", []models.EarConstraint{{IDSC: "c2", Property: "price", Operator: "~", PropertyValue: 1}})
+		assert.Error(err)
+
+		_, err = accessFilter("c1", []models.EarConstraint{{IDSC: "c2", Property: "name", Operator: ">", PropertyValue: 1}})
+		assert.Error(err)
+	})
+}
+
+func TestReadable(t *testing.T) {
+	assert := assert.New(t)
+	constraints := []models.EarConstraint{
+		{IDSC: "c2"},
+		{IDSC: "c3", Property: "quantity", Operator: ">", PropertyValue: 10},
+	}
+
+	t.Run("same rules as the access filter", func(t *testing.T) {
+		assert.True(readable(models.Product{IDC: "c1"}, "c1", constraints))
+		assert.True(readable(models.Product{IDC: "c2"}, "c1", constraints))
+		assert.True(readable(models.Product{IDC: "c3", Quantity: 11}, "c1", constraints))
+		assert.False(readable(models.Product{IDC: "c3", Quantity: 10}, "c1", constraints))
+		assert.False(readable(models.Product{IDC: "c4"}, "c1", constraints))
+	})
+
+	t.Run("every readable company once", func(t *testing.T) {
+		assert.Equal([]string{"c1", "c2", "c3"}, readableCompanies("c1", append(constraints, models.EarConstraint{IDSC: "c2"})))
+	})
+}
@@ -0,0 +1,128 @@
+package elasticsearch_helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"internship_project/metrics"
+	"internship_project/tracing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BulkIndex  = "index"
+	BulkDelete = "delete"
+)
+
+// BulkOperation indexes or deletes one document in a bulk request. Version
+// works like in IndexDocument.
+type BulkOperation struct {
+	Action  string
+	ID      string
+	Body    []byte
+	Version int64
+}
+
+type bulkResponse struct {
+	Errors bool                                `json:"errors"`
+	Items  []map[string]bulkResponseItemResult `json:"items"`
+}
+
+type bulkResponseItemResult struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// Bulk sends every operation in one _bulk request without forcing a
+// refresh. It returns an error if the request as a whole failed, otherwise
+// the result of every operation in the order of operations. Like with
+// IndexDocument and DeleteDocument, an operation on an older version fails
+// with ErrVersionConflict and deleting a missing document succeeds.
+func (esclient *ElasticsearchClient) Bulk(ctx context.Context, operations []BulkOperation) (results []error, err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "bulk")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("bulk", start, err)
+		tracing.End(ctx, span, err)
+	}()
+
+	body, err := bulkBody(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	req := esapi.BulkRequest{
+		Index: esclient.Index,
+		Body:  body,
+	}
+	logger := esclient.Logger.WithContext(ctx).WithField("operations", len(operations))
+
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		logger.WithError(err).Error("Error getting response")
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		logger.WithField("status", res.Status()).Error("Error sending bulk request")
+		return nil, fmt.Errorf("[%s] Error sending bulk request", res.Status())
+	}
+
+	var r bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		logger.WithError(err).Error("Error parsing the response body")
+		return nil, err
+	}
+	if len(r.Items) != len(operations) {
+		return nil, fmt.Errorf("Bulk response has %d items for %d operations", len(r.Items), len(operations))
+	}
+
+	results = make([]error, len(operations))
+	failed := 0
+	for i, item := range r.Items {
+		result := item[operations[i].Action]
+		switch {
+		case result.Status == 409:
+			results[i] = ErrVersionConflict
+		case result.Status == 404 && operations[i].Action == BulkDelete:
+		case result.Status >= 300:
+			results[i] = fmt.Errorf("[%d] %s: %s", result.Status, result.Error.Type, result.Error.Reason)
+			failed++
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"status": res.Status(),
+		"failed": failed,
+	}).Info("Sent bulk request")
+	return results, nil
+}
+
+// bulkBody encodes operations as the newline delimited JSON of a _bulk
+// request.
+func bulkBody(operations []BulkOperation) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	for _, operation := range operations {
+		meta := map[string]interface{}{"_id": operation.ID}
+		if operation.Version > 0 {
+			meta["version"] = operation.Version
+			meta["version_type"] = "external"
+		}
+		if err := json.NewEncoder(&buf).Encode(map[string]interface{}{operation.Action: meta}); err != nil {
+			return nil, err
+		}
+		if operation.Action == BulkIndex {
+			buf.Write(operation.Body)
+			buf.WriteByte('\n')
+		}
+	}
+	return &buf, nil
+}
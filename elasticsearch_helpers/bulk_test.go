@@ -0,0 +1,29 @@
+package elasticsearch_helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkBody(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("versioned operations", func(t *testing.T) {
+		body, err := bulkBody([]BulkOperation{
+			{Action: BulkIndex, ID: "1", Body: []byte(`{"name":"milk"}`), Version: 2},
+			{Action: BulkDelete, ID: "2", Version: 5},
+		})
+		assert.NoError(err)
+		assert.Equal(`{"index":{"_id":"1","version":2,"version_type":"external"}}
+{"name":"milk"}
+{"delete":{"_id":"2","version":5,"version_type":"external"}}
+`, body.String())
+	})
+
+	t.Run("unversioned operations", func(t *testing.T) {
+		body, err := bulkBody([]BulkOperation{{Action: BulkDelete, ID: "3"}})
+		assert.NoError(err)
+		assert.Equal("{\"delete\":{\"_id\":\"3\"}}\n", body.String())
+	})
+}
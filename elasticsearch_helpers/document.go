@@ -0,0 +1,36 @@
+package elasticsearch_helpers
+
+import (
+	"encoding/json"
+	"internship_project/models"
+	"strings"
+)
+
+// productDocument is what is stored in the product index for a product.
+type productDocument struct {
+	models.Product
+	Suggest suggestField `json:"suggest"`
+}
+
+type suggestField struct {
+	Input []string `json:"input"`
+}
+
+// ProductDocument returns the document stored for product.
+func ProductDocument(product models.Product) ([]byte, error) {
+	return json.Marshal(productDocument{
+		Product: product,
+		Suggest: suggestField{Input: suggestInputs(product.Name)},
+	})
+}
+
+// suggestInputs returns the name starting at each of its words, so that
+// "Coca Cola Zero" is also suggested for "cola" and "zero".
+func suggestInputs(name string) []string {
+	words := strings.Fields(name)
+	inputs := make([]string, 0, len(words))
+	for i := range words {
+		inputs = append(inputs, strings.Join(words[i:], " "))
+	}
+	return inputs
+}
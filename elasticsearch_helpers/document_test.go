@@ -0,0 +1,22 @@
+package elasticsearch_helpers
+
+import (
+	"internship_project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductDocument(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("suggested from every word", func(t *testing.T) {
+		assert.Equal([]string{"Coca Cola Zero", "Cola Zero", "Zero"}, suggestInputs(" Coca  Cola Zero"))
+	})
+
+	t.Run("product fields stay at the top level", func(t *testing.T) {
+		body, err := ProductDocument(models.Product{ID: "p1", Name: "Mleko", Price: 1.5, Quantity: 2, IDC: "c1"})
+		assert.NoError(err)
+		assert.JSONEq(`{"id":"p1","name":"Mleko","price":1.5,"quantity":2,"idc":"c1","suggest":{"input":["Mleko"]}}`, string(body))
+	})
+}
@@ -0,0 +1,189 @@
+package elasticsearch_helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sirupsen/logrus"
+)
+
+// VersionedIndex returns the name of version of the product index.
+func VersionedIndex(version int) string {
+	return fmt.Sprintf("%s_v%d", ProductIndex, version)
+}
+
+// NextIndex returns the name of the versioned product index after the
+// newest one. The first one is product_v2, the product index created before
+// indices were versioned counts as version 1.
+func (esclient *ElasticsearchClient) NextIndex(ctx context.Context) (string, error) {
+	ignoreUnavailable := true
+	req := esapi.IndicesGetRequest{
+		Index:             []string{ProductIndex + "_v*"},
+		IgnoreUnavailable: &ignoreUnavailable,
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("[%s] Error listing product indices", res.Status())
+	}
+
+	var indices map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", err
+	}
+
+	latest := 1
+	for name := range indices {
+		version, err := strconv.Atoi(strings.TrimPrefix(name, ProductIndex+"_v"))
+		if err == nil && version > latest {
+			latest = version
+		}
+	}
+	return VersionedIndex(latest + 1), nil
+}
+
+// CreateIndex creates index with the settings and mappings of the product
+// index.
+func (esclient *ElasticsearchClient) CreateIndex(ctx context.Context, index string) error {
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  strings.NewReader(productIndexBody),
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("[%s] Error creating index %s", res.Status(), index)
+	}
+
+	esclient.Logger.WithContext(ctx).WithField("index", index).Info("Created index")
+	return nil
+}
+
+// Refresh makes every document written to index so far searchable.
+func (esclient *ElasticsearchClient) Refresh(ctx context.Context, index string) error {
+	req := esapi.IndicesRefreshRequest{
+		Index: []string{index},
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("[%s] Error refreshing index %s", res.Status(), index)
+	}
+	return nil
+}
+
+// AliasIndices returns the indices alias points to.
+func (esclient *ElasticsearchClient) AliasIndices(ctx context.Context, alias string) ([]string, error) {
+	req := esapi.IndicesGetAliasRequest{
+		Name: []string{alias},
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("[%s] Error reading alias %s", res.Status(), alias)
+	}
+
+	var aliases map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&aliases); err != nil {
+		return nil, err
+	}
+
+	indices := []string{}
+	for index := range aliases {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// SwapAlias points alias to index only, in one atomic step. If alias is the
+// name of an index, as with the product index created before indices were
+// versioned, that index is deleted in the same step. It returns the indices
+// alias pointed to before.
+func (esclient *ElasticsearchClient) SwapAlias(ctx context.Context, alias string, index string) ([]string, error) {
+	previous, err := esclient.AliasIndices(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []map[string]interface{}{
+		{"add": map[string]interface{}{"index": index, "alias": alias}},
+	}
+	for _, old := range previous {
+		if old != index {
+			actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": old, "alias": alias}})
+		}
+	}
+	if len(previous) == 0 {
+		exists, err := esclient.indexExists(ctx, alias)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": alias}})
+			previous = []string{alias}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"actions": actions}); err != nil {
+		return nil, err
+	}
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: &buf,
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("[%s] Error pointing alias %s to %s", res.Status(), alias, index)
+	}
+
+	esclient.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"alias":    alias,
+		"index":    index,
+		"previous": previous,
+	}).Info("Swapped alias")
+	return previous, nil
+}
+
+func (esclient *ElasticsearchClient) indexExists(ctx context.Context, index string) (bool, error) {
+	req := esapi.IndicesExistsRequest{
+		Index: []string{index},
+	}
+	res, err := req.Do(ctx, esclient.client)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 200:
+		return true, nil
+	case 404:
+		return false, nil
+	}
+	return false, fmt.Errorf("[%s] Error checking index %s", res.Status(), index)
+}
@@ -0,0 +1,108 @@
+package elasticsearch_helpers
+
+import (
+	"context"
+	"errors"
+)
+
+// productIndexBody holds the settings and mappings of every versioned product
+// index. name is indexed three times:
+//   - name with edge n-grams of every word, for search as you type,
+//   - name.en with the English analyzer, which stems English words,
+//   - name.sr with the serbian analyzer below, which folds Cyrillic to Latin
+//     script and strips diacritics, so that "čokolada", "cokolada" and
+//     "чоколада" all match.
+//
+// name.keyword holds the whole name for sorting and aggregations. suggest
+// holds the inputs of the completion suggester, with the company as context
+// so that suggestions can be limited to the companies a caller may see.
+// Fields that are not mapped here are kept in the source but not indexed.
+const productIndexBody = `{
+	"settings": {
+		"analysis": {
+			"filter": {
+				"autocomplete_filter": {
+					"type": "edge_ngram",
+					"min_gram": 2,
+					"max_gram": 20
+				}
+			},
+			"analyzer": {
+				"autocomplete": {
+					"type": "custom",
+					"tokenizer": "standard",
+					"filter": ["lowercase", "serbian_normalization", "asciifolding", "autocomplete_filter"]
+				},
+				"autocomplete_search": {
+					"type": "custom",
+					"tokenizer": "standard",
+					"filter": ["lowercase", "serbian_normalization", "asciifolding"]
+				},
+				"serbian": {
+					"type": "custom",
+					"tokenizer": "standard",
+					"filter": ["lowercase", "serbian_normalization", "asciifolding"]
+				}
+			}
+		}
+	},
+	"mappings": {
+		"dynamic": false,
+		"properties": {
+			"id": {"type": "keyword"},
+			"idc": {"type": "keyword"},
+			"name": {
+				"type": "text",
+				"analyzer": "autocomplete",
+				"search_analyzer": "autocomplete_search",
+				"fields": {
+					"en": {"type": "text", "analyzer": "english"},
+					"sr": {"type": "text", "analyzer": "serbian"},
+					"keyword": {"type": "keyword", "ignore_above": 256}
+				}
+			},
+			"price": {"type": "float"},
+			"quantity": {"type": "integer"},
+			"suggest": {
+				"type": "completion",
+				"analyzer": "serbian",
+				"contexts": [
+					{"name": "company", "type": "category", "path": "idc"}
+				]
+			}
+		}
+	}
+}`
+
+// ErrUnversionedProductIndex is returned by EnsureProductIndex when product
+// is an index instead of an alias.
+var ErrUnversionedProductIndex = errors.New("The product index was created by dynamic mapping before indices were versioned, run the reindex command to move it to a mapped index")
+
+// EnsureProductIndex creates the first versioned product index and points
+// the product alias to it, unless the alias exists already. An unversioned
+// product index from before is not served, the reindex command has to
+// replace it first.
+func (esclient *ElasticsearchClient) EnsureProductIndex(ctx context.Context) error {
+	indices, err := esclient.AliasIndices(ctx, ProductIndex)
+	if err != nil || len(indices) != 0 {
+		return err
+	}
+	exists, err := esclient.indexExists(ctx, ProductIndex)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrUnversionedProductIndex
+	}
+
+	index, err := esclient.NextIndex(ctx)
+	if err != nil {
+		return err
+	}
+	err = esclient.CreateIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	_, err = esclient.SwapAlias(ctx, ProductIndex, index)
+	return err
+}
@@ -0,0 +1,120 @@
+package elasticsearch_helpers
+
+import (
+	"context"
+	"encoding/json"
+	"internship_project/config"
+	"internship_project/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductIndexBody(t *testing.T) {
+	assert := assert.New(t)
+
+	var body struct {
+		Settings struct {
+			Analysis struct {
+				Analyzer map[string]interface{} `json:"analyzer"`
+			} `json:"analysis"`
+		} `json:"settings"`
+		Mappings struct {
+			Properties map[string]struct {
+				Type           string `json:"type"`
+				Analyzer       string `json:"analyzer"`
+				SearchAnalyzer string `json:"search_analyzer"`
+				Fields         map[string]struct {
+					Type     string `json:"type"`
+					Analyzer string `json:"analyzer"`
+				} `json:"fields"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+
+	t.Run("valid JSON", func(t *testing.T) {
+		assert.NoError(json.Unmarshal([]byte(productIndexBody), &body))
+	})
+
+	t.Run("ids are keywords and numbers are numeric", func(t *testing.T) {
+		properties := body.Mappings.Properties
+		assert.Equal("keyword", properties["id"].Type)
+		assert.Equal("keyword", properties["idc"].Type)
+		assert.Equal("float", properties["price"].Type)
+		assert.Equal("integer", properties["quantity"].Type)
+	})
+
+	t.Run("custom analyzers are defined", func(t *testing.T) {
+		name := body.Mappings.Properties["name"]
+		analyzers := body.Settings.Analysis.Analyzer
+		assert.Contains(analyzers, name.Analyzer)
+		assert.Contains(analyzers, name.SearchAnalyzer)
+		assert.Contains(analyzers, name.Fields["sr"].Analyzer)
+		assert.Equal("english", name.Fields["en"].Analyzer)
+	})
+}
+
+func TestEnsureProductIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	// cluster answers like Elasticsearch with the product alias or index
+	// given, and records the requests that change something.
+	cluster := func(alias bool, index bool, changes *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/_alias/product":
+				if !alias {
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{}`))
+					return
+				}
+				w.Write([]byte(`{"product_v2":{"aliases":{"product":{}}}}`))
+			case r.Method == http.MethodHead && r.URL.Path == "/product":
+				if !index {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			case r.Method == http.MethodGet && r.URL.Path == "/product_v*":
+				w.Write([]byte(`{}`))
+			default:
+				*changes = append(*changes, r.Method+" "+r.URL.Path)
+				w.Write([]byte(`{"acknowledged":true}`))
+			}
+		}))
+	}
+	client := func(server *httptest.Server) ElasticsearchClient {
+		return GetElasticsearchClient(config.ElasticsearchConfig{Address: server.URL}, logging.Discard())
+	}
+
+	t.Run("the alias exists", func(t *testing.T) {
+		changes := []string{}
+		server := cluster(true, false, &changes)
+		defer server.Close()
+		esclient := client(server)
+
+		assert.NoError(esclient.EnsureProductIndex(context.Background()))
+		assert.Empty(changes)
+	})
+
+	t.Run("a new cluster gets a versioned index", func(t *testing.T) {
+		changes := []string{}
+		server := cluster(false, false, &changes)
+		defer server.Close()
+		esclient := client(server)
+
+		assert.NoError(esclient.EnsureProductIndex(context.Background()))
+		assert.Equal([]string{"PUT /product_v2", "POST /_aliases"}, changes)
+	})
+
+	t.Run("an unversioned index is not served", func(t *testing.T) {
+		changes := []string{}
+		server := cluster(false, true, &changes)
+		defer server.Close()
+		esclient := client(server)
+
+		assert.Equal(ErrUnversionedProductIndex, esclient.EnsureProductIndex(context.Background()))
+		assert.Empty(changes)
+	})
+}
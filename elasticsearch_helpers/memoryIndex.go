@@ -0,0 +1,435 @@
+package elasticsearch_helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"internship_project/models"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// MemoryIndex is a SearchIndex that keeps the product documents in memory,
+// for tests that should not need a cluster. It follows the query semantics
+// of the product index mapping as far as they matter to the callers: name
+// matches the beginnings of words, or whole words with a typo or two, with
+// Serbian diacritics folded, but without stemming. Scores only rank the hits
+// roughly like Elasticsearch does. Every change is searchable right away.
+type MemoryIndex struct {
+	mu        sync.RWMutex
+	documents map[string]models.Product
+	// versions holds the version of every document, and of every deleted
+	// one, which Elasticsearch only keeps for index.gc_deletes.
+	versions map[string]int64
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		documents: map[string]models.Product{},
+		versions:  map[string]int64{},
+	}
+}
+
+func (index *MemoryIndex) IndexDocument(ctx context.Context, id string, body string, version int64) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	return index.index(id, []byte(body), version)
+}
+
+func (index *MemoryIndex) DeleteDocument(ctx context.Context, id string, version int64) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	return index.delete(id, version)
+}
+
+func (index *MemoryIndex) DeleteCompanyDocuments(ctx context.Context, companyID string) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	for id, product := range index.documents {
+		if product.IDC == companyID {
+			delete(index.documents, id)
+			// Delete-by-query uses internal versioning.
+			index.versions[id]++
+		}
+	}
+	return nil
+}
+
+func (index *MemoryIndex) Bulk(ctx context.Context, operations []BulkOperation) ([]error, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	results := make([]error, len(operations))
+	for i, operation := range operations {
+		switch operation.Action {
+		case BulkIndex:
+			results[i] = index.index(operation.ID, operation.Body, operation.Version)
+		case BulkDelete:
+			results[i] = index.delete(operation.ID, operation.Version)
+		default:
+			return nil, fmt.Errorf("Unknown bulk action %q", operation.Action)
+		}
+	}
+	return results, nil
+}
+
+// Get returns the document with id.
+func (index *MemoryIndex) Get(id string) (models.Product, bool) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	product, ok := index.documents[id]
+	return product, ok
+}
+
+// Version returns the version of the document with id, or of its deletion.
+func (index *MemoryIndex) Version(id string) int64 {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return index.versions[id]
+}
+
+func (index *MemoryIndex) index(id string, body []byte, version int64) error {
+	var product models.Product
+	if err := json.Unmarshal(body, &product); err != nil {
+		return fmt.Errorf("Error parsing document ID=%s: %w", id, err)
+	}
+	if err := index.setVersion(id, version); err != nil {
+		return err
+	}
+	index.documents[id] = product
+	return nil
+}
+
+func (index *MemoryIndex) delete(id string, version int64) error {
+	if err := index.setVersion(id, version); err != nil {
+		return err
+	}
+	delete(index.documents, id)
+	return nil
+}
+
+// setVersion applies the rules of external versions: a version greater than
+// 0 has to be newer than the stored one, otherwise the version is
+// incremented.
+func (index *MemoryIndex) setVersion(id string, version int64) error {
+	if version <= 0 {
+		index.versions[id]++
+		return nil
+	}
+	if stored, ok := index.versions[id]; ok && version <= stored {
+		return ErrVersionConflict
+	}
+	index.versions[id] = version
+	return nil
+}
+
+type memoryHit struct {
+	product    models.Product
+	score      float64
+	highlights map[string][]string
+	sort       []interface{}
+}
+
+func (index *MemoryIndex) SearchProducts(ctx context.Context, request models.ProductSearchRequest, companyID string, constraints []models.EarConstraint) (models.ProductSearchResult, error) {
+	result := models.ProductSearchResult{
+		Hits:           []models.ProductSearchHit{},
+		Companies:      []models.FacetBucket{},
+		PriceHistogram: []models.HistogramBucket{},
+	}
+	// Constraints are checked the same way as for a search request.
+	if _, err := accessFilter(companyID, constraints); err != nil {
+		return result, err
+	}
+	if len(request.SearchAfter) != 0 && len(request.SearchAfter) != 2 {
+		return result, fmt.Errorf("search_after has %d values, expected 2", len(request.SearchAfter))
+	}
+
+	index.mu.RLock()
+	matches := []memoryHit{}
+	for _, product := range index.documents {
+		if !readable(product, companyID, constraints) || !inRanges(product, request) {
+			continue
+		}
+		score, highlights, ok := matchName(product.Name, request.Query)
+		if !ok {
+			continue
+		}
+		hit := memoryHit{product: product, score: score, highlights: highlights}
+		hit.sort = []interface{}{sortValue(hit, request.Sort), product.ID}
+		matches = append(matches, hit)
+	}
+	index.mu.RUnlock()
+
+	// The facets count every match, the company filter is a post filter.
+	companies := map[string]int64{}
+	histogram := map[float64]int64{}
+	hits := []memoryHit{}
+	for _, hit := range matches {
+		companies[hit.product.IDC]++
+		histogram[math.Floor(float64(hit.product.Price)/request.PriceInterval)*request.PriceInterval]++
+		if len(request.CompanyIDs) == 0 || contains(request.CompanyIDs, hit.product.IDC) {
+			hits = append(hits, hit)
+		}
+	}
+	result.Total = int64(len(hits))
+
+	sort.Slice(hits, func(i, j int) bool {
+		return compareSort(hits[i].sort, hits[j].sort, request.Order) < 0
+	})
+	for _, hit := range hits {
+		if len(result.Hits) == request.Size {
+			break
+		}
+		if len(request.SearchAfter) != 0 && compareSort(hit.sort, request.SearchAfter, request.Order) <= 0 {
+			continue
+		}
+		result.Hits = append(result.Hits, models.ProductSearchHit{
+			Product:    hit.product,
+			Score:      hit.score,
+			Highlights: hit.highlights,
+			Sort:       hit.sort,
+		})
+	}
+	if len(result.Hits) == request.Size {
+		result.SearchAfter = result.Hits[len(result.Hits)-1].Sort
+	}
+
+	for key, count := range companies {
+		result.Companies = append(result.Companies, models.FacetBucket{Key: key, Count: count})
+	}
+	sort.Slice(result.Companies, func(i, j int) bool {
+		if result.Companies[i].Count != result.Companies[j].Count {
+			return result.Companies[i].Count > result.Companies[j].Count
+		}
+		return result.Companies[i].Key < result.Companies[j].Key
+	})
+	if len(result.Companies) > 50 {
+		result.Companies = result.Companies[:50]
+	}
+	for from, count := range histogram {
+		result.PriceHistogram = append(result.PriceHistogram, models.HistogramBucket{From: from, Count: count})
+	}
+	sort.Slice(result.PriceHistogram, func(i, j int) bool {
+		return result.PriceHistogram[i].From < result.PriceHistogram[j].From
+	})
+	return result, nil
+}
+
+func (index *MemoryIndex) SuggestProducts(ctx context.Context, prefix string, size int, companyID string, constraints []models.EarConstraint) ([]models.ProductSuggestion, error) {
+	folded := fold(prefix)
+
+	index.mu.RLock()
+	suggestions := []models.ProductSuggestion{}
+	for id, product := range index.documents {
+		if !readable(product, companyID, constraints) {
+			continue
+		}
+		for _, input := range suggestInputs(product.Name) {
+			if strings.HasPrefix(fold(input), folded) {
+				suggestions = append(suggestions, models.ProductSuggestion{ID: id, Name: product.Name, Text: input})
+				break
+			}
+		}
+	}
+	index.mu.RUnlock()
+
+	// Every input has the same weight, so the suggestions are ordered by
+	// their text.
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Text != suggestions[j].Text {
+			return suggestions[i].Text < suggestions[j].Text
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	if len(suggestions) > size {
+		suggestions = suggestions[:size]
+	}
+	return suggestions, nil
+}
+
+func inRanges(product models.Product, request models.ProductSearchRequest) bool {
+	if request.MinPrice != nil && product.Price < *request.MinPrice {
+		return false
+	}
+	if request.MaxPrice != nil && product.Price > *request.MaxPrice {
+		return false
+	}
+	if request.MinQuantity != nil && product.Quantity < *request.MinQuantity {
+		return false
+	}
+	if request.MaxQuantity != nil && product.Quantity > *request.MaxQuantity {
+		return false
+	}
+	return true
+}
+
+// matchName matches query against name like searchBody: every term starting
+// a word of the name, or any term equal to a word up to the fuzziness AUTO
+// allows. An empty query matches every name with a score of 1.
+func matchName(name string, query string) (float64, map[string][]string, bool) {
+	terms := analyze(query)
+	if len(terms) == 0 {
+		return 1, nil, true
+	}
+	words := strings.Fields(name)
+	tokens := make([]string, len(words))
+	for i, word := range words {
+		tokens[i] = strings.Join(analyze(word), "")
+	}
+
+	matched := make([]bool, len(words))
+	prefixes, score := 0, 0.0
+	for _, term := range terms {
+		prefix, fuzzy := false, 0.0
+		for i, token := range tokens {
+			// The edge n-grams start at two characters.
+			if len([]rune(term)) >= 2 && strings.HasPrefix(token, term) {
+				prefix = true
+				matched[i] = true
+			}
+			switch distance := editDistance(term, token); {
+			case distance == 0:
+				fuzzy = 1
+				matched[i] = true
+			case distance <= fuzziness(term):
+				fuzzy = math.Max(fuzzy, 0.5)
+				matched[i] = true
+			}
+		}
+		if prefix {
+			prefixes++
+		}
+		score += fuzzy
+	}
+	if prefixes == len(terms) {
+		score += 2 * float64(prefixes)
+	}
+	if score == 0 {
+		return 0, nil, false
+	}
+
+	for i, word := range words {
+		if matched[i] {
+			words[i] = "<em>" + word + "</em>"
+		}
+	}
+	return score, map[string][]string{"name": {strings.Join(words, " ")}}, true
+}
+
+// analyze splits text into lowercase words without diacritics.
+func analyze(text string) []string {
+	return strings.FieldsFunc(fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+var diacritics = strings.NewReplacer("č", "c", "ć", "c", "š", "s", "ž", "z", "đ", "d")
+
+func fold(text string) string {
+	return diacritics.Replace(strings.ToLower(text))
+}
+
+// fuzziness is the number of edits fuzziness AUTO allows for term.
+func fuzziness(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// editDistance counts the insertions, deletions, substitutions and
+// transpositions of adjacent characters that turn a into b.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func sortValue(hit memoryHit, field string) float64 {
+	switch field {
+	case models.SortByPrice:
+		return float64(hit.product.Price)
+	case models.SortByQuantity:
+		return float64(hit.product.Quantity)
+	}
+	return hit.score
+}
+
+// compareSort compares the sort values of two hits like sortClause orders
+// them: by the first value in order, then by the ID ascending.
+func compareSort(a []interface{}, b []interface{}, order string) int {
+	x, y := toFloat(a[0]), toFloat(b[0])
+	if x != y {
+		if (x < y) == (order == "asc") {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a[1]), fmt.Sprint(b[1]))
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
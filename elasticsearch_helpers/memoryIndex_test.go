@@ -0,0 +1,161 @@
+package elasticsearch_helpers
+
+import (
+	"context"
+	"internship_project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func memoryIndexWith(products ...models.Product) *MemoryIndex {
+	index := NewMemoryIndex()
+	for _, product := range products {
+		body, _ := ProductDocument(product)
+		index.IndexDocument(context.Background(), product.ID, string(body), 1)
+	}
+	return index
+}
+
+func searchRequest(query string) models.ProductSearchRequest {
+	request := models.ProductSearchRequest{Query: query}
+	request.Normalize()
+	return request
+}
+
+func hitIDs(result models.ProductSearchResult) []string {
+	ids := []string{}
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.Product.ID)
+	}
+	return ids
+}
+
+func TestMemoryIndexVersions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("older versions are rejected", func(t *testing.T) {
+		index := NewMemoryIndex()
+		assert.NoError(index.IndexDocument(ctx, "p1", `{"id":"p1","name":"Milk"}`, 2))
+		assert.Equal(ErrVersionConflict, index.IndexDocument(ctx, "p1", `{"id":"p1","name":"Old milk"}`, 1))
+		assert.Equal(ErrVersionConflict, index.DeleteDocument(ctx, "p1", 2))
+
+		product, ok := index.Get("p1")
+		assert.True(ok)
+		assert.Equal("Milk", product.Name)
+	})
+
+	t.Run("deleted documents keep their version", func(t *testing.T) {
+		index := NewMemoryIndex()
+		assert.NoError(index.DeleteDocument(ctx, "p1", 3))
+		assert.Equal(ErrVersionConflict, index.IndexDocument(ctx, "p1", `{"id":"p1"}`, 2))
+		_, ok := index.Get("p1")
+		assert.False(ok)
+	})
+
+	t.Run("bulk reports every operation", func(t *testing.T) {
+		index := NewMemoryIndex()
+		results, err := index.Bulk(ctx, []BulkOperation{
+			{Action: BulkIndex, ID: "p1", Body: []byte(`{"id":"p1"}`), Version: 2},
+			{Action: BulkIndex, ID: "p1", Body: []byte(`{"id":"p1"}`), Version: 1},
+			{Action: BulkDelete, ID: "p2", Version: 1},
+			{Action: BulkIndex, ID: "p3", Body: []byte(`not json`)},
+		})
+		assert.NoError(err)
+		assert.Nil(results[0])
+		assert.Equal(ErrVersionConflict, results[1])
+		assert.Nil(results[2])
+		assert.Error(results[3])
+	})
+
+	t.Run("company documents are deleted", func(t *testing.T) {
+		index := memoryIndexWith(
+			models.Product{ID: "p1", IDC: "c1"},
+			models.Product{ID: "p2", IDC: "c2"},
+		)
+		assert.NoError(index.DeleteCompanyDocuments(ctx, "c1"))
+		_, ok := index.Get("p1")
+		assert.False(ok)
+		_, ok = index.Get("p2")
+		assert.True(ok)
+	})
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	index := memoryIndexWith(
+		models.Product{ID: "p1", IDC: "c1", Name: "Coca Cola Zero", Price: 120, Quantity: 5},
+		models.Product{ID: "p2", IDC: "c1", Name: "Čokolada", Price: 95, Quantity: 30},
+		models.Product{ID: "p3", IDC: "c2", Name: "Cola light", Price: 110, Quantity: 2},
+		models.Product{ID: "p4", IDC: "c2", Name: "Cola cherry", Price: 250, Quantity: 8},
+		models.Product{ID: "p5", IDC: "c3", Name: "Cola", Price: 100, Quantity: 1},
+	)
+	constraints := []models.EarConstraint{{IDSC: "c2", Property: "price", Operator: "<", PropertyValue: 200}}
+
+	t.Run("only readable products are found", func(t *testing.T) {
+		result, err := index.SearchProducts(ctx, searchRequest(""), "c1", constraints)
+		assert.NoError(err)
+		assert.ElementsMatch([]string{"p1", "p2", "p3"}, hitIDs(result))
+		assert.Equal(int64(3), result.Total)
+	})
+
+	t.Run("unknown constraints deny access", func(t *testing.T) {
+		_, err := index.SearchProducts(ctx, searchRequest(""), "c1", []models.EarConstraint{{IDSC: "c2", Property: "name", Operator: "<"}})
+		assert.Error(err)
+	})
+
+	t.Run("prefixes, typos and diacritics match", func(t *testing.T) {
+		result, _ := index.SearchProducts(ctx, searchRequest("col"), "c1", constraints)
+		assert.ElementsMatch([]string{"p1", "p3"}, hitIDs(result))
+
+		result, _ = index.SearchProducts(ctx, searchRequest("zeor"), "c1", constraints)
+		assert.Equal([]string{"p1"}, hitIDs(result))
+		assert.Equal([]string{"Coca Cola <em>Zero</em>"}, result.Hits[0].Highlights["name"])
+
+		result, _ = index.SearchProducts(ctx, searchRequest("cokolada"), "c1", constraints)
+		assert.Equal([]string{"p2"}, hitIDs(result))
+	})
+
+	t.Run("ranges, sorting and facets", func(t *testing.T) {
+		request := searchRequest("")
+		min := float32(100)
+		request.MinPrice = &min
+		request.Sort, request.Order = models.SortByPrice, "desc"
+		request.CompanyIDs = []string{"c1"}
+
+		result, err := index.SearchProducts(ctx, request, "c1", constraints)
+		assert.NoError(err)
+		assert.Equal([]string{"p1"}, hitIDs(result))
+		assert.Equal([]models.FacetBucket{{Key: "c1", Count: 1}, {Key: "c2", Count: 1}}, result.Companies)
+		assert.Equal([]models.HistogramBucket{{From: 110, Count: 1}, {From: 120, Count: 1}}, result.PriceHistogram)
+	})
+
+	t.Run("search after pages through the hits", func(t *testing.T) {
+		request := searchRequest("")
+		request.Sort, request.Order, request.Size = models.SortByQuantity, "asc", 2
+
+		first, _ := index.SearchProducts(ctx, request, "c1", constraints)
+		assert.Equal([]string{"p3", "p1"}, hitIDs(first))
+		assert.Equal([]interface{}{float64(5), "p1"}, first.SearchAfter)
+
+		request.SearchAfter = first.SearchAfter
+		second, _ := index.SearchProducts(ctx, request, "c1", constraints)
+		assert.Equal([]string{"p2"}, hitIDs(second))
+		assert.Nil(second.SearchAfter)
+	})
+
+	t.Run("suggestions match any word", func(t *testing.T) {
+		suggestions, err := index.SuggestProducts(ctx, "co", 10, "c1", constraints)
+		assert.NoError(err)
+		assert.Equal([]models.ProductSuggestion{
+			{ID: "p1", Name: "Coca Cola Zero", Text: "Coca Cola Zero"},
+			{ID: "p3", Name: "Cola light", Text: "Cola light"},
+			{ID: "p2", Name: "Čokolada", Text: "Čokolada"},
+		}, suggestions)
+
+		suggestions, _ = index.SuggestProducts(ctx, "zer", 10, "c1", constraints)
+		assert.Equal([]models.ProductSuggestion{{ID: "p1", Name: "Coca Cola Zero", Text: "Zero"}}, suggestions)
+	})
+}
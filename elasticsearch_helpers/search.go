@@ -0,0 +1,207 @@
+package elasticsearch_helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"internship_project/metrics"
+	"internship_project/models"
+	"internship_project/tracing"
+	"time"
+)
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Score     float64             `json:"_score"`
+			Source    models.Product      `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+			Sort      []interface{}       `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations struct {
+		Companies struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"companies"`
+		PriceHistogram struct {
+			Buckets []struct {
+				Key      float64 `json:"key"`
+				DocCount int64   `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"price_histogram"`
+	} `json:"aggregations"`
+}
+
+// SearchProducts searches the products that companyID may read, given the
+// constraints of its external access rights. The request is expected to be
+// complete, with its sort, order, size and price interval set.
+func (esclient *ElasticsearchClient) SearchProducts(ctx context.Context, request models.ProductSearchRequest, companyID string, constraints []models.EarConstraint) (result models.ProductSearchResult, err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "search")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("search", start, err)
+		tracing.End(ctx, span, err)
+	}()
+
+	access, err := accessFilter(companyID, constraints)
+	if err != nil {
+		return result, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(searchBody(request, access)); err != nil {
+		return result, err
+	}
+
+	res, err := esclient.client.Search(
+		esclient.client.Search.WithContext(ctx),
+		esclient.client.Search.WithIndex(esclient.Index),
+		esclient.client.Search.WithBody(&buf),
+		esclient.client.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return result, err
+		}
+		return result, fmt.Errorf("[%s] %s: %s", res.Status(), e.Error.Type, e.Error.Reason)
+	}
+
+	var r searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return result, err
+	}
+
+	result = models.ProductSearchResult{
+		Total:          r.Hits.Total.Value,
+		Hits:           []models.ProductSearchHit{},
+		Companies:      []models.FacetBucket{},
+		PriceHistogram: []models.HistogramBucket{},
+	}
+	for _, hit := range r.Hits.Hits {
+		result.Hits = append(result.Hits, models.ProductSearchHit{
+			Product:    hit.Source,
+			Score:      hit.Score,
+			Highlights: hit.Highlight,
+			Sort:       hit.Sort,
+		})
+	}
+	if len(result.Hits) == request.Size {
+		result.SearchAfter = result.Hits[len(result.Hits)-1].Sort
+	}
+	for _, bucket := range r.Aggregations.Companies.Buckets {
+		result.Companies = append(result.Companies, models.FacetBucket{Key: bucket.Key, Count: bucket.DocCount})
+	}
+	for _, bucket := range r.Aggregations.PriceHistogram.Buckets {
+		result.PriceHistogram = append(result.PriceHistogram, models.HistogramBucket{From: bucket.Key, Count: bucket.DocCount})
+	}
+	return result, nil
+}
+
+// searchBody builds the body of a product search. The company filter is a
+// post filter, so that the company facet still counts the other companies.
+func searchBody(request models.ProductSearchRequest, access map[string]interface{}) map[string]interface{} {
+	filters := []interface{}{access}
+	price := map[string]interface{}{}
+	if request.MinPrice != nil {
+		price["gte"] = *request.MinPrice
+	}
+	if request.MaxPrice != nil {
+		price["lte"] = *request.MaxPrice
+	}
+	if len(price) != 0 {
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"price": price}})
+	}
+	quantity := map[string]interface{}{}
+	if request.MinQuantity != nil {
+		quantity["gte"] = *request.MinQuantity
+	}
+	if request.MaxQuantity != nil {
+		quantity["lte"] = *request.MaxQuantity
+	}
+	if len(quantity) != 0 {
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"quantity": quantity}})
+	}
+
+	// name matches the beginnings of words, name.en and name.sr whole words
+	// in their stemmed and normalized forms, also with a typo or two.
+	var must interface{} = map[string]interface{}{"match_all": map[string]interface{}{}}
+	if request.Query != "" {
+		must = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{"match": map[string]interface{}{
+						"name": map[string]interface{}{"query": request.Query, "operator": "and", "boost": 2},
+					}},
+					map[string]interface{}{"multi_match": map[string]interface{}{
+						"query":     request.Query,
+						"fields":    []string{"name.en", "name.sr"},
+						"fuzziness": "AUTO",
+					}},
+				},
+				"minimum_should_match": 1,
+			},
+		}
+	}
+
+	body := map[string]interface{}{
+		"size": request.Size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filters,
+			},
+		},
+		"sort": sortClause(request),
+		"aggs": map[string]interface{}{
+			"companies": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "idc", "size": 50},
+			},
+			"price_histogram": map[string]interface{}{
+				"histogram": map[string]interface{}{"field": "price", "interval": request.PriceInterval, "min_doc_count": 1},
+			},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"name":    map[string]interface{}{},
+				"name.en": map[string]interface{}{},
+				"name.sr": map[string]interface{}{},
+			},
+		},
+	}
+	if len(request.CompanyIDs) != 0 {
+		body["post_filter"] = map[string]interface{}{"terms": map[string]interface{}{"idc": request.CompanyIDs}}
+	}
+	if len(request.SearchAfter) != 0 {
+		body["search_after"] = request.SearchAfter
+	}
+	return body
+}
+
+// sortClause sorts by the requested field, with the product ID as tiebreaker
+// so that search_after pages through the hits without gaps or repetitions.
+func sortClause(request models.ProductSearchRequest) []interface{} {
+	tiebreaker := map[string]interface{}{"id": "asc"}
+	switch request.Sort {
+	case models.SortByPrice, models.SortByQuantity:
+		return []interface{}{map[string]interface{}{request.Sort: request.Order}, tiebreaker}
+	}
+	return []interface{}{map[string]interface{}{"_score": request.Order}, tiebreaker}
+}
@@ -0,0 +1,26 @@
+package elasticsearch_helpers
+
+import (
+	"context"
+	"internship_project/models"
+)
+
+// SearchIndex stores product documents and searches them. ElasticsearchClient
+// implements it against a cluster, MemoryIndex in memory for tests.
+type SearchIndex interface {
+	// IndexDocument stores body, a document as returned by ProductDocument,
+	// under id. A version greater than 0 only replaces older versions.
+	IndexDocument(ctx context.Context, id string, body string, version int64) error
+	// DeleteDocument deletes the document with id, if it is older than a
+	// version greater than 0.
+	DeleteDocument(ctx context.Context, id string, version int64) error
+	DeleteCompanyDocuments(ctx context.Context, companyID string) error
+	Bulk(ctx context.Context, operations []BulkOperation) ([]error, error)
+	SearchProducts(ctx context.Context, request models.ProductSearchRequest, companyID string, constraints []models.EarConstraint) (models.ProductSearchResult, error)
+	SuggestProducts(ctx context.Context, prefix string, size int, companyID string, constraints []models.EarConstraint) ([]models.ProductSuggestion, error)
+}
+
+var (
+	_ SearchIndex = &ElasticsearchClient{}
+	_ SearchIndex = &MemoryIndex{}
+)
@@ -0,0 +1,52 @@
+package elasticsearch_helpers
+
+import (
+	"encoding/json"
+	"internship_project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchBody(t *testing.T) {
+	assert := assert.New(t)
+	access := map[string]interface{}{"term": map[string]interface{}{"idc": "c1"}}
+
+	t.Run("empty query matches everything readable", func(t *testing.T) {
+		body, _ := json.Marshal(searchBody(models.ProductSearchRequest{Sort: models.SortByRelevance, Order: "desc", Size: 20, PriceInterval: 10}, access))
+
+		var decoded map[string]interface{}
+		json.Unmarshal(body, &decoded)
+		query := decoded["query"].(map[string]interface{})["bool"].(map[string]interface{})
+		assert.Contains(query["must"], "match_all")
+		assert.Len(query["filter"], 1)
+		assert.NotContains(decoded, "post_filter")
+		assert.NotContains(decoded, "search_after")
+	})
+
+	t.Run("ranges, companies and paging", func(t *testing.T) {
+		min, max := float32(5), float32(50)
+		request := models.ProductSearchRequest{
+			Query:       "mleko",
+			MinPrice:    &min,
+			MaxPrice:    &max,
+			CompanyIDs:  []string{"c2"},
+			Sort:        models.SortByPrice,
+			Order:       "asc",
+			Size:        10,
+			SearchAfter: []interface{}{12.5, "p1"},
+		}
+		body, _ := json.Marshal(searchBody(request, access))
+
+		var decoded map[string]interface{}
+		json.Unmarshal(body, &decoded)
+		query := decoded["query"].(map[string]interface{})["bool"].(map[string]interface{})
+		assert.Len(query["filter"], 2)
+		assert.Equal([]interface{}{
+			map[string]interface{}{"price": "asc"},
+			map[string]interface{}{"id": "asc"},
+		}, decoded["sort"])
+		assert.Equal(map[string]interface{}{"terms": map[string]interface{}{"idc": []interface{}{"c2"}}}, decoded["post_filter"])
+		assert.Equal([]interface{}{12.5, "p1"}, decoded["search_after"])
+	})
+}
@@ -0,0 +1,100 @@
+package elasticsearch_helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"internship_project/metrics"
+	"internship_project/models"
+	"internship_project/tracing"
+	"time"
+)
+
+// suggestOverfetch is how many more suggestions are requested than needed,
+// because the ones an access constraint hides are only dropped afterwards.
+const suggestOverfetch = 5
+
+type suggestResponse struct {
+	Suggest struct {
+		Products []struct {
+			Options []struct {
+				Text   string         `json:"text"`
+				ID     string         `json:"_id"`
+				Source models.Product `json:"_source"`
+			} `json:"options"`
+		} `json:"products"`
+	} `json:"suggest"`
+}
+
+// SuggestProducts returns up to size products that companyID may read whose
+// names have a word starting with prefix, the best matches first. The
+// completion suggester only filters by company, products hidden by an access
+// constraint are dropped from its options.
+func (esclient *ElasticsearchClient) SuggestProducts(ctx context.Context, prefix string, size int, companyID string, constraints []models.EarConstraint) (suggestions []models.ProductSuggestion, err error) {
+	ctx, span := startSpan(ctx, esclient.Index, "suggest")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveElasticsearch("suggest", start, err)
+		tracing.End(ctx, span, err)
+	}()
+
+	var buf bytes.Buffer
+	query := map[string]interface{}{
+		"_source": []string{"id", "name", "idc", "price", "quantity"},
+		"suggest": map[string]interface{}{
+			"products": map[string]interface{}{
+				"prefix": prefix,
+				"completion": map[string]interface{}{
+					"field": "suggest",
+					"size":  size * suggestOverfetch,
+					"contexts": map[string]interface{}{
+						"company": readableCompanies(companyID, constraints),
+					},
+				},
+			},
+		},
+	}
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, err
+	}
+
+	res, err := esclient.client.Search(
+		esclient.client.Search.WithContext(ctx),
+		esclient.client.Search.WithIndex(esclient.Index),
+		esclient.client.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("[%s] Error getting suggestions", res.Status())
+	}
+
+	var r suggestResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	suggestions = []models.ProductSuggestion{}
+	seen := map[string]bool{}
+	for _, products := range r.Suggest.Products {
+		for _, option := range products.Options {
+			if len(suggestions) == size {
+				return suggestions, nil
+			}
+			// A product is suggested once, for its best matching input.
+			if seen[option.ID] || !readable(option.Source, companyID, constraints) {
+				continue
+			}
+			seen[option.ID] = true
+			suggestions = append(suggestions, models.ProductSuggestion{
+				ID:   option.ID,
+				Name: option.Source.Name,
+				Text: option.Text,
+			})
+		}
+	}
+	return suggestions, nil
+}
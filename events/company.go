@@ -0,0 +1,49 @@
+package events
+
+import (
+	"internship_project/models"
+	"time"
+)
+
+const (
+	CompanyCreatedType = "company.created"
+	CompanyUpdatedType = "company.updated"
+	CompanyDeletedType = "company.deleted"
+
+	CompanyArchivedType = "company.archived"
+	CompanyRestoredType = "company.restored"
+)
+
+type CompanyCreated struct {
+	Company models.Company `json:"company"`
+}
+
+func (*CompanyCreated) EventType() string { return CompanyCreatedType }
+
+type CompanyUpdated struct {
+	Company models.Company `json:"company"`
+}
+
+func (*CompanyUpdated) EventType() string { return CompanyUpdatedType }
+
+type CompanyDeleted struct {
+	ID string `json:"id"`
+}
+
+func (*CompanyDeleted) EventType() string { return CompanyDeletedType }
+
+// CompanyArchived is published when a company is soft deleted. It is
+// followed by either a CompanyRestored or, once the grace period has
+// passed, a CompanyDeleted.
+type CompanyArchived struct {
+	ID         string    `json:"id"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+func (*CompanyArchived) EventType() string { return CompanyArchivedType }
+
+type CompanyRestored struct {
+	ID string `json:"id"`
+}
+
+func (*CompanyRestored) EventType() string { return CompanyRestoredType }
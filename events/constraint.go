@@ -0,0 +1,27 @@
+package events
+
+import "internship_project/models"
+
+const (
+	ConstraintCreatedType = "constraint.created"
+	ConstraintUpdatedType = "constraint.updated"
+	ConstraintDeletedType = "constraint.deleted"
+)
+
+type ConstraintCreated struct {
+	Constraint models.AccessConstraint `json:"constraint"`
+}
+
+func (*ConstraintCreated) EventType() string { return ConstraintCreatedType }
+
+type ConstraintUpdated struct {
+	Constraint models.AccessConstraint `json:"constraint"`
+}
+
+func (*ConstraintUpdated) EventType() string { return ConstraintUpdatedType }
+
+type ConstraintDeleted struct {
+	ID string `json:"id"`
+}
+
+func (*ConstraintDeleted) EventType() string { return ConstraintDeletedType }
@@ -0,0 +1,27 @@
+package events
+
+import "internship_project/models"
+
+const (
+	EmployeeCreatedType = "employee.created"
+	EmployeeUpdatedType = "employee.updated"
+	EmployeeDeletedType = "employee.deleted"
+)
+
+type EmployeeCreated struct {
+	Employee models.Employee `json:"employee"`
+}
+
+func (*EmployeeCreated) EventType() string { return EmployeeCreatedType }
+
+type EmployeeUpdated struct {
+	Employee models.Employee `json:"employee"`
+}
+
+func (*EmployeeUpdated) EventType() string { return EmployeeUpdatedType }
+
+type EmployeeDeleted struct {
+	ID string `json:"id"`
+}
+
+func (*EmployeeDeleted) EventType() string { return EmployeeDeletedType }
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// SchemaVersion is written into every envelope. Consumers accept any minor
+// version of the major version they were built for, so fields may be added
+// to payloads without a new major version.
+const (
+	SchemaMajor   = 1
+	SchemaMinor   = 1
+	SchemaVersion = "1.1"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+	ErrUnknownEventType   = errors.New("unknown event type")
+)
+
+// Envelope wraps every event published to Kafka.
+type Envelope struct {
+	ID            string          `json:"event_id"`
+	Type          string          `json:"type"`
+	SchemaVersion string          `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Actor         string          `json:"actor,omitempty"`
+	Tenant        string          `json:"tenant,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Event is implemented by every payload that can be published.
+type Event interface {
+	EventType() string
+}
+
+type actorKey struct{}
+
+type tenantKey struct{}
+
+type eventIDKey struct{}
+
+// WithActor stores who caused the events published with the returned
+// context, and on behalf of which company (tenant).
+func WithActor(ctx context.Context, actor string, tenant string) context.Context {
+	ctx = context.WithValue(ctx, actorKey{}, actor)
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// ActorFrom returns the actor and tenant WithActor stored in ctx.
+func ActorFrom(ctx context.Context) (string, string) {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return actor, tenant
+}
+
+// ActorMiddleware stores the actor and tenant that actor returns for a
+// request in its context, so that every event published while handling the
+// request names them. Services that know the caller better, e.g. from the
+// employee they load, may still set their own with WithActor.
+func ActorMiddleware(actor func(*http.Request) (string, string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor, tenant := actor(r)
+			next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), actor, tenant)))
+		})
+	}
+}
+
+// ActorMessagePrefix is the prefix of the logical decoding messages that
+// name the actor of a transaction, for change data capture. Their content is
+// an ActorMessage as JSON.
+const ActorMessagePrefix = "ava.actor"
+
+type ActorMessage struct {
+	Actor  string `json:"actor"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// WithEventID makes the envelopes created with the returned context use
+// id instead of a random ID, so that an event that is published again, e.g.
+// after a crash, is recognised as a duplicate.
+func WithEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, eventIDKey{}, id)
+}
+
+// Marshal wraps event into a new envelope and encodes it.
+func Marshal(ctx context.Context, event Event) ([]byte, error) {
+	envelope, err := NewEnvelope(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// NewEnvelope wraps event, taking the actor and tenant from ctx.
+func NewEnvelope(ctx context.Context, event Event) (Envelope, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	id, ok := ctx.Value(eventIDKey{}).(string)
+	if !ok {
+		id = uuid.NewV4().String()
+	}
+	actor, tenant := ActorFrom(ctx)
+	return Envelope{
+		ID:            id,
+		Type:          event.EventType(),
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		Actor:         actor,
+		Tenant:        tenant,
+		Payload:       payload,
+	}, nil
+}
+
+// Unmarshal decodes an envelope and its payload. Envelopes of another major
+// schema version or of an unknown type are rejected.
+func Unmarshal(data []byte) (Envelope, Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, nil, err
+	}
+
+	major, err := majorVersion(envelope.SchemaVersion)
+	if err != nil || major != SchemaMajor {
+		return envelope, nil, fmt.Errorf("%w %q", ErrUnsupportedVersion, envelope.SchemaVersion)
+	}
+
+	newEvent, ok := registry[envelope.Type]
+	if !ok {
+		return envelope, nil, fmt.Errorf("%w %q", ErrUnknownEventType, envelope.Type)
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(envelope.Payload, event); err != nil {
+		return envelope, nil, err
+	}
+	return envelope, event, nil
+}
+
+func majorVersion(version string) (int, error) {
+	return strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+}
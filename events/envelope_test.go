@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"internship_project/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	assert := assert.New(t)
+
+	product := models.Product{ID: "product-1", Name: "Milk", Price: 1.5, Quantity: 10, IDC: "company-1"}
+	ctx := WithActor(context.Background(), "employee-1", "company-1")
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := Marshal(ctx, &ProductUpdated{Product: product})
+		assert.NoError(err)
+
+		envelope, event, err := Unmarshal(data)
+
+		assert.NoError(err)
+		assert.NotEmpty(envelope.ID)
+		assert.Equal(ProductUpdatedType, envelope.Type)
+		assert.Equal(SchemaVersion, envelope.SchemaVersion)
+		assert.Equal("employee-1", envelope.Actor)
+		assert.Equal("company-1", envelope.Tenant)
+		assert.False(envelope.OccurredAt.IsZero())
+		assert.Equal(&ProductUpdated{Product: product}, event)
+	})
+
+	t.Run("every event gets its own id", func(t *testing.T) {
+		first, _ := NewEnvelope(ctx, &ProductDeleted{ID: "product-1"})
+		second, _ := NewEnvelope(ctx, &ProductDeleted{ID: "product-1"})
+
+		assert.NotEqual(first.ID, second.ID)
+	})
+
+	t.Run("event id from the context", func(t *testing.T) {
+		envelope, _ := NewEnvelope(WithEventID(ctx, "event-1"), &ProductDeleted{ID: "product-1"})
+
+		assert.Equal("event-1", envelope.ID)
+		assert.Equal("employee-1", envelope.Actor)
+	})
+
+	t.Run("newer minor version is accepted", func(t *testing.T) {
+		data := []byte(`{"event_id":"1","type":"product.deleted","schema_version":"1.7","payload":{"id":"product-1","reason":"new field"}}`)
+
+		_, event, err := Unmarshal(data)
+
+		assert.NoError(err)
+		assert.Equal(&ProductDeleted{ID: "product-1"}, event)
+	})
+
+	t.Run("unknown major version is rejected", func(t *testing.T) {
+		data := []byte(`{"event_id":"1","type":"product.deleted","schema_version":"2.0","payload":{"id":"product-1"}}`)
+
+		_, _, err := Unmarshal(data)
+
+		assert.True(errors.Is(err, ErrUnsupportedVersion))
+	})
+
+	t.Run("missing version is rejected", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]interface{}{"operation": "DELETED", "id": "product-1"})
+
+		_, _, err := Unmarshal(data)
+
+		assert.True(errors.Is(err, ErrUnsupportedVersion))
+	})
+
+	t.Run("unknown type is rejected", func(t *testing.T) {
+		data := []byte(`{"event_id":"1","type":"product.archived","schema_version":"1.0","payload":{}}`)
+
+		_, _, err := Unmarshal(data)
+
+		assert.True(errors.Is(err, ErrUnknownEventType))
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("every registered type belongs to a known aggregate", func(t *testing.T) {
+		aggregates := map[string]bool{
+			ProductAggregate:       true,
+			CompanyAggregate:       true,
+			EmployeeAggregate:      true,
+			ShopAggregate:          true,
+			ExternalRightAggregate: true,
+			ConstraintAggregate:    true,
+		}
+		for eventType, newEvent := range registry {
+			assert.True(aggregates[Aggregate(eventType)], eventType)
+			assert.Equal(eventType, newEvent().EventType())
+		}
+	})
+
+	t.Run("approval changes belong to the external right", func(t *testing.T) {
+		assert.Equal(ExternalRightAggregate, Aggregate(ExternalRightApprovalChangedType))
+	})
+}
+
+func TestActorMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var envelope Envelope
+	handler := ActorMiddleware(func(r *http.Request) (string, string) {
+		return r.Header.Get("employeeID"), r.Header.Get("companyID")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		envelope, err = NewEnvelope(r.Context(), &ShopDeleted{ID: "shop-1"})
+		assert.NoError(err)
+	}))
+
+	r := httptest.NewRequest(http.MethodDelete, "/shop/shop-1", nil)
+	r.Header.Set("employeeID", "employee-1")
+	r.Header.Set("companyID", "company-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(ShopDeletedType, envelope.Type)
+	assert.Equal("employee-1", envelope.Actor)
+	assert.Equal("company-1", envelope.Tenant)
+}
@@ -0,0 +1,37 @@
+package events
+
+import "internship_project/models"
+
+const (
+	ExternalRightCreatedType         = "external_right.created"
+	ExternalRightUpdatedType         = "external_right.updated"
+	ExternalRightDeletedType         = "external_right.deleted"
+	ExternalRightApprovalChangedType = "external_right.approval_changed"
+)
+
+type ExternalRightCreated struct {
+	ExternalRight models.ExternalRights `json:"external_right"`
+}
+
+func (*ExternalRightCreated) EventType() string { return ExternalRightCreatedType }
+
+type ExternalRightUpdated struct {
+	ExternalRight models.ExternalRights `json:"external_right"`
+}
+
+func (*ExternalRightUpdated) EventType() string { return ExternalRightUpdatedType }
+
+type ExternalRightDeleted struct {
+	ID string `json:"id"`
+}
+
+func (*ExternalRightDeleted) EventType() string { return ExternalRightDeletedType }
+
+// ExternalRightApprovalChanged is published when the sharing company approves
+// or revokes an external access right.
+type ExternalRightApprovalChanged struct {
+	ID       string `json:"id"`
+	Approved bool   `json:"approved"`
+}
+
+func (*ExternalRightApprovalChanged) EventType() string { return ExternalRightApprovalChangedType }
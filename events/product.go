@@ -0,0 +1,53 @@
+package events
+
+import "internship_project/models"
+
+const (
+	ProductCreatedType = "product.created"
+	ProductUpdatedType = "product.updated"
+	ProductDeletedType = "product.deleted"
+
+	ProductsDeletedForCompanyType  = "product.deleted_for_company"
+	ProductsArchivedForCompanyType = "product.archived_for_company"
+)
+
+// Version is the product's sequence number, which grows with every change of
+// the product. It is 0 in events published before it was introduced.
+type ProductCreated struct {
+	Product models.Product `json:"product"`
+	Version int64          `json:"version,omitempty"`
+}
+
+func (*ProductCreated) EventType() string { return ProductCreatedType }
+
+type ProductUpdated struct {
+	Product models.Product `json:"product"`
+	Version int64          `json:"version,omitempty"`
+}
+
+func (*ProductUpdated) EventType() string { return ProductUpdatedType }
+
+type ProductDeleted struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version,omitempty"`
+}
+
+func (*ProductDeleted) EventType() string { return ProductDeletedType }
+
+// ProductsDeletedForCompany is published instead of a ProductDeleted per
+// product when all products of a company are deleted at once.
+type ProductsDeletedForCompany struct {
+	CompanyID string `json:"company_id"`
+}
+
+func (*ProductsDeletedForCompany) EventType() string { return ProductsDeletedForCompanyType }
+
+// ProductsArchivedForCompany is published with CompanyArchived, on the topic
+// of the products, so that the products of the company are removed from the
+// search index. They stay in the database, and once the company is restored
+// every one of them is published again as a ProductUpdated.
+type ProductsArchivedForCompany struct {
+	CompanyID string `json:"company_id"`
+}
+
+func (*ProductsArchivedForCompany) EventType() string { return ProductsArchivedForCompanyType }
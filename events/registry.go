@@ -0,0 +1,53 @@
+package events
+
+import "strings"
+
+// Aggregates, the prefix of the types of their events.
+const (
+	ProductAggregate       = "product"
+	CompanyAggregate       = "company"
+	EmployeeAggregate      = "employee"
+	ShopAggregate          = "shop"
+	ExternalRightAggregate = "external_right"
+	ConstraintAggregate    = "constraint"
+)
+
+// registry creates an empty payload for every known event type.
+var registry = map[string]func() Event{
+	ProductCreatedType: func() Event { return &ProductCreated{} },
+	ProductUpdatedType: func() Event { return &ProductUpdated{} },
+	ProductDeletedType: func() Event { return &ProductDeleted{} },
+
+	ProductsDeletedForCompanyType:  func() Event { return &ProductsDeletedForCompany{} },
+	ProductsArchivedForCompanyType: func() Event { return &ProductsArchivedForCompany{} },
+
+	CompanyCreatedType: func() Event { return &CompanyCreated{} },
+	CompanyUpdatedType: func() Event { return &CompanyUpdated{} },
+	CompanyDeletedType: func() Event { return &CompanyDeleted{} },
+
+	CompanyArchivedType: func() Event { return &CompanyArchived{} },
+	CompanyRestoredType: func() Event { return &CompanyRestored{} },
+
+	EmployeeCreatedType: func() Event { return &EmployeeCreated{} },
+	EmployeeUpdatedType: func() Event { return &EmployeeUpdated{} },
+	EmployeeDeletedType: func() Event { return &EmployeeDeleted{} },
+
+	ShopCreatedType: func() Event { return &ShopCreated{} },
+	ShopUpdatedType: func() Event { return &ShopUpdated{} },
+	ShopDeletedType: func() Event { return &ShopDeleted{} },
+
+	ExternalRightCreatedType:         func() Event { return &ExternalRightCreated{} },
+	ExternalRightUpdatedType:         func() Event { return &ExternalRightUpdated{} },
+	ExternalRightDeletedType:         func() Event { return &ExternalRightDeleted{} },
+	ExternalRightApprovalChangedType: func() Event { return &ExternalRightApprovalChanged{} },
+
+	ConstraintCreatedType: func() Event { return &ConstraintCreated{} },
+	ConstraintUpdatedType: func() Event { return &ConstraintUpdated{} },
+	ConstraintDeletedType: func() Event { return &ConstraintDeleted{} },
+}
+
+// Aggregate returns the aggregate an event type belongs to, e.g. "product"
+// for "product.created".
+func Aggregate(eventType string) string {
+	return strings.SplitN(eventType, ".", 2)[0]
+}
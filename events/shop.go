@@ -0,0 +1,27 @@
+package events
+
+import "internship_project/models"
+
+const (
+	ShopCreatedType = "shop.created"
+	ShopUpdatedType = "shop.updated"
+	ShopDeletedType = "shop.deleted"
+)
+
+type ShopCreated struct {
+	Shop models.Shop `json:"shop"`
+}
+
+func (*ShopCreated) EventType() string { return ShopCreatedType }
+
+type ShopUpdated struct {
+	Shop models.Shop `json:"shop"`
+}
+
+func (*ShopUpdated) EventType() string { return ShopUpdatedType }
+
+type ShopDeleted struct {
+	ID string `json:"id"`
+}
+
+func (*ShopDeleted) EventType() string { return ShopDeletedType }
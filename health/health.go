@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Check describes a single dependency of the application. A failing critical
+// check makes the whole application unready, while a failing non-critical
+// check only marks it as degraded.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+type DependencyStatus struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Latency  string `json:"latency"`
+}
+
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Ready reports whether the application can serve traffic, which is the
+// case as long as no critical dependency is down.
+func (report Report) Ready() bool {
+	return report.Status != StatusDown
+}
+
+// Run executes all checks concurrently, each limited by timeout, and
+// aggregates their results into a single report.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	report := Report{
+		Status:       StatusUp,
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Run(checkCtx)
+
+			dependency := DependencyStatus{
+				Status:   StatusUp,
+				Critical: check.Critical,
+				Latency:  time.Since(start).String(),
+			}
+			if err != nil {
+				dependency.Status = StatusDown
+				dependency.Error = err.Error()
+			}
+
+			mutex.Lock()
+			report.Dependencies[check.Name] = dependency
+			mutex.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if dependency.Status == StatusUp {
+			continue
+		}
+		if dependency.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	return report
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func passing(ctx context.Context) error {
+	return nil
+}
+
+func failing(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("all dependencies up", func(t *testing.T) {
+		report := Run(context.Background(), []Check{
+			{Name: "postgres", Critical: true, Run: passing},
+			{Name: "elasticsearch", Critical: false, Run: passing},
+		}, time.Second)
+
+		assert.Equal(StatusUp, report.Status)
+		assert.True(report.Ready())
+		assert.Len(report.Dependencies, 2)
+		assert.Equal(StatusUp, report.Dependencies["postgres"].Status)
+	})
+
+	t.Run("non-critical dependency down degrades", func(t *testing.T) {
+		report := Run(context.Background(), []Check{
+			{Name: "postgres", Critical: true, Run: passing},
+			{Name: "kafka_consumer", Critical: false, Run: failing},
+		}, time.Second)
+
+		assert.Equal(StatusDegraded, report.Status)
+		assert.True(report.Ready())
+		assert.Equal(StatusDown, report.Dependencies["kafka_consumer"].Status)
+		assert.Equal("connection refused", report.Dependencies["kafka_consumer"].Error)
+	})
+
+	t.Run("critical dependency down", func(t *testing.T) {
+		report := Run(context.Background(), []Check{
+			{Name: "postgres", Critical: true, Run: failing},
+			{Name: "kafka_consumer", Critical: false, Run: failing},
+		}, time.Second)
+
+		assert.Equal(StatusDown, report.Status)
+		assert.False(report.Ready())
+	})
+
+	t.Run("check exceeding the timeout fails", func(t *testing.T) {
+		report := Run(context.Background(), []Check{
+			{Name: "kafka", Critical: true, Run: hanging},
+		}, 10*time.Millisecond)
+
+		assert.Equal(StatusDown, report.Status)
+		assert.Equal(context.DeadlineExceeded.Error(), report.Dependencies["kafka"].Error)
+	})
+}
@@ -0,0 +1,57 @@
+package kafka_helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Publisher writes messages to one topic.
+type Publisher interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// Subscriber reads the messages of one topic as a member of a consumer
+// group. FetchMessage returns io.EOF once the subscriber is closed.
+type Subscriber interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// PartitionReader reads one partition in order, outside of any consumer
+// group.
+type PartitionReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// Broker hands out the publishers and subscribers of a cluster and answers
+// questions about its offsets. KafkaBroker talks to Kafka, MemoryBroker keeps
+// everything in process for tests.
+type Broker interface {
+	Publisher(topic string) Publisher
+	// Subscriber joins the consumer group groupID on topic. A fetch waits at
+	// most maxWait for more messages.
+	Subscriber(groupID string, topic string, maxWait time.Duration) Subscriber
+	// PartitionReader reads partition of topic from offset on.
+	PartitionReader(topic string, partition int, offset int64) (PartitionReader, error)
+	// Offsets returns the first offset and the offset after the last message
+	// of every partition of topic.
+	Offsets(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error)
+	// OffsetAt returns the offset of the first message written to partition
+	// at or after t, or -1 if there is none.
+	OffsetAt(ctx context.Context, topic string, partition int, t time.Time) (int64, error)
+	// CommittedOffsets returns the offset groupID committed on every
+	// partition of topic it committed on.
+	CommittedOffsets(ctx context.Context, groupID string, topic string) (map[int]int64, error)
+	// Ping succeeds when the broker knows the topic.
+	Ping(ctx context.Context, topic string) error
+}
+
+var (
+	_ Broker = &KafkaBroker{}
+	_ Broker = &MemoryBroker{}
+)
@@ -0,0 +1,120 @@
+package kafka_helpers
+
+import (
+	"context"
+	"fmt"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
+	"internship_project/logging"
+	"internship_project/tracing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// CatchUp applies the events of the main topic to an index that is being
+// rebuilt, next to the consumer group that keeps the live index up to date.
+// It reads every partition directly and does not commit any offsets.
+type CatchUp struct {
+	broker   Broker
+	consumer KafkaConsumer
+	// positions holds the offset every partition was read up to.
+	positions map[int]int64
+}
+
+func NewCatchUp(conf config.KafkaConfig, broker Broker, index elasticsearch_helpers.SearchIndex, companies CompanyFilter, logger *logrus.Logger) *CatchUp {
+	return &CatchUp{
+		broker: broker,
+		consumer: KafkaConsumer{
+			Index:     index,
+			Companies: companies,
+			Config:    conf,
+			Processed: NewProcessedEvents(conf.DedupWindow),
+			Logger:    logging.Component(logger, "kafkaCatchUp"),
+		},
+		positions: map[int]int64{},
+	}
+}
+
+// Run applies every event from where the previous Run stopped up to the
+// current end of the main topic and returns how many messages it read. The
+// first Run starts at the messages written since. Events that cannot be
+// decoded are skipped, any other failure stops Run.
+func (catchUp *CatchUp) Run(ctx context.Context, since time.Time) (int, error) {
+	conf := catchUp.consumer.Config
+	offsets, err := catchUp.broker.Offsets(ctx, conf.MainTopic)
+	if err != nil {
+		return 0, err
+	}
+
+	read := 0
+	for _, partition := range offsets {
+		n, err := catchUp.runPartition(ctx, partition.Partition, since, partition.LastOffset)
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func (catchUp *CatchUp) runPartition(ctx context.Context, partition int, since time.Time, end int64) (int, error) {
+	conf := catchUp.consumer.Config
+	start, ok := catchUp.positions[partition]
+	if !ok {
+		var err error
+		start, err = catchUp.broker.OffsetAt(ctx, conf.MainTopic, partition, since)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if start < 0 || start >= end {
+		// Nothing was written since.
+		catchUp.positions[partition] = end
+		return 0, nil
+	}
+
+	reader, err := catchUp.broker.PartitionReader(conf.MainTopic, partition, start)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	catchUp.positions[partition] = end
+
+	read := 0
+	batch := make([]kafka.Message, 0, conf.BatchSize)
+	for offset := start; offset < end; {
+		m, err := reader.ReadMessage(ctx)
+		if err != nil {
+			catchUp.positions[partition] = offset
+			return read, err
+		}
+		offset = m.Offset + 1
+		batch = append(batch, m)
+		if len(batch) < conf.BatchSize && offset < end {
+			continue
+		}
+
+		err = catchUp.apply(batch)
+		if err != nil {
+			// The batch is applied again by the next Run.
+			catchUp.positions[partition] = batch[0].Offset
+			return read, err
+		}
+		read += len(batch)
+		batch = batch[:0]
+	}
+	return read, nil
+}
+
+func (catchUp *CatchUp) apply(batch []kafka.Message) error {
+	var firstErr error
+	for _, item := range catchUp.consumer.applyBatch(batch) {
+		tracing.End(item.ctx, item.span, item.err)
+		if item.err != nil && !isPermanent(item.err) && firstErr == nil {
+			firstErr = fmt.Errorf("offset %d of partition %d: %w", item.message.Offset, item.message.Partition, item.err)
+		}
+	}
+	return firstErr
+}
@@ -3,90 +3,392 @@ package kafka_helpers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"hash/fnv"
+	"internship_project/config"
 	"internship_project/elasticsearch_helpers"
-	"log"
+	"internship_project/events"
+	"internship_project/metrics"
+	"internship_project/models"
+	"internship_project/tracing"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/semconv"
 )
 
+// CompanyFilter returns which of the companies ids still exist and are not
+// archived.
+type CompanyFilter func(ctx context.Context, ids []string) (map[string]bool, error)
+
 type KafkaConsumer struct {
-	Reader   *kafka.Reader
-	EsClient elasticsearch_helpers.ElasticsearchClient
+	Reader  Subscriber
+	Broker  Broker
+	Topic   string
+	GroupID string
+	Index   elasticsearch_helpers.SearchIndex
+	// Companies limits the products that are indexed to those of the
+	// companies it returns, so that a replayed event does not bring back a
+	// document of a deleted or archived company. nil indexes every product.
+	Companies CompanyFilter
+	Config    config.KafkaConfig
+	Retries   *RetryRouter
+	// Processed holds the IDs of the events already applied, which are
+	// skipped when they are delivered again.
+	Processed *ProcessedEvents
+	// Delayed holds every message back until the time in its retry header,
+	// which is what the consumers of the retry tiers do.
+	Delayed bool
+	Logger  *logrus.Entry
 }
 
+// Consume fetches messages in batches of up to Config.BatchSize, waiting at
+// most Config.FlushInterval for a batch to fill up. Config.Workers goroutines
+// write a batch to the search index, each with one bulk request for the keys
+// it is given. The offsets of a batch are committed
+// once every message of it was either applied or handed to the retry path.
+// Consume returns when the reader is closed.
 func (consumer *KafkaConsumer) Consume() {
-	fmt.Println("KafkaConsumer is ready to consume on topic " + consumer.Reader.Stats().Topic)
-	retryWriter := GetWriter("retry")
-	defer retryWriter.Close()
-	retryProducer := KafkaProducer{
-		Writer: retryWriter,
-	}
+	consumer.Logger.WithField("topic", consumer.Topic).Info("KafkaConsumer is ready to consume")
+	var next *kafka.Message
 	for {
-		m, err := consumer.Reader.FetchMessage(context.Background())
-		if err != nil {
-			fmt.Println("Error while fetching message")
-			consumer.resolveError(retryProducer, m)
-			continue
+		batch := consumer.fetchBatch(next)
+		next = nil
+		if len(batch) == 0 {
+			consumer.Logger.WithField("topic", consumer.Topic).Info("KafkaConsumer stopped")
+			return
 		}
+		if consumer.Delayed && len(batch) > 1 {
+			// A message that is not due yet starts the next batch
+			// instead.
+			last := batch[len(batch)-1]
+			if retryAt(last).After(time.Now()) {
+				batch, next = batch[:len(batch)-1], &last
+			}
+		}
+
+		consumer.processBatch(batch)
 
-		fmt.Printf("message at offset %d: %s = %s\n", m.Offset, string(m.Key), string(m.Value))
+		err := consumer.Reader.CommitMessages(context.Background(), batch...)
+		if err != nil {
+			consumer.Logger.WithError(err).WithField("messages", len(batch)).Error("Failed to commit messages")
+		}
+	}
+}
 
-		var jsonMessage map[string]interface{}
-		err = json.Unmarshal(m.Value, &jsonMessage)
+// fetchBatch blocks until at least one message is available, starting with
+// first if it is set, and then adds messages until the batch is full or the
+// flush interval has passed. It returns an empty batch once the reader is
+// closed.
+func (consumer *KafkaConsumer) fetchBatch(first *kafka.Message) []kafka.Message {
+	batch := make([]kafka.Message, 0, consumer.Config.BatchSize)
+	if first != nil {
+		batch = append(batch, *first)
+	}
+	for len(batch) == 0 {
+		m, err := consumer.Reader.FetchMessage(context.Background())
+		if err == io.EOF {
+			return batch
+		}
 		if err != nil {
-			fmt.Println("Unable to parse Kafka message")
-			consumer.resolveError(retryProducer, m)
+			consumer.Logger.WithError(err).Error("Error while fetching message")
+			time.Sleep(time.Second)
 			continue
 		}
+		batch = append(batch, m)
+	}
+	if consumer.Delayed {
+		time.Sleep(time.Until(retryAt(batch[0])))
+	}
 
-		if jsonMessage["operation"] == OperationEnumString(Created) || jsonMessage["operation"] == OperationEnumString(Updated) {
-			product, err := json.Marshal(jsonMessage["product"])
-			if err != nil {
-				fmt.Println(err)
-				consumer.resolveError(retryProducer, m)
-				continue
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(consumer.Config.FlushInterval)*time.Millisecond)
+	defer cancel()
+	for len(batch) < consumer.Config.BatchSize {
+		m, err := consumer.Reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				consumer.Logger.WithError(err).Error("Error while fetching message")
 			}
+			break
+		}
+		batch = append(batch, m)
+		if consumer.Delayed && retryAt(m).After(time.Now()) {
+			break
+		}
+	}
+	return batch
+}
 
-			err = consumer.EsClient.IndexDocument(string(m.Key), string(product))
-			if err != nil {
-				fmt.Println("Error while indexing new Elasticsearch document")
-				consumer.resolveError(retryProducer, m)
-				continue
-			}
-		} else if jsonMessage["operation"] == OperationEnumString(Deleted) {
-			err = consumer.EsClient.DeleteDocument(string(m.Key))
-			if err != nil {
-				fmt.Println("Error while deleting Elasticsearch document")
-				consumer.resolveError(retryProducer, m)
-				continue
+// batchItem is a message of a batch while it is being processed.
+type batchItem struct {
+	message  kafka.Message
+	ctx      context.Context
+	span     trace.Span
+	logger   *logrus.Entry
+	envelope events.Envelope
+	err      error
+}
+
+// processBatch applies batch with Config.Workers goroutines and hands every
+// message that failed to the retry path.
+func (consumer *KafkaConsumer) processBatch(batch []kafka.Message) {
+	for _, round := range workerBatches(batch, consumer.Config.Workers) {
+		var wg sync.WaitGroup
+		for _, messages := range round {
+			wg.Add(1)
+			go func(messages []kafka.Message) {
+				defer wg.Done()
+				for _, item := range consumer.applyBatch(messages) {
+					tracing.End(item.ctx, item.span, item.err)
+					if item.err != nil {
+						consumer.retry(item)
+					}
+				}
+			}(messages)
+		}
+		wg.Wait()
+	}
+}
+
+// workerBatches splits batch into rounds of up to workers sub-batches that
+// can be applied concurrently. The messages of one key stay in one sub-batch,
+// in their order. A product.deleted_for_company or product.archived_for_company
+// event changes the documents of many keys, so it is a round of its own
+// between the messages before and after it.
+func workerBatches(batch []kafka.Message, workers int) [][][]kafka.Message {
+	if workers < 1 {
+		workers = 1
+	}
+	rounds := [][][]kafka.Message{}
+	current := make([][]kafka.Message, workers)
+	endRound := func() {
+		round := [][]kafka.Message{}
+		for i, messages := range current {
+			if len(messages) != 0 {
+				round = append(round, messages)
+				current[i] = nil
 			}
 		}
+		if len(round) != 0 {
+			rounds = append(rounds, round)
+		}
+	}
+
+	for _, m := range batch {
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(m.Value, &envelope)
+		if envelope.Type == events.ProductsDeletedForCompanyType || envelope.Type == events.ProductsArchivedForCompanyType {
+			endRound()
+			rounds = append(rounds, [][]kafka.Message{{m}})
+			continue
+		}
+
+		hash := fnv.New32a()
+		hash.Write(m.Key)
+		worker := hash.Sum32() % uint32(workers)
+		current[worker] = append(current[worker], m)
+	}
+	endRound()
+	return rounds
+}
+
+// applyBatch applies the events of batch to Elasticsearch and returns every
+// message with the error it failed with, if any. The span of every item is
+// left open.
+func (consumer *KafkaConsumer) applyBatch(batch []kafka.Message) []*batchItem {
+	items := make([]*batchItem, len(batch))
+	for i, m := range batch {
+		items[i] = consumer.startItem(m)
+	}
+
+	// Bulk operations are collected until an event needs a request of its
+	// own, so that every change is applied in the order of the messages.
+	var pending []*batchItem
+	var operations []elasticsearch_helpers.BulkOperation
+	flush := func() {
+		consumer.bulk(pending, operations)
+		pending, operations = nil, nil
+	}
+
+	companies, companiesErr := consumer.indexableCompanies(items)
+	index := func(item *batchItem, product models.Product, version int64) *elasticsearch_helpers.BulkOperation {
+		if companiesErr != nil {
+			item.logger.WithError(companiesErr).Error("Unable to look up the companies of the batch")
+			item.err = companiesErr
+			return nil
+		}
+		if companies != nil && !companies[product.IDC] {
+			item.logger.Debug("Skipped event of a deleted or archived company")
+			metrics.ObserveKafkaSkip(item.message.Topic, "company")
+			consumer.Processed.Add(item.envelope.ID)
+			return nil
+		}
+		operation, err := indexOperation(product, version)
+		item.err = err
+		return operation
+	}
+
+	for _, item := range items {
+		_, event, err := events.Unmarshal(item.message.Value)
+		if err != nil {
+			item.logger.WithError(err).Error("Unable to decode Kafka message")
+			item.err = err
+			continue
+		}
+		if consumer.Processed.Contains(item.envelope.ID) {
+			item.logger.Debug("Skipped duplicate event")
+			metrics.ObserveKafkaSkip(item.message.Topic, "duplicate")
+			continue
+		}
+
+		var operation *elasticsearch_helpers.BulkOperation
+		switch event := event.(type) {
+		case *events.ProductCreated:
+			operation = index(item, event.Product, event.Version)
+		case *events.ProductUpdated:
+			operation = index(item, event.Product, event.Version)
+		case *events.ProductDeleted:
+			operation = &elasticsearch_helpers.BulkOperation{Action: elasticsearch_helpers.BulkDelete, ID: event.ID, Version: event.Version}
+		case *events.ProductsDeletedForCompany:
+			flush()
+			consumer.finishOperation(item, consumer.Index.DeleteCompanyDocuments(item.ctx, event.CompanyID))
+			continue
+		case *events.ProductsArchivedForCompany:
+			// The products come back as ProductUpdated events with newer
+			// versions once the company is restored.
+			flush()
+			consumer.finishOperation(item, consumer.Index.DeleteCompanyDocuments(item.ctx, event.CompanyID))
+			continue
+		default:
+			consumer.Processed.Add(item.envelope.ID)
+			continue
+		}
+		if operation != nil {
+			pending = append(pending, item)
+			operations = append(operations, *operation)
+		}
+	}
+	flush()
 
-		err = consumer.Reader.CommitMessages(context.Background(), m)
+	return items
+}
+
+// indexableCompanies looks up which of the companies whose products items
+// create or update may be indexed, with one call of Companies. The map is
+// nil without Companies.
+func (consumer *KafkaConsumer) indexableCompanies(items []*batchItem) (map[string]bool, error) {
+	if consumer.Companies == nil {
+		return nil, nil
+	}
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		_, event, err := events.Unmarshal(item.message.Value)
 		if err != nil {
-			log.Println("Failed to commit message")
 			continue
 		}
+		var idc string
+		switch event := event.(type) {
+		case *events.ProductCreated:
+			idc = event.Product.IDC
+		case *events.ProductUpdated:
+			idc = event.Product.IDC
+		default:
+			continue
+		}
+		if !seen[idc] {
+			seen[idc] = true
+			ids = append(ids, idc)
+		}
+	}
+	if len(ids) == 0 {
+		return map[string]bool{}, nil
+	}
+	return consumer.Companies(context.Background(), ids)
+}
+
+func (consumer *KafkaConsumer) startItem(m kafka.Message) *batchItem {
+	ctx, span := tracing.Tracer().Start(messageContext(m), "kafka.consume "+m.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(m.Topic),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingMessageIDKey.String(string(m.Key)),
+		),
+	)
+	item := &batchItem{message: m, ctx: ctx, span: span}
+	// The envelope is decoded on its own so that the event ID and type are
+	// logged even when the payload cannot be decoded.
+	json.Unmarshal(m.Value, &item.envelope)
+	item.logger = consumer.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"topic":      m.Topic,
+		"offset":     m.Offset,
+		"key":        string(m.Key),
+		"attempts":   MessageAttempts(m),
+		"event_id":   item.envelope.ID,
+		"event_type": item.envelope.Type,
+	})
+	item.logger.Debug("Received message")
+	return item
+}
+
+// bulk applies operations and records the result of every operation on its
+// item. If the request as a whole fails, every item has failed.
+func (consumer *KafkaConsumer) bulk(items []*batchItem, operations []elasticsearch_helpers.BulkOperation) {
+	if len(operations) == 0 {
+		return
+	}
 
+	results, err := consumer.Index.Bulk(context.Background(), operations)
+	for i, item := range items {
+		if err != nil {
+			consumer.finishOperation(item, err)
+		} else {
+			consumer.finishOperation(item, results[i])
+		}
 	}
 }
 
-func (consumer *KafkaConsumer) resolveError(producer KafkaProducer, message kafka.Message) {
-	if consumer.Reader.Stats().Topic != "retry" {
-		writeToRetry(producer, message)
+func (consumer *KafkaConsumer) finishOperation(item *batchItem, err error) {
+	if errors.Is(err, elasticsearch_helpers.ErrVersionConflict) {
+		// A newer change of the product has been applied already.
+		item.logger.Debug("Skipped stale event")
+		metrics.ObserveKafkaSkip(item.message.Topic, "stale")
+		err = nil
 	}
-	err := consumer.Reader.CommitMessages(context.Background(), message)
 	if err != nil {
-		log.Println("Failed to commit message")
+		item.logger.WithError(err).Error("Error while updating Elasticsearch document")
+		item.err = err
+		return
+	}
+	consumer.Processed.Add(item.envelope.ID)
+}
+
+// retry hands a failed message to the retry path. It keeps trying until that
+// succeeds, so that the offset of the batch is never committed past a message
+// that was neither applied nor rescheduled.
+func (consumer *KafkaConsumer) retry(item *batchItem) {
+	for {
+		err := consumer.Retries.Route(item.ctx, item.message, item.err)
+		if err == nil {
+			return
+		}
+		item.logger.WithError(err).Error("Unable to schedule message for retry")
+		time.Sleep(time.Second)
 	}
 }
 
-func writeToRetry(producer KafkaProducer, message kafka.Message) {
-	err := producer.WriteMessage(string(message.Value), string(message.Key))
+func indexOperation(product models.Product, version int64) (*elasticsearch_helpers.BulkOperation, error) {
+	body, err := elasticsearch_helpers.ProductDocument(product)
 	if err != nil {
-		fmt.Println("Failed to write message to retry topic")
-	} else {
-		fmt.Println("Successfuly written to retry topic")
+		return nil, err
 	}
+	return &elasticsearch_helpers.BulkOperation{Action: elasticsearch_helpers.BulkIndex, ID: product.ID, Body: body, Version: version}, nil
 }
@@ -0,0 +1,210 @@
+package kafka_helpers
+
+import (
+	"context"
+	"fmt"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
+	"internship_project/events"
+	"internship_project/logging"
+	"internship_project/models"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventMessage(event events.Event) kafka.Message {
+	value, _ := events.Marshal(context.Background(), event)
+	return kafka.Message{Topic: "ava-internship", Value: value}
+}
+
+func TestApplyBatch(t *testing.T) {
+	assert := assert.New(t)
+	product := models.Product{ID: "p1", IDC: "c1", Name: "Milk", Price: 1, Quantity: 2}
+	updated := product
+	updated.Name = "Fresh milk"
+
+	newConsumer := func(index elasticsearch_helpers.SearchIndex) KafkaConsumer {
+		return KafkaConsumer{
+			Index:     index,
+			Config:    config.KafkaConfig{BatchSize: 10},
+			Processed: NewProcessedEvents(10),
+			Logger:    logging.Component(logging.Discard(), "kafkaConsumer"),
+		}
+	}
+
+	t.Run("events are applied in order", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+
+		items := consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			eventMessage(&events.ProductUpdated{Product: updated, Version: 2}),
+		})
+		for _, item := range items {
+			assert.NoError(item.err)
+		}
+
+		indexed, ok := index.Get("p1")
+		assert.True(ok)
+		assert.Equal("Fresh milk", indexed.Name)
+		assert.Equal(int64(2), index.Version("p1"))
+	})
+
+	t.Run("stale and duplicate events are skipped", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+		update := eventMessage(&events.ProductUpdated{Product: updated, Version: 2})
+
+		consumer.applyBatch([]kafka.Message{update})
+		items := consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			update,
+		})
+		for _, item := range items {
+			assert.NoError(item.err)
+		}
+
+		indexed, _ := index.Get("p1")
+		assert.Equal("Fresh milk", indexed.Name)
+	})
+
+	t.Run("company deletion is applied between the other events", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+		other := models.Product{ID: "p2", IDC: "c1", Name: "Bread"}
+
+		consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			eventMessage(&events.ProductsDeletedForCompany{CompanyID: "c1"}),
+			eventMessage(&events.ProductCreated{Product: other, Version: 1}),
+		})
+
+		_, ok := index.Get("p1")
+		assert.False(ok)
+		_, ok = index.Get("p2")
+		assert.True(ok)
+	})
+
+	t.Run("archiving a company removes its products until they are published again", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+
+		consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			eventMessage(&events.ProductsArchivedForCompany{CompanyID: "c1"}),
+		})
+		_, ok := index.Get("p1")
+		assert.False(ok)
+
+		items := consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductUpdated{Product: product, Version: 3}),
+		})
+		assert.NoError(items[0].err)
+		_, ok = index.Get("p1")
+		assert.True(ok)
+	})
+
+	t.Run("replayed events of a deleted company are skipped", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+		other := models.Product{ID: "p2", IDC: "c2", Name: "Bread"}
+		var looked [][]string
+		consumer.Companies = func(ctx context.Context, ids []string) (map[string]bool, error) {
+			looked = append(looked, ids)
+			return map[string]bool{"c2": true}, nil
+		}
+
+		items := consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			eventMessage(&events.ProductCreated{Product: other, Version: 1}),
+			eventMessage(&events.ProductUpdated{Product: updated, Version: 2}),
+		})
+		for _, item := range items {
+			assert.NoError(item.err)
+		}
+
+		assert.Equal([][]string{{"c1", "c2"}}, looked)
+		_, ok := index.Get("p1")
+		assert.False(ok)
+		_, ok = index.Get("p2")
+		assert.True(ok)
+	})
+
+	t.Run("a failed company lookup fails the products of the batch", func(t *testing.T) {
+		index := elasticsearch_helpers.NewMemoryIndex()
+		consumer := newConsumer(index)
+		consumer.Companies = func(ctx context.Context, ids []string) (map[string]bool, error) {
+			return nil, fmt.Errorf("Database is down")
+		}
+
+		items := consumer.applyBatch([]kafka.Message{
+			eventMessage(&events.ProductCreated{Product: product, Version: 1}),
+			eventMessage(&events.ProductsDeletedForCompany{CompanyID: "c1"}),
+		})
+		assert.Error(items[0].err)
+		assert.NoError(items[1].err)
+		_, ok := index.Get("p1")
+		assert.False(ok)
+	})
+
+	t.Run("undecodable messages fail", func(t *testing.T) {
+		consumer := newConsumer(elasticsearch_helpers.NewMemoryIndex())
+		items := consumer.applyBatch([]kafka.Message{{Value: []byte("not json")}})
+		assert.Error(items[0].err)
+	})
+}
+
+func TestWorkerBatches(t *testing.T) {
+	assert := assert.New(t)
+	keyed := func(key string, event events.Event) kafka.Message {
+		m := eventMessage(event)
+		m.Key = []byte(key)
+		return m
+	}
+	keys := func(messages []kafka.Message) []string {
+		result := []string{}
+		for _, m := range messages {
+			result = append(result, string(m.Key))
+		}
+		return result
+	}
+
+	t.Run("the messages of a key stay together in order", func(t *testing.T) {
+		batch := []kafka.Message{}
+		for i := 0; i < 20; i++ {
+			batch = append(batch, keyed(fmt.Sprint("p", i%5), &events.ProductDeleted{ID: fmt.Sprint(i)}))
+		}
+
+		rounds := workerBatches(batch, 3)
+		assert.Len(rounds, 1)
+		assert.True(len(rounds[0]) <= 3)
+		count := 0
+		for _, messages := range rounds[0] {
+			count += len(messages)
+			positions := map[string]int{}
+			for _, m := range messages {
+				_, event, _ := events.Unmarshal(m.Value)
+				position := 0
+				fmt.Sscan(event.(*events.ProductDeleted).ID, &position)
+				assert.True(position >= positions[string(m.Key)], "messages of a key are out of order")
+				positions[string(m.Key)] = position
+			}
+		}
+		assert.Equal(20, count)
+	})
+
+	t.Run("company deletions are a round of their own", func(t *testing.T) {
+		rounds := workerBatches([]kafka.Message{
+			keyed("p1", &events.ProductDeleted{ID: "p1"}),
+			keyed("c1", &events.ProductsDeletedForCompany{CompanyID: "c1"}),
+			keyed("p2", &events.ProductDeleted{ID: "p2"}),
+		}, 4)
+
+		assert.Len(rounds, 3)
+		assert.Equal([]string{"p1"}, keys(rounds[0][0]))
+		assert.Equal([]string{"c1"}, keys(rounds[1][0]))
+		assert.Equal([]string{"p2"}, keys(rounds[2][0]))
+	})
+}
@@ -0,0 +1,191 @@
+package kafka_helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/models"
+	"internship_project/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// deadLetterReadTimeout is how long reading the dead-letter topic waits for
+// the next message below the high-water mark. The offsets up to it can all be
+// gone, removed by compaction or taken by transaction control records.
+var deadLetterReadTimeout = 5 * time.Second
+
+// DeadLetterQueue reads the dead-letter topic and replays its messages to
+// the main topic.
+type DeadLetterQueue struct {
+	Config   config.KafkaConfig
+	Broker   Broker
+	Replayer *KafkaProducer
+}
+
+func NewDeadLetterQueue(conf config.KafkaConfig, broker Broker, logger *logrus.Logger) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		Config:   conf,
+		Broker:   broker,
+		Replayer: NewProducer(broker, conf.MainTopic, logger),
+	}
+}
+
+// DeadLetterID returns the ID of the dead letter at offset of partition.
+func DeadLetterID(partition int, offset int64) string {
+	return fmt.Sprintf("%d-%d", partition, offset)
+}
+
+// ParseDeadLetterID returns the partition and the offset of a dead letter.
+func ParseDeadLetterID(id string) (int, int64, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid dead letter ID %q, expected <partition>-<offset>", id)
+	}
+	partition, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid dead letter ID %q, expected <partition>-<offset>", id)
+	}
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid dead letter ID %q, expected <partition>-<offset>", id)
+	}
+	return partition, offset, nil
+}
+
+// Messages returns every message on the dead-letter topic.
+func (queue *DeadLetterQueue) Messages(ctx context.Context) ([]models.DeadLetter, error) {
+	offsets, err := queue.Broker.Offsets(ctx, queue.Config.DeadLetterTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := []models.DeadLetter{}
+	for _, partition := range offsets {
+		if partition.FirstOffset >= partition.LastOffset {
+			continue
+		}
+
+		err := queue.read(ctx, partition.Partition, partition.FirstOffset, partition.LastOffset, func(m kafka.Message) bool {
+			deadLetters = append(deadLetters, toDeadLetter(m))
+			return m.Offset < partition.LastOffset-1
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deadLetters, nil
+}
+
+// Message returns the dead letter with id.
+func (queue *DeadLetterQueue) Message(ctx context.Context, id string) (models.DeadLetter, error) {
+	partition, offset, err := ParseDeadLetterID(id)
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+
+	offsets, err := queue.Broker.Offsets(ctx, queue.Config.DeadLetterTopic)
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+
+	for _, p := range offsets {
+		if p.Partition != partition || offset < p.FirstOffset || offset >= p.LastOffset {
+			continue
+		}
+
+		var deadLetter models.DeadLetter
+		err := queue.read(ctx, partition, offset, p.LastOffset, func(m kafka.Message) bool {
+			if m.Offset == offset {
+				deadLetter = toDeadLetter(m)
+			}
+			return false
+		})
+		if err != nil {
+			return deadLetter, err
+		}
+		if deadLetter.ID == "" {
+			// Compaction removed the message.
+			break
+		}
+		return deadLetter, nil
+	}
+	return models.DeadLetter{}, utils.NoDataError
+}
+
+// Replay writes the dead letter to the main topic again. It goes through
+// every retry tier again if it keeps failing.
+func (queue *DeadLetterQueue) Replay(ctx context.Context, deadLetter models.DeadLetter) error {
+	return queue.Replayer.WriteMessage(ctx, deadLetter.Value, deadLetter.Key)
+}
+
+func (queue *DeadLetterQueue) Close() error {
+	return queue.Replayer.Writer.Close()
+}
+
+// read reads partition from offset on and hands every message to next until
+// next returns false or the high-water mark end is reached. It also stops
+// when no message arrives for deadLetterReadTimeout, since the offsets left
+// below end may never be delivered.
+func (queue *DeadLetterQueue) read(ctx context.Context, partition int, offset int64, end int64, next func(kafka.Message) bool) error {
+	reader, err := queue.Broker.PartitionReader(queue.Config.DeadLetterTopic, partition, offset)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, deadLetterReadTimeout)
+		m, err := reader.ReadMessage(readCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Offset >= end || !next(m) {
+			return nil
+		}
+	}
+}
+
+func toDeadLetter(m kafka.Message) models.DeadLetter {
+	carrier := headerCarrier(m.Headers)
+
+	originalPartition, err := strconv.Atoi(carrier.Get(OriginalPartitionHeader))
+	if err != nil {
+		originalPartition = -1
+	}
+	originalOffset, err := strconv.ParseInt(carrier.Get(OriginalOffsetHeader), 10, 64)
+	if err != nil {
+		originalOffset = -1
+	}
+
+	deadLetter := models.DeadLetter{
+		ID:                DeadLetterID(m.Partition, m.Offset),
+		Partition:         m.Partition,
+		Offset:            m.Offset,
+		Key:               string(m.Key),
+		Error:             carrier.Get(ErrorHeader),
+		Attempts:          MessageAttempts(m),
+		OriginalTopic:     carrier.Get(OriginalTopicHeader),
+		OriginalPartition: originalPartition,
+		OriginalOffset:    originalOffset,
+		FailedAt:          m.Time,
+		Value:             string(m.Value),
+	}
+	// The type is shown even for events this version cannot decode.
+	var envelope events.Envelope
+	if err := json.Unmarshal(m.Value, &envelope); err == nil {
+		deadLetter.EventType = envelope.Type
+	}
+	return deadLetter
+}
@@ -0,0 +1,101 @@
+package kafka_helpers
+
+import (
+	"context"
+	"internship_project/config"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeadLetterID(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("valid ID", func(t *testing.T) {
+		partition, offset, err := ParseDeadLetterID(DeadLetterID(2, 41))
+		assert.NoError(err)
+		assert.Equal(2, partition)
+		assert.Equal(int64(41), offset)
+	})
+
+	t.Run("invalid IDs", func(t *testing.T) {
+		for _, id := range []string{"", "2", "a-41", "2-b"} {
+			_, _, err := ParseDeadLetterID(id)
+			assert.Error(err, id)
+		}
+	})
+}
+
+func TestToDeadLetter(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("failure is read from the headers", func(t *testing.T) {
+		deadLetter := toDeadLetter(kafka.Message{
+			Partition: 1,
+			Offset:    7,
+			Key:       []byte("key"),
+			Value:     []byte(`{"type":"product.created","schema_version":"9.0"}`),
+			Headers: []kafka.Header{
+				{Key: AttemptsHeader, Value: []byte("4")},
+				{Key: ErrorHeader, Value: []byte("elasticsearch unavailable")},
+				{Key: OriginalTopicHeader, Value: []byte("ava-internship")},
+				{Key: OriginalPartitionHeader, Value: []byte("0")},
+				{Key: OriginalOffsetHeader, Value: []byte("12")},
+			},
+		})
+
+		assert.Equal("1-7", deadLetter.ID)
+		assert.Equal("key", deadLetter.Key)
+		assert.Equal("product.created", deadLetter.EventType)
+		assert.Equal("elasticsearch unavailable", deadLetter.Error)
+		assert.Equal(4, deadLetter.Attempts)
+		assert.Equal("ava-internship", deadLetter.OriginalTopic)
+		assert.Equal(0, deadLetter.OriginalPartition)
+		assert.Equal(int64(12), deadLetter.OriginalOffset)
+	})
+
+	t.Run("unknown original offset", func(t *testing.T) {
+		deadLetter := toDeadLetter(kafka.Message{Value: []byte("not json")})
+		assert.Equal(-1, deadLetter.OriginalPartition)
+		assert.Equal(int64(-1), deadLetter.OriginalOffset)
+		assert.Empty(deadLetter.EventType)
+	})
+}
+
+// controlRecordBroker reports a high-water mark one past the last message of
+// every partition, as if a transaction control record followed it.
+type controlRecordBroker struct {
+	*MemoryBroker
+}
+
+func (broker controlRecordBroker) Offsets(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error) {
+	offsets, err := broker.MemoryBroker.Offsets(ctx, topic)
+	for i := range offsets {
+		offsets[i].LastOffset++
+	}
+	return offsets, err
+}
+
+func TestDeadLetterQueueMessages(t *testing.T) {
+	assert := assert.New(t)
+	timeout := deadLetterReadTimeout
+	deadLetterReadTimeout = 50 * time.Millisecond
+	defer func() { deadLetterReadTimeout = timeout }()
+
+	t.Run("offsets that are never delivered end the read", func(t *testing.T) {
+		broker := controlRecordBroker{NewMemoryBroker(1)}
+		conf := config.KafkaConfig{DeadLetterTopic: "dead-letter"}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		broker.Publisher(conf.DeadLetterTopic).WriteMessages(ctx, kafka.Message{Value: []byte("1")}, kafka.Message{Value: []byte("2")})
+		queue := DeadLetterQueue{Config: conf, Broker: broker}
+
+		deadLetters, err := queue.Messages(ctx)
+
+		assert.NoError(err)
+		assert.Len(deadLetters, 2)
+		assert.NoError(ctx.Err(), "Read waited for the request to time out")
+	})
+}
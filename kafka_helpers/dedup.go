@@ -0,0 +1,45 @@
+package kafka_helpers
+
+import "sync"
+
+// ProcessedEvents remembers the IDs of the last processed events, so that an
+// event delivered twice is only applied once. It is shared by the consumers
+// of the main topic and of the retry tiers.
+type ProcessedEvents struct {
+	mu    sync.Mutex
+	ids   map[string]struct{}
+	order []string
+	next  int
+}
+
+// NewProcessedEvents remembers up to size event IDs, forgetting the oldest
+// first.
+func NewProcessedEvents(size int) *ProcessedEvents {
+	return &ProcessedEvents{
+		ids:   make(map[string]struct{}, size),
+		order: make([]string, size),
+	}
+}
+
+func (processed *ProcessedEvents) Contains(id string) bool {
+	processed.mu.Lock()
+	defer processed.mu.Unlock()
+
+	_, ok := processed.ids[id]
+	return ok
+}
+
+func (processed *ProcessedEvents) Add(id string) {
+	processed.mu.Lock()
+	defer processed.mu.Unlock()
+
+	if _, ok := processed.ids[id]; ok || len(processed.order) == 0 {
+		return
+	}
+	if oldest := processed.order[processed.next]; oldest != "" {
+		delete(processed.ids, oldest)
+	}
+	processed.order[processed.next] = id
+	processed.ids[id] = struct{}{}
+	processed.next = (processed.next + 1) % len(processed.order)
+}
@@ -0,0 +1,31 @@
+package kafka_helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("added events are remembered", func(t *testing.T) {
+		processed := NewProcessedEvents(2)
+		assert.False(processed.Contains("1"))
+
+		processed.Add("1")
+		assert.True(processed.Contains("1"))
+	})
+
+	t.Run("oldest events are forgotten first", func(t *testing.T) {
+		processed := NewProcessedEvents(2)
+		processed.Add("1")
+		processed.Add("2")
+		processed.Add("1")
+		processed.Add("3")
+
+		assert.False(processed.Contains("1"))
+		assert.True(processed.Contains("2"))
+		assert.True(processed.Contains("3"))
+	})
+}
@@ -0,0 +1,96 @@
+package kafka_helpers
+
+import (
+	"context"
+	"internship_project/events"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// EventBatch holds events until the transaction that produced them has
+// committed, and then writes them together, one write per topic.
+type EventBatch struct {
+	mu        sync.Mutex
+	producers []*KafkaProducer
+	messages  map[*KafkaProducer][]kafka.Message
+}
+
+type eventBatchKey struct{}
+
+func NewEventBatch() *EventBatch {
+	return &EventBatch{messages: map[*KafkaProducer][]kafka.Message{}}
+}
+
+// WithEventBatch makes EventPublisher.Publish add the events published with
+// the returned context to batch instead of writing them.
+func WithEventBatch(ctx context.Context, batch *EventBatch) context.Context {
+	return context.WithValue(ctx, eventBatchKey{}, batch)
+}
+
+// EventBatchFrom returns the batch of ctx, or nil.
+func EventBatchFrom(ctx context.Context) *EventBatch {
+	batch, _ := ctx.Value(eventBatchKey{}).(*EventBatch)
+	return batch
+}
+
+// add marshals event right away, so that it keeps the actor, event ID and
+// request of ctx.
+func (batch *EventBatch) add(ctx context.Context, producer *KafkaProducer, key string, event events.Event) error {
+	value, err := events.Marshal(ctx, event)
+	if err != nil {
+		return err
+	}
+	batch.append(producer, kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: messageHeaders(ctx),
+	})
+	return nil
+}
+
+func (batch *EventBatch) append(producer *KafkaProducer, messages ...kafka.Message) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if _, ok := batch.messages[producer]; !ok {
+		batch.producers = append(batch.producers, producer)
+	}
+	batch.messages[producer] = append(batch.messages[producer], messages...)
+}
+
+// Merge moves the events of other into batch, e.g. those of a nested unit
+// of work into the outer one.
+func (batch *EventBatch) Merge(other *EventBatch) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	for _, producer := range other.producers {
+		batch.append(producer, other.messages[producer]...)
+	}
+	other.producers = nil
+	other.messages = map[*KafkaProducer][]kafka.Message{}
+}
+
+// Len is the number of events in the batch.
+func (batch *EventBatch) Len() int {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	n := 0
+	for _, messages := range batch.messages {
+		n += len(messages)
+	}
+	return n
+}
+
+// Publish writes the events in the order they were added, with one write
+// per topic.
+func (batch *EventBatch) Publish(ctx context.Context) error {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	for _, producer := range batch.producers {
+		err := producer.ForwardBatch(ctx, batch.messages[producer])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package kafka_helpers
+
+import (
+	"context"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/logging"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	conf := config.Default().Kafka
+
+	read := func(broker *MemoryBroker, topic string) []events.Envelope {
+		offsets, err := broker.Offsets(ctx, topic)
+		assert.NoError(err)
+		reader, err := broker.PartitionReader(topic, 0, 0)
+		assert.NoError(err)
+		envelopes := []events.Envelope{}
+		for i := int64(0); i < offsets[0].LastOffset; i++ {
+			message, err := reader.ReadMessage(ctx)
+			assert.NoError(err)
+			envelope, _, err := events.Unmarshal(message.Value)
+			assert.NoError(err)
+			envelopes = append(envelopes, envelope)
+		}
+		return envelopes
+	}
+
+	t.Run("events are written when the batch is published", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+		batch := NewEventBatch()
+		batchCtx := WithEventBatch(events.WithActor(ctx, "employee-1", "company-1"), batch)
+
+		assert.NoError(publisher.Publish(batchCtx, "product-1", &events.ProductDeleted{ID: "product-1"}))
+		assert.NoError(publisher.Publish(batchCtx, "product-2", &events.ProductDeleted{ID: "product-2"}))
+		assert.NoError(publisher.Publish(batchCtx, "company-1", &events.CompanyDeleted{ID: "company-1"}))
+
+		assert.Equal(3, batch.Len())
+		assert.Empty(read(broker, conf.MainTopic))
+
+		assert.NoError(batch.Publish(ctx))
+
+		products := read(broker, conf.MainTopic)
+		assert.Len(products, 2)
+		assert.Equal("employee-1", products[0].Actor)
+		assert.Equal("company-1", products[1].Tenant)
+		assert.Len(read(broker, conf.CompanyTopic), 1)
+	})
+
+	t.Run("a dropped batch writes nothing", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+
+		batchCtx := WithEventBatch(ctx, NewEventBatch())
+		assert.NoError(publisher.Publish(batchCtx, "product-1", &events.ProductDeleted{ID: "product-1"}))
+
+		assert.Empty(read(broker, conf.MainTopic))
+	})
+
+	t.Run("skipped aggregates are not added", func(t *testing.T) {
+		publisher := NewEventPublisher(conf, NewMemoryBroker(1), logging.Discard()).Without(events.ProductAggregate)
+		batch := NewEventBatch()
+
+		assert.NoError(publisher.Publish(WithEventBatch(ctx, batch), "product-1", &events.ProductDeleted{ID: "product-1"}))
+
+		assert.Equal(0, batch.Len())
+	})
+
+	t.Run("merging moves the events", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+		outer := NewEventBatch()
+		inner := NewEventBatch()
+		assert.NoError(publisher.Publish(WithEventBatch(ctx, outer), "product-1", &events.ProductDeleted{ID: "product-1"}))
+		assert.NoError(publisher.Publish(WithEventBatch(ctx, inner), "product-2", &events.ProductDeleted{ID: "product-2"}))
+
+		outer.Merge(inner)
+
+		assert.Equal(0, inner.Len())
+		assert.NoError(outer.Publish(ctx))
+		assert.Len(read(broker, conf.MainTopic), 2)
+	})
+}
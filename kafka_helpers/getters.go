@@ -1,56 +1,123 @@
 package kafka_helpers
 
 import (
+	"internship_project/config"
 	"internship_project/elasticsearch_helpers"
+	"internship_project/logging"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 )
 
-func NewConsumer(topicName string, address string, groupId string, EsClient elasticsearch_helpers.ElasticsearchClient, miliseconds int) KafkaConsumer {
-	r := GetReader(topicName, address, groupId, miliseconds)
-
-	r.SetOffset(kafka.LastOffset)
-
+func NewConsumer(conf config.KafkaConfig, broker Broker, index elasticsearch_helpers.SearchIndex, companies CompanyFilter, retries *RetryRouter, processed *ProcessedEvents, logger *logrus.Logger) KafkaConsumer {
 	consumer := KafkaConsumer{
-		Reader:   r,
-		EsClient: EsClient,
+		Reader:    broker.Subscriber(conf.GroupID, conf.MainTopic, time.Duration(conf.MainTopicTime)*time.Millisecond),
+		Broker:    broker,
+		Topic:     conf.MainTopic,
+		GroupID:   conf.GroupID,
+		Index:     index,
+		Companies: companies,
+		Config:    conf,
+		Retries:   retries,
+		Processed: processed,
+		Logger:    logging.Component(logger, "kafkaConsumer"),
 	}
 
 	return consumer
 }
 
-func GetReader(topicName string, address string, groupId string, miliseconds int) *kafka.Reader {
+// NewRetryConsumers returns a consumer for every retry tier. Each tier has
+// its own consumer group, so that a tier waiting for its next message to be
+// due does not hold back the others.
+func NewRetryConsumers(conf config.KafkaConfig, broker Broker, index elasticsearch_helpers.SearchIndex, companies CompanyFilter, retries *RetryRouter, processed *ProcessedEvents, logger *logrus.Logger) []KafkaConsumer {
+	consumers := []KafkaConsumer{}
+	for _, topic := range RetryTopics(conf) {
+		groupID := RetryGroupID(conf, topic)
+		consumers = append(consumers, KafkaConsumer{
+			Reader:    broker.Subscriber(groupID, topic, time.Duration(conf.RetryTopicTime)*time.Millisecond),
+			Broker:    broker,
+			Topic:     topic,
+			GroupID:   groupID,
+			Index:     index,
+			Companies: companies,
+			Config:    conf,
+			Retries:   retries,
+			Processed: processed,
+			Delayed:   true,
+			Logger:    logging.Component(logger, "kafkaRetryConsumer"),
+		})
+	}
+	return consumers
+}
+
+// RetryGroupID returns the consumer group of a retry tier topic.
+func RetryGroupID(conf config.KafkaConfig, topic string) string {
+	return conf.GroupID + "-" + topic
+}
+
+// GetReader joins the consumer group groupID, which assigns the partitions
+// of topicName among its members. A member continues at the offsets the group
+// committed. A new group starts at the first message, so that nothing written
+// before the consumer first started is skipped.
+func GetReader(conf config.KafkaConfig, dialer *kafka.Dialer, groupID string, topicName string, miliseconds int) *kafka.Reader {
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:   []string{address},
-		GroupID:   groupId,
-		Topic:     topicName,
-		Partition: 0,
-		MinBytes:  10e2, // 10KB
-		MaxBytes:  10e6, // 10MB
-		MaxWait:   time.Duration(miliseconds) * time.Millisecond,
+		Brokers:     conf.Brokers,
+		Dialer:      dialer,
+		GroupID:     groupID,
+		Topic:       topicName,
+		StartOffset: kafka.FirstOffset,
+		MinBytes:    10e2, // 10KB
+		MaxBytes:    10e6, // 10MB
+		MaxWait:     time.Duration(miliseconds) * time.Millisecond,
 	})
 
 	return r
 }
 
-func GetWriter(topicName string) *kafka.Writer {
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  []string{"localhost:9092"},
-		Topic:    topicName,
-		Balancer: &kafka.LeastBytes{},
-	})
+// GetWriter writes messages with the same key to the same partition, so that
+// the events of a product are consumed in order. Every write waits for the
+// acknowledgements of kafka.required_acks and is tried kafka.write_attempts
+// times.
+func GetWriter(conf config.KafkaConfig, transport *kafka.Transport, topicName string) *kafka.Writer {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(conf.Brokers...),
+		Topic:        topicName,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  conf.WriteAttempts,
+		BatchSize:    conf.WriteBatchSize,
+		BatchTimeout: time.Duration(conf.WriteBatchTimeout) * time.Millisecond,
+		RequiredAcks: requiredAcks(conf.RequiredAcks),
+		Compression:  compression(conf.Compression),
+		Transport:    transport,
+	}
 
 	return w
 }
 
-func GetRetryHandler(retryTopic string, mainTopic string, address string, groupId string, miliseconds int) *RetryHandler {
-	handler := &RetryHandler{
-		Reader: GetReader(retryTopic, address, groupId, miliseconds),
-		Writer: &KafkaProducer{
-			Writer: GetWriter(mainTopic),
-		},
+func requiredAcks(acks string) kafka.RequiredAcks {
+	switch acks {
+	case "none":
+		return kafka.RequireNone
+	case "one":
+		return kafka.RequireOne
+	default:
+		return kafka.RequireAll
 	}
+}
 
-	return handler
+// compression returns the codec called name, or no compression.
+func compression(name string) kafka.Compression {
+	switch name {
+	case "gzip":
+		return kafka.Gzip
+	case "snappy":
+		return kafka.Snappy
+	case "lz4":
+		return kafka.Lz4
+	case "zstd":
+		return kafka.Zstd
+	default:
+		return 0
+	}
 }
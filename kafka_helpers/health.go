@@ -0,0 +1,19 @@
+package kafka_helpers
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckLag reports an error when the consumer is more than maxLag messages
+// behind the end of its topic.
+func (consumer *KafkaConsumer) CheckLag(ctx context.Context, maxLag int64) error {
+	lag, err := TopicLag(ctx, consumer.Broker, consumer.GroupID, consumer.Topic)
+	if err != nil {
+		return err
+	}
+	if lag > maxLag {
+		return fmt.Errorf("consumer lag is %d messages, allowed at most %d", lag, maxLag)
+	}
+	return nil
+}
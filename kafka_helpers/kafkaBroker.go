@@ -0,0 +1,209 @@
+package kafka_helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"io/ioutil"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// KafkaBroker is the Broker of the Kafka cluster in the configuration.
+// Readers and connections go through dialer, writers and the admin client
+// through transport, both with the TLS and SASL settings of the
+// configuration.
+type KafkaBroker struct {
+	Config    config.KafkaConfig
+	dialer    *kafka.Dialer
+	transport *kafka.Transport
+}
+
+// NewKafkaBroker fails if the certificates cannot be loaded or the SASL
+// mechanism cannot be set up.
+func NewKafkaBroker(conf config.KafkaConfig) (*KafkaBroker, error) {
+	tlsConfig, err := newTLSConfig(conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := newSASLMechanism(conf.SASL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaBroker{
+		Config: conf,
+		dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsConfig,
+			SASLMechanism: mechanism,
+		},
+		transport: &kafka.Transport{
+			TLS:  tlsConfig,
+			SASL: mechanism,
+		},
+	}, nil
+}
+
+// newTLSConfig returns nil when TLS is disabled.
+func newTLSConfig(conf config.KafkaTLSConfig) (*tls.Config, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+	if conf.CAFile != "" {
+		ca, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read the Kafka CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificates found in the Kafka CA file %s", conf.CAFile)
+		}
+	}
+	if conf.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load the Kafka client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// newSASLMechanism returns nil when no mechanism is configured.
+func newSASLMechanism(conf config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch conf.Mechanism {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: conf.Username, Password: conf.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, conf.Username, conf.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, conf.Username, conf.Password)
+	default:
+		return nil, fmt.Errorf("Unknown SASL mechanism %q", conf.Mechanism)
+	}
+}
+
+func (broker *KafkaBroker) Publisher(topic string) Publisher {
+	return GetWriter(broker.Config, broker.transport, topic)
+}
+
+func (broker *KafkaBroker) Subscriber(groupID string, topic string, maxWait time.Duration) Subscriber {
+	return GetReader(broker.Config, broker.dialer, groupID, topic, int(maxWait/time.Millisecond))
+}
+
+func (broker *KafkaBroker) PartitionReader(topic string, partition int, offset int64) (PartitionReader, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   broker.Config.Brokers,
+		Dialer:    broker.dialer,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6, // 10MB
+	})
+	if err := reader.SetOffset(offset); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (broker *KafkaBroker) Offsets(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error) {
+	return partitionOffsets(ctx, broker.client(), topic)
+}
+
+func (broker *KafkaBroker) OffsetAt(ctx context.Context, topic string, partition int, t time.Time) (int64, error) {
+	var lastErr error = errors.New("no Kafka brokers configured")
+	for _, address := range broker.Config.Brokers {
+		conn, err := broker.dialer.DialLeader(ctx, "tcp", address, topic, partition)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		offset, err := conn.ReadOffset(t)
+		conn.Close()
+		return offset, err
+	}
+	return 0, lastErr
+}
+
+func (broker *KafkaBroker) CommittedOffsets(ctx context.Context, groupID string, topic string) (map[int]int64, error) {
+	offsets, err := broker.Offsets(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	partitions := make([]int, 0, len(offsets))
+	for _, partition := range offsets {
+		partitions = append(partitions, partition.Partition)
+	}
+
+	committed, err := broker.client().OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if committed.Error != nil {
+		return nil, committed.Error
+	}
+
+	// A negative committed offset means the group never committed on the
+	// partition.
+	committedOffsets := make(map[int]int64, len(partitions))
+	for _, partition := range committed.Topics[topic] {
+		if partition.CommittedOffset >= 0 {
+			committedOffsets[partition.Partition] = partition.CommittedOffset
+		}
+	}
+	return committedOffsets, nil
+}
+
+// Ping succeeds when at least one of the configured brokers answers a
+// metadata request for topic.
+func (broker *KafkaBroker) Ping(ctx context.Context, topic string) error {
+	var lastErr error = errors.New("no Kafka brokers configured")
+
+	for _, address := range broker.Config.Brokers {
+		conn, err := broker.dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.ReadPartitions(topic)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return nil
+	}
+
+	return lastErr
+}
+
+func (broker *KafkaBroker) client() *kafka.Client {
+	return &kafka.Client{Addr: kafka.TCP(broker.Config.Brokers...), Transport: broker.transport}
+}
@@ -0,0 +1,62 @@
+package kafka_helpers
+
+import (
+	"internship_project/config"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKafkaBroker(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("plain connections by default", func(t *testing.T) {
+		broker, err := NewKafkaBroker(config.Default().Kafka)
+
+		assert.NoError(err)
+		assert.Nil(broker.dialer.TLS)
+		assert.Nil(broker.dialer.SASLMechanism)
+	})
+
+	t.Run("tls and sasl", func(t *testing.T) {
+		conf := config.Default().Kafka
+		conf.TLS.Enabled = true
+		conf.SASL = config.KafkaSASLConfig{Mechanism: "scram-sha-512", Username: "ava", Password: "secret"}
+
+		broker, err := NewKafkaBroker(conf)
+
+		assert.NoError(err)
+		assert.NotNil(broker.dialer.TLS)
+		assert.Equal(broker.dialer.TLS, broker.transport.TLS)
+		assert.Equal("SCRAM-SHA-512", broker.transport.SASL.Name())
+	})
+
+	t.Run("missing ca file", func(t *testing.T) {
+		conf := config.Default().Kafka
+		conf.TLS.Enabled = true
+		conf.TLS.CAFile = "does-not-exist.pem"
+
+		_, err := NewKafkaBroker(conf)
+
+		assert.Error(err)
+	})
+}
+
+func TestGetWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := config.Default().Kafka
+	conf.RequiredAcks = "one"
+	conf.Compression = "zstd"
+
+	writer := GetWriter(conf, &kafka.Transport{}, conf.MainTopic)
+
+	assert.IsType(&kafka.Hash{}, writer.Balancer)
+	assert.Equal(kafka.RequireOne, writer.RequiredAcks)
+	assert.Equal(kafka.Zstd, writer.Compression)
+	assert.Equal(conf.WriteAttempts, writer.MaxAttempts)
+	assert.Equal(conf.WriteBatchSize, writer.BatchSize)
+	assert.Equal(time.Duration(conf.WriteBatchTimeout)*time.Millisecond, writer.BatchTimeout)
+}
@@ -0,0 +1,331 @@
+package kafka_helpers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MemoryBroker is a Broker that keeps every topic in memory, so that the
+// producers, the consumers and the retry tiers can run in tests without
+// Kafka. Topics are created on first use. Messages with a key go to the
+// partition of the key's hash, the others round robin. The members of a
+// consumer group split the partitions of its topic among themselves and
+// start at the offsets the group committed, or at the first message.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string]*memoryTopic
+	groups     map[memoryGroupKey]*memoryGroup
+	// changed is closed and replaced whenever a message is written or a
+	// group is rebalanced, which wakes up every waiting reader.
+	changed chan struct{}
+}
+
+type memoryTopic struct {
+	partitions [][]kafka.Message
+	next       int
+}
+
+type memoryGroupKey struct {
+	groupID string
+	topic   string
+}
+
+type memoryGroup struct {
+	committed map[int]int64
+	members   []*memorySubscriber
+}
+
+// NewMemoryBroker creates topics with the given number of partitions.
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     map[string]*memoryTopic{},
+		groups:     map[memoryGroupKey]*memoryGroup{},
+		changed:    make(chan struct{}),
+	}
+}
+
+func (broker *MemoryBroker) Publisher(topic string) Publisher {
+	return &memoryPublisher{broker: broker, topic: topic}
+}
+
+// Subscriber joins groupID on topic. A fetch returns as soon as a message
+// is available, so maxWait does not matter.
+func (broker *MemoryBroker) Subscriber(groupID string, topic string, maxWait time.Duration) Subscriber {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	broker.topic(topic)
+	key := memoryGroupKey{groupID: groupID, topic: topic}
+	group, ok := broker.groups[key]
+	if !ok {
+		group = &memoryGroup{committed: map[int]int64{}}
+		broker.groups[key] = group
+	}
+	subscriber := &memorySubscriber{broker: broker, group: group, topic: topic}
+	group.members = append(group.members, subscriber)
+	broker.rebalance(group)
+	return subscriber
+}
+
+func (broker *MemoryBroker) PartitionReader(topic string, partition int, offset int64) (PartitionReader, error) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if partition < 0 || partition >= len(broker.topic(topic).partitions) {
+		return nil, fmt.Errorf("Topic %s has no partition %d", topic, partition)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("Invalid offset %d", offset)
+	}
+	return &memoryPartitionReader{broker: broker, topic: topic, partition: partition, offset: offset}, nil
+}
+
+func (broker *MemoryBroker) Offsets(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	offsets := []kafka.PartitionOffsets{}
+	for partition, messages := range broker.topic(topic).partitions {
+		offsets = append(offsets, kafka.PartitionOffsets{
+			Partition:   partition,
+			FirstOffset: 0,
+			LastOffset:  int64(len(messages)),
+		})
+	}
+	return offsets, nil
+}
+
+func (broker *MemoryBroker) OffsetAt(ctx context.Context, topic string, partition int, t time.Time) (int64, error) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	partitions := broker.topic(topic).partitions
+	if partition < 0 || partition >= len(partitions) {
+		return 0, fmt.Errorf("Topic %s has no partition %d", topic, partition)
+	}
+	for _, m := range partitions[partition] {
+		if !m.Time.Before(t) {
+			return m.Offset, nil
+		}
+	}
+	return -1, nil
+}
+
+func (broker *MemoryBroker) CommittedOffsets(ctx context.Context, groupID string, topic string) (map[int]int64, error) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	committed := map[int]int64{}
+	if group, ok := broker.groups[memoryGroupKey{groupID: groupID, topic: topic}]; ok {
+		for partition, offset := range group.committed {
+			committed[partition] = offset
+		}
+	}
+	return committed, nil
+}
+
+func (broker *MemoryBroker) Ping(ctx context.Context, topic string) error {
+	return nil
+}
+
+// topic returns the topic called name, creating it if it does not exist.
+// The caller holds mu.
+func (broker *MemoryBroker) topic(name string) *memoryTopic {
+	topic, ok := broker.topics[name]
+	if !ok {
+		topic = &memoryTopic{partitions: make([][]kafka.Message, broker.partitions)}
+		broker.topics[name] = topic
+	}
+	return topic
+}
+
+// rebalance assigns the partitions of the group's topic to its members
+// round robin. Every member continues at the committed offsets. The caller
+// holds mu.
+func (broker *MemoryBroker) rebalance(group *memoryGroup) {
+	for i, member := range group.members {
+		member.positions = map[int]int64{}
+		for partition := i; partition < broker.partitions; partition += len(group.members) {
+			member.positions[partition] = group.committed[partition]
+		}
+	}
+	broker.notify()
+}
+
+// notify wakes up every waiting reader. The caller holds mu.
+func (broker *MemoryBroker) notify() {
+	close(broker.changed)
+	broker.changed = make(chan struct{})
+}
+
+// wait blocks until something changed after changed was read, or ctx is
+// done.
+func wait(ctx context.Context, changed chan struct{}) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+		return nil
+	}
+}
+
+type memoryPublisher struct {
+	broker *MemoryBroker
+	topic  string
+}
+
+func (publisher *memoryPublisher) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	broker := publisher.broker
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	topic := broker.topic(publisher.topic)
+	for _, m := range messages {
+		partition := topic.next % len(topic.partitions)
+		if len(m.Key) != 0 {
+			hash := fnv.New32a()
+			hash.Write(m.Key)
+			partition = int(hash.Sum32() % uint32(len(topic.partitions)))
+		} else {
+			topic.next++
+		}
+
+		m.Topic = publisher.topic
+		m.Partition = partition
+		m.Offset = int64(len(topic.partitions[partition]))
+		m.Headers = append([]kafka.Header{}, m.Headers...)
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		topic.partitions[partition] = append(topic.partitions[partition], m)
+	}
+	broker.notify()
+	return nil
+}
+
+func (publisher *memoryPublisher) Close() error {
+	return nil
+}
+
+type memorySubscriber struct {
+	broker *MemoryBroker
+	group  *memoryGroup
+	topic  string
+	// positions holds the offset of the next message of every partition
+	// assigned to the subscriber.
+	positions map[int]int64
+	// next is the partition the next fetch looks at first, so that one
+	// busy partition does not hold back the others.
+	next   int
+	closed bool
+}
+
+func (subscriber *memorySubscriber) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	broker := subscriber.broker
+	for {
+		broker.mu.Lock()
+		if subscriber.closed {
+			broker.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		partitions := broker.topics[subscriber.topic].partitions
+		for i := 0; i < len(partitions); i++ {
+			partition := (subscriber.next + i) % len(partitions)
+			position, ok := subscriber.positions[partition]
+			if !ok || position >= int64(len(partitions[partition])) {
+				continue
+			}
+			subscriber.positions[partition]++
+			subscriber.next = partition + 1
+			m := partitions[partition][position]
+			broker.mu.Unlock()
+			return m, nil
+		}
+		changed := broker.changed
+		broker.mu.Unlock()
+
+		if err := wait(ctx, changed); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+func (subscriber *memorySubscriber) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	broker := subscriber.broker
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for _, m := range messages {
+		if m.Topic != subscriber.topic {
+			return fmt.Errorf("Cannot commit a message of topic %s on topic %s", m.Topic, subscriber.topic)
+		}
+		if m.Offset+1 > subscriber.group.committed[m.Partition] {
+			subscriber.group.committed[m.Partition] = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+// Close leaves the group, whose other members take over the partitions of
+// the subscriber.
+func (subscriber *memorySubscriber) Close() error {
+	broker := subscriber.broker
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if subscriber.closed {
+		return nil
+	}
+	subscriber.closed = true
+	members := subscriber.group.members[:0]
+	for _, member := range subscriber.group.members {
+		if member != subscriber {
+			members = append(members, member)
+		}
+	}
+	subscriber.group.members = members
+	broker.rebalance(subscriber.group)
+	return nil
+}
+
+type memoryPartitionReader struct {
+	broker    *MemoryBroker
+	topic     string
+	partition int
+	offset    int64
+}
+
+func (reader *memoryPartitionReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	broker := reader.broker
+	for {
+		broker.mu.Lock()
+		messages := broker.topics[reader.topic].partitions[reader.partition]
+		if reader.offset < int64(len(messages)) {
+			m := messages[reader.offset]
+			reader.offset++
+			broker.mu.Unlock()
+			return m, nil
+		}
+		changed := broker.changed
+		broker.mu.Unlock()
+
+		if err := wait(ctx, changed); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+func (reader *memoryPartitionReader) Close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package kafka_helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	t.Run("messages with the same key share a partition", func(t *testing.T) {
+		broker := NewMemoryBroker(4)
+		broker.Publisher("topic").WriteMessages(ctx,
+			kafka.Message{Key: []byte("a"), Value: []byte("1")},
+			kafka.Message{Key: []byte("a"), Value: []byte("2")},
+		)
+
+		offsets, err := broker.Offsets(ctx, "topic")
+		assert.NoError(err)
+		assert.Len(offsets, 4)
+		total := int64(0)
+		for _, partition := range offsets {
+			total += partition.LastOffset
+			if partition.LastOffset != 0 {
+				assert.Equal(int64(2), partition.LastOffset)
+			}
+		}
+		assert.Equal(int64(2), total)
+	})
+
+	t.Run("a group resumes at its committed offsets", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		broker.Publisher("topic").WriteMessages(ctx, kafka.Message{Value: []byte("1")}, kafka.Message{Value: []byte("2")})
+
+		subscriber := broker.Subscriber("group", "topic", time.Second)
+		first, err := subscriber.FetchMessage(ctx)
+		assert.NoError(err)
+		assert.Equal("1", string(first.Value))
+		assert.NoError(subscriber.CommitMessages(ctx, first))
+		subscriber.Close()
+
+		_, err = subscriber.FetchMessage(ctx)
+		assert.Error(err)
+
+		lag, err := TopicLag(ctx, broker, "group", "topic")
+		assert.NoError(err)
+		assert.Equal(int64(1), lag)
+
+		subscriber = broker.Subscriber("group", "topic", time.Second)
+		second, err := subscriber.FetchMessage(ctx)
+		assert.NoError(err)
+		assert.Equal("2", string(second.Value))
+	})
+
+	t.Run("members of a group split the partitions", func(t *testing.T) {
+		broker := NewMemoryBroker(2)
+		broker.Publisher("topic").WriteMessages(ctx, kafka.Message{Value: []byte("1")}, kafka.Message{Value: []byte("2")})
+
+		first := broker.Subscriber("group", "topic", time.Second)
+		second := broker.Subscriber("group", "topic", time.Second)
+		a, err := first.FetchMessage(ctx)
+		assert.NoError(err)
+		b, err := second.FetchMessage(ctx)
+		assert.NoError(err)
+		assert.NotEqual(a.Partition, b.Partition)
+
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = first.FetchMessage(timeout)
+		assert.Equal(context.DeadlineExceeded, err)
+	})
+
+	t.Run("fetching waits for new messages", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		subscriber := broker.Subscriber("group", "topic", time.Second)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			broker.Publisher("topic").WriteMessages(ctx, kafka.Message{Value: []byte("late")})
+		}()
+
+		m, err := subscriber.FetchMessage(ctx)
+		assert.NoError(err)
+		assert.Equal("late", string(m.Value))
+	})
+
+	t.Run("partitions are read from an offset or a time", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		old := time.Now().Add(-time.Hour)
+		broker.Publisher("topic").WriteMessages(ctx,
+			kafka.Message{Value: []byte("old"), Time: old},
+			kafka.Message{Value: []byte("new")},
+		)
+
+		offset, err := broker.OffsetAt(ctx, "topic", 0, old.Add(time.Minute))
+		assert.NoError(err)
+		assert.Equal(int64(1), offset)
+		offset, _ = broker.OffsetAt(ctx, "topic", 0, time.Now().Add(time.Minute))
+		assert.Equal(int64(-1), offset)
+
+		reader, err := broker.PartitionReader("topic", 0, 1)
+		assert.NoError(err)
+		m, err := reader.ReadMessage(ctx)
+		assert.NoError(err)
+		assert.Equal("new", string(m.Value))
+
+		_, err = broker.PartitionReader("topic", 1, 0)
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,70 @@
+package kafka_helpers
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// TopicLag returns how many messages on topic the consumer group groupID has
+// not committed yet, summed over all partitions.
+func TopicLag(ctx context.Context, broker Broker, groupID string, topic string) (int64, error) {
+	offsets, err := broker.Offsets(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	committed, err := broker.CommittedOffsets(ctx, groupID, topic)
+	if err != nil {
+		return 0, err
+	}
+
+	var lag int64
+	for _, partition := range offsets {
+		// If the group never committed on this partition, every message is
+		// still waiting.
+		start, ok := committed[partition.Partition]
+		if !ok {
+			start = partition.FirstOffset
+		}
+		if partition.LastOffset > start {
+			lag += partition.LastOffset - start
+		}
+	}
+
+	return lag, nil
+}
+
+// partitionOffsets returns the first offset and the offset after the last
+// message of every partition of topic.
+func partitionOffsets(ctx context.Context, client *kafka.Client, topic string) ([]kafka.PartitionOffsets, error) {
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{
+		Topics: []string{topic},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offsetRequests := []kafka.OffsetRequest{}
+	for _, t := range metadata.Topics {
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		for _, partition := range t.Partitions {
+			offsetRequests = append(offsetRequests, kafka.FirstOffsetOf(partition.ID), kafka.LastOffsetOf(partition.ID))
+		}
+	}
+
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: offsetRequests},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, partition := range offsets.Topics[topic] {
+		if partition.Error != nil {
+			return nil, partition.Error
+		}
+	}
+	return offsets.Topics[topic], nil
+}
@@ -0,0 +1,54 @@
+package kafka_helpers
+
+import (
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Pipeline wires the event publisher to the consumers that keep a search
+// index up to date, including the retry tiers. With a MemoryBroker and a
+// MemoryIndex the whole path from a repository to the index runs in tests.
+type Pipeline struct {
+	Publisher      *EventPublisher
+	Retries        *RetryRouter
+	Consumer       KafkaConsumer
+	RetryConsumers []KafkaConsumer
+}
+
+func NewPipeline(conf config.KafkaConfig, broker Broker, index elasticsearch_helpers.SearchIndex, companies CompanyFilter, logger *logrus.Logger) *Pipeline {
+	retries := NewRetryRouter(conf, broker, logger)
+	processed := NewProcessedEvents(conf.DedupWindow)
+	return &Pipeline{
+		Publisher:      NewEventPublisher(conf, broker, logger),
+		Retries:        retries,
+		Consumer:       NewConsumer(conf, broker, index, companies, retries, processed, logger),
+		RetryConsumers: NewRetryConsumers(conf, broker, index, companies, retries, processed, logger),
+	}
+}
+
+// Start runs every consumer in its own goroutine until Close.
+func (pipeline *Pipeline) Start() {
+	go pipeline.Consumer.Consume()
+	for i := range pipeline.RetryConsumers {
+		go pipeline.RetryConsumers[i].Consume()
+	}
+}
+
+// Close stops the consumers and flushes and closes every writer.
+func (pipeline *Pipeline) Close() error {
+	var firstErr error
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	record(pipeline.Consumer.Reader.Close())
+	for _, consumer := range pipeline.RetryConsumers {
+		record(consumer.Reader.Close())
+	}
+	record(pipeline.Retries.Close())
+	record(pipeline.Publisher.Close())
+	return firstErr
+}
@@ -0,0 +1,167 @@
+package kafka_helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
+	"internship_project/events"
+	"internship_project/logging"
+	"internship_project/models"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingIndex fails the given number of bulk requests before it passes
+// them on.
+type failingIndex struct {
+	*elasticsearch_helpers.MemoryIndex
+	failures int32
+}
+
+func (index *failingIndex) Bulk(ctx context.Context, operations []elasticsearch_helpers.BulkOperation) ([]error, error) {
+	if atomic.AddInt32(&index.failures, -1) >= 0 {
+		return nil, errors.New("cluster unavailable")
+	}
+	return index.MemoryIndex.Bulk(ctx, operations)
+}
+
+func pipelineConfig() config.KafkaConfig {
+	conf := config.Default().Kafka
+	conf.FlushInterval = 10
+	conf.RetryBackoff = 10
+	conf.RetryAttempts = 2
+	return conf
+}
+
+func TestPipeline(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	product := models.Product{ID: "p1", IDC: "c1", Name: "Milk", Price: 1, Quantity: 2}
+
+	start := func(failures int32) (*Pipeline, *MemoryBroker, *failingIndex) {
+		broker := NewMemoryBroker(2)
+		index := &failingIndex{MemoryIndex: elasticsearch_helpers.NewMemoryIndex(), failures: failures}
+		pipeline := NewPipeline(pipelineConfig(), broker, index, nil, logging.Discard())
+		pipeline.Start()
+		return pipeline, broker, index
+	}
+	indexed := func(index *failingIndex) func() bool {
+		return func() bool {
+			_, ok := index.Get(product.ID)
+			return ok
+		}
+	}
+
+	t.Run("published events reach the index", func(t *testing.T) {
+		pipeline, broker, index := start(0)
+		defer pipeline.Close()
+
+		assert.NoError(pipeline.Publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1}))
+		assert.Eventually(indexed(index), time.Second, 5*time.Millisecond)
+		assert.Eventually(func() bool {
+			lag, _ := TopicLag(ctx, broker, pipeline.Consumer.GroupID, pipeline.Consumer.Topic)
+			return lag == 0
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("failed events are retried", func(t *testing.T) {
+		pipeline, broker, index := start(1)
+		defer pipeline.Close()
+
+		assert.NoError(pipeline.Publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1}))
+		assert.Eventually(indexed(index), time.Second, 5*time.Millisecond)
+
+		offsets, _ := broker.Offsets(ctx, RetryTopic(pipelineConfig(), 1))
+		written := int64(0)
+		for _, partition := range offsets {
+			written += partition.LastOffset
+		}
+		assert.Equal(int64(1), written)
+	})
+
+	t.Run("dead letters can be replayed", func(t *testing.T) {
+		pipeline, broker, index := start(1000)
+		defer pipeline.Close()
+		queue := NewDeadLetterQueue(pipelineConfig(), broker, logging.Discard())
+
+		assert.NoError(pipeline.Publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1}))
+		var deadLetters []models.DeadLetter
+		assert.Eventually(func() bool {
+			deadLetters, _ = queue.Messages(ctx)
+			return len(deadLetters) == 1
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(3, deadLetters[0].Attempts)
+		assert.Equal(events.ProductCreatedType, deadLetters[0].EventType)
+
+		atomic.StoreInt32(&index.failures, 0)
+		assert.NoError(queue.Replay(ctx, deadLetters[0]))
+		assert.Eventually(indexed(index), time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestConsumerGroup(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	conf := pipelineConfig()
+	products := []models.Product{}
+	for i := 0; i < 20; i++ {
+		products = append(products, models.Product{ID: fmt.Sprint("p", i), IDC: "c1", Name: "Milk"})
+	}
+	allIndexed := func(index *elasticsearch_helpers.MemoryIndex) func() bool {
+		return func() bool {
+			for _, product := range products {
+				if _, ok := index.Get(product.ID); !ok {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	t.Run("members split the partitions", func(t *testing.T) {
+		broker := NewMemoryBroker(4)
+		index := elasticsearch_helpers.NewMemoryIndex()
+		first := NewPipeline(conf, broker, index, nil, logging.Discard())
+		second := NewPipeline(conf, broker, index, nil, logging.Discard())
+		first.Start()
+		second.Start()
+		defer first.Close()
+		defer second.Close()
+
+		for _, product := range products {
+			assert.NoError(first.Publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1}))
+		}
+		assert.Eventually(allIndexed(index), time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("a restarted consumer resumes at the committed offsets", func(t *testing.T) {
+		broker := NewMemoryBroker(4)
+		index := elasticsearch_helpers.NewMemoryIndex()
+		first := NewPipeline(conf, broker, index, nil, logging.Discard())
+		first.Start()
+		assert.NoError(first.Publisher.Publish(ctx, products[0].ID, &events.ProductCreated{Product: products[0], Version: 1}))
+		assert.Eventually(func() bool {
+			lag, _ := TopicLag(ctx, broker, conf.GroupID, conf.MainTopic)
+			return lag == 0
+		}, time.Second, 5*time.Millisecond)
+		first.Close()
+
+		// Written while no consumer runs.
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+		for _, product := range products[1:] {
+			assert.NoError(publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1}))
+		}
+		lag, _ := TopicLag(ctx, broker, conf.GroupID, conf.MainTopic)
+		assert.Equal(int64(len(products)-1), lag)
+
+		second := NewPipeline(conf, broker, index, nil, logging.Discard())
+		second.Start()
+		defer second.Close()
+		assert.Eventually(allIndexed(index), time.Second, 5*time.Millisecond)
+	})
+}
@@ -2,27 +2,167 @@ package kafka_helpers
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"internship_project/events"
+	"internship_project/logging"
+	"internship_project/metrics"
+	"internship_project/tracing"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/semconv"
 )
 
 type KafkaProducer struct {
-	Writer *kafka.Writer
+	Topic  string
+	Writer Publisher
+	Logger *logrus.Entry
 }
 
-func (producer *KafkaProducer) WriteMessage(message string, id string) error {
-	kafkaMessage := kafka.Message{
+func NewProducer(broker Broker, topic string, logger *logrus.Logger) *KafkaProducer {
+	return &KafkaProducer{
+		Topic:  topic,
+		Writer: broker.Publisher(topic),
+		Logger: logging.Component(logger, "kafkaProducer"),
+	}
+}
+
+func (producer *KafkaProducer) WriteMessage(ctx context.Context, message string, id string) error {
+	return producer.Forward(ctx, kafka.Message{
 		Key:   []byte(id),
 		Value: []byte(message),
+	})
+}
+
+// Forward writes message as it is. Messages without headers get the request
+// ID and the trace context of ctx, the headers of other messages, e.g. those
+// moved to a retry topic, are kept.
+func (producer *KafkaProducer) Forward(ctx context.Context, message kafka.Message) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "kafka.produce "+producer.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(producer.Topic),
+			semconv.MessagingMessageIDKey.String(string(message.Key)),
+		),
+	)
+	defer func() { tracing.End(ctx, span, err) }()
+
+	if message.Headers == nil {
+		message.Headers = messageHeaders(ctx)
+	}
+	// The topic is the one of the writer, even for forwarded messages.
+	message.Topic = ""
+
+	start := time.Now()
+	err = producer.Writer.WriteMessages(ctx, message)
+	metrics.ObserveKafkaWrite(producer.Topic, start, err)
+
+	logger := producer.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"topic": producer.Topic,
+		"key":   string(message.Key),
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to write message")
+		return err
+	}
+
+	logger.Debug("Written message")
+	return nil
+}
+
+// ForwardBatch writes messages with a single write, see Forward.
+func (producer *KafkaProducer) ForwardBatch(ctx context.Context, messages []kafka.Message) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "kafka.produce "+producer.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(producer.Topic),
+		),
+	)
+	defer func() { tracing.End(ctx, span, err) }()
+
+	batch := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		if message.Headers == nil {
+			message.Headers = messageHeaders(ctx)
+		}
+		message.Topic = ""
+		batch[i] = message
+	}
+
+	start := time.Now()
+	err = producer.Writer.WriteMessages(ctx, batch...)
+	metrics.ObserveKafkaWrite(producer.Topic, start, err)
+
+	logger := producer.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"topic":    producer.Topic,
+		"messages": len(batch),
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to write messages")
+		return err
 	}
 
-	err := producer.Writer.WriteMessages(context.Background(), kafkaMessage)
+	logger.Debug("Written messages")
+	return nil
+}
+
+// Publish wraps event in a versioned envelope and writes it under key.
+func (producer *KafkaProducer) Publish(ctx context.Context, key string, event events.Event) error {
+	message, err := events.Marshal(ctx, event)
 	if err != nil {
-		log.Println("failed to write messages:", err)
+		return err
 	}
+	return producer.WriteMessage(ctx, string(message), key)
+}
+
+// messageHeaders copies the request ID and the trace context of ctx into the
+// Kafka message headers.
+func messageHeaders(ctx context.Context) []kafka.Header {
+	carrier := headerCarrier{}
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		carrier.Set(logging.RequestIDHeader, requestID)
+	}
+	tracing.Inject(ctx, &carrier)
+	return carrier
+}
 
-	fmt.Println(fmt.Sprintf("Written message to %s topic", producer.Writer.Topic))
-	return err
+// messageContext restores the request ID and the trace context stored in the
+// message headers, so that everything done while handling the message is
+// logged under the same request and continues its trace.
+func messageContext(message kafka.Message) context.Context {
+	carrier := headerCarrier(message.Headers)
+	ctx := context.Background()
+	if requestID := carrier.Get(logging.RequestIDHeader); requestID != "" {
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+	return tracing.Extract(ctx, &carrier)
+}
+
+// headerCarrier lets the OpenTelemetry propagators read and write Kafka
+// message headers.
+type headerCarrier []kafka.Header
+
+func (carrier *headerCarrier) Get(key string) string {
+	for _, header := range *carrier {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (carrier *headerCarrier) Set(key string, value string) {
+	for i, header := range *carrier {
+		if header.Key == key {
+			(*carrier)[i].Value = []byte(value)
+			return
+		}
+	}
+	*carrier = append(*carrier, kafka.Header{Key: key, Value: []byte(value)})
 }
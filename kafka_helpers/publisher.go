@@ -0,0 +1,82 @@
+package kafka_helpers
+
+import (
+	"context"
+	"fmt"
+	"internship_project/config"
+	"internship_project/events"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventPublisher writes every event to the topic of its aggregate, so that
+// other systems can subscribe to the aggregates they build projections of.
+type EventPublisher struct {
+	producers map[string]*KafkaProducer
+	// skipped holds the aggregates whose events are dropped, because another
+	// source, e.g. change data capture, publishes them.
+	skipped map[string]bool
+}
+
+// AggregateTopics maps every aggregate to its topic. Product events keep
+// using the main topic, which the Elasticsearch consumer reads.
+func AggregateTopics(conf config.KafkaConfig) map[string]string {
+	return map[string]string{
+		events.ProductAggregate:       conf.MainTopic,
+		events.CompanyAggregate:       conf.CompanyTopic,
+		events.EmployeeAggregate:      conf.EmployeeTopic,
+		events.ShopAggregate:          conf.ShopTopic,
+		events.ExternalRightAggregate: conf.ExternalRightTopic,
+		events.ConstraintAggregate:    conf.ConstraintTopic,
+	}
+}
+
+func NewEventPublisher(conf config.KafkaConfig, broker Broker, logger *logrus.Logger) *EventPublisher {
+	publisher := &EventPublisher{producers: map[string]*KafkaProducer{}}
+	for aggregate, topic := range AggregateTopics(conf) {
+		publisher.producers[aggregate] = NewProducer(broker, topic, logger)
+	}
+	return publisher
+}
+
+// Publish writes event under key to the topic of its aggregate. If ctx
+// carries an EventBatch, the event is added to it and written once the batch
+// is published.
+func (publisher *EventPublisher) Publish(ctx context.Context, key string, event events.Event) error {
+	aggregate := events.Aggregate(event.EventType())
+	if publisher.skipped[aggregate] {
+		return nil
+	}
+	producer, ok := publisher.producers[aggregate]
+	if !ok {
+		return fmt.Errorf("no topic configured for event %q", event.EventType())
+	}
+	if batch := EventBatchFrom(ctx); batch != nil {
+		return batch.add(ctx, producer, key, event)
+	}
+	return producer.Publish(ctx, key, event)
+}
+
+// Without returns a publisher that shares the writers of publisher but drops
+// the events of aggregates.
+func (publisher *EventPublisher) Without(aggregates ...string) *EventPublisher {
+	skipped := map[string]bool{}
+	for aggregate := range publisher.skipped {
+		skipped[aggregate] = true
+	}
+	for _, aggregate := range aggregates {
+		skipped[aggregate] = true
+	}
+	return &EventPublisher{producers: publisher.producers, skipped: skipped}
+}
+
+// Close flushes and closes the writers of every topic.
+func (publisher *EventPublisher) Close() error {
+	var firstErr error
+	for _, producer := range publisher.producers {
+		if err := producer.Writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
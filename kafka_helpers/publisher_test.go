@@ -0,0 +1,48 @@
+package kafka_helpers
+
+import (
+	"context"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/logging"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventPublisher(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	conf := config.Default().Kafka
+
+	topicSize := func(broker Broker, topic string) int64 {
+		offsets, err := broker.Offsets(ctx, topic)
+		assert.NoError(err)
+		return offsets[0].LastOffset
+	}
+
+	t.Run("events go to the topic of their aggregate", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+
+		assert.NoError(publisher.Publish(ctx, "product-1", &events.ProductDeleted{ID: "product-1"}))
+		assert.NoError(publisher.Publish(ctx, "company-1", &events.CompanyDeleted{ID: "company-1"}))
+
+		assert.Equal(int64(1), topicSize(broker, conf.MainTopic))
+		assert.Equal(int64(1), topicSize(broker, conf.CompanyTopic))
+	})
+
+	t.Run("without drops the events of the given aggregates", func(t *testing.T) {
+		broker := NewMemoryBroker(1)
+		publisher := NewEventPublisher(conf, broker, logging.Discard())
+		withoutProducts := publisher.Without(events.ProductAggregate)
+
+		assert.NoError(withoutProducts.Publish(ctx, "product-1", &events.ProductDeleted{ID: "product-1"}))
+		assert.NoError(withoutProducts.Publish(ctx, "company-1", &events.CompanyDeleted{ID: "company-1"}))
+		assert.NoError(publisher.Publish(ctx, "product-2", &events.ProductDeleted{ID: "product-2"}))
+
+		assert.Equal(int64(1), topicSize(broker, conf.MainTopic))
+		assert.Equal(int64(1), topicSize(broker, conf.CompanyTopic))
+	})
+}
@@ -0,0 +1,154 @@
+package kafka_helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"internship_project/events"
+	"internship_project/logging"
+	"internship_project/metrics"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers that track the failures of a message across the retry tiers.
+const (
+	AttemptsHeader          = "x-attempts"
+	OriginalTopicHeader     = "x-original-topic"
+	OriginalPartitionHeader = "x-original-partition"
+	OriginalOffsetHeader    = "x-original-offset"
+	ErrorHeader             = "x-error"
+	RetryAtHeader           = "x-retry-at"
+)
+
+// RetryTopic returns the topic of a retry tier, counting from 1.
+func RetryTopic(conf config.KafkaConfig, tier int) string {
+	return fmt.Sprintf("%s-%d", conf.RetryTopic, tier)
+}
+
+// RetryTopics returns the topics of every retry tier in order.
+func RetryTopics(conf config.KafkaConfig) []string {
+	topics := make([]string, conf.RetryAttempts)
+	for i := range topics {
+		topics[i] = RetryTopic(conf, i+1)
+	}
+	return topics
+}
+
+// RetryDelay returns how long a message waits in a retry tier. The delay
+// doubles with every tier.
+func RetryDelay(conf config.KafkaConfig, tier int) time.Duration {
+	return time.Duration(conf.RetryBackoff) * time.Millisecond << uint(tier-1)
+}
+
+// RetryRouter moves messages that could not be processed to the next retry
+// tier, or to the dead-letter topic once every tier has been tried.
+type RetryRouter struct {
+	Config    config.KafkaConfig
+	producers map[string]*KafkaProducer
+	Logger    *logrus.Entry
+}
+
+func NewRetryRouter(conf config.KafkaConfig, broker Broker, logger *logrus.Logger) *RetryRouter {
+	router := &RetryRouter{
+		Config:    conf,
+		producers: map[string]*KafkaProducer{},
+		Logger:    logging.Component(logger, "retryRouter"),
+	}
+	for _, topic := range append(RetryTopics(conf), conf.DeadLetterTopic) {
+		router.producers[topic] = NewProducer(broker, topic, logger)
+	}
+	return router
+}
+
+// Route records the failure in the headers of message and writes it to the
+// topic of its next attempt.
+func (router *RetryRouter) Route(ctx context.Context, message kafka.Message, cause error) error {
+	attempts := MessageAttempts(message) + 1
+
+	carrier := headerCarrier(append([]kafka.Header{}, message.Headers...))
+	if carrier.Get(OriginalTopicHeader) == "" {
+		carrier.Set(OriginalTopicHeader, message.Topic)
+		carrier.Set(OriginalPartitionHeader, strconv.Itoa(message.Partition))
+		carrier.Set(OriginalOffsetHeader, strconv.FormatInt(message.Offset, 10))
+	}
+	carrier.Set(AttemptsHeader, strconv.Itoa(attempts))
+	carrier.Set(ErrorHeader, cause.Error())
+
+	topic := router.Config.DeadLetterTopic
+	if attempts <= router.Config.RetryAttempts && !isPermanent(cause) {
+		topic = RetryTopic(router.Config, attempts)
+		retryAt := time.Now().Add(RetryDelay(router.Config, attempts))
+		carrier.Set(RetryAtHeader, strconv.FormatInt(retryAt.UnixNano()/int64(time.Millisecond), 10))
+	}
+
+	err := router.producers[topic].Forward(ctx, kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: carrier,
+	})
+	if err != nil {
+		return err
+	}
+	metrics.ObserveKafkaRedelivery(message.Topic, topic)
+
+	logger := router.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"topic":    topic,
+		"key":      string(message.Key),
+		"attempts": attempts,
+	}).WithError(cause)
+	if topic == router.Config.DeadLetterTopic {
+		logger.Error("Moved message to the dead-letter topic")
+	} else {
+		logger.Warn("Scheduled message for retry")
+	}
+	return nil
+}
+
+// Close flushes and closes the writers of every retry tier and of the
+// dead-letter topic.
+func (router *RetryRouter) Close() error {
+	var firstErr error
+	for _, producer := range router.producers {
+		if err := producer.Writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// isPermanent reports whether retrying cannot help, e.g. because the message
+// cannot be decoded at all.
+func isPermanent(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.Is(err, events.ErrUnsupportedVersion) ||
+		errors.Is(err, events.ErrUnknownEventType) ||
+		errors.As(err, &syntaxErr) ||
+		errors.As(err, &typeErr)
+}
+
+// MessageAttempts returns how many times processing message has failed.
+func MessageAttempts(message kafka.Message) int {
+	carrier := headerCarrier(message.Headers)
+	attempts, err := strconv.Atoi(carrier.Get(AttemptsHeader))
+	if err != nil {
+		return 0
+	}
+	return attempts
+}
+
+// retryAt returns when message is due for its next attempt.
+func retryAt(message kafka.Message) time.Time {
+	carrier := headerCarrier(message.Headers)
+	millis, err := strconv.ParseInt(carrier.Get(RetryAtHeader), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
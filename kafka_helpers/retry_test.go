@@ -0,0 +1,64 @@
+package kafka_helpers
+
+import (
+	"errors"
+	"fmt"
+	"internship_project/config"
+	"internship_project/events"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTiers(t *testing.T) {
+	assert := assert.New(t)
+	conf := config.KafkaConfig{RetryTopic: "retry", RetryAttempts: 3, RetryBackoff: 5000}
+
+	t.Run("one topic per tier", func(t *testing.T) {
+		assert.Equal([]string{"retry-1", "retry-2", "retry-3"}, RetryTopics(conf))
+	})
+
+	t.Run("delay doubles with every tier", func(t *testing.T) {
+		assert.Equal(5*time.Second, RetryDelay(conf, 1))
+		assert.Equal(10*time.Second, RetryDelay(conf, 2))
+		assert.Equal(20*time.Second, RetryDelay(conf, 3))
+	})
+}
+
+func TestRetryHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("messages without headers have no attempts", func(t *testing.T) {
+		assert.Equal(0, MessageAttempts(kafka.Message{}))
+		assert.True(retryAt(kafka.Message{}).IsZero())
+	})
+
+	t.Run("attempts and retry time are read from the headers", func(t *testing.T) {
+		due := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		message := kafka.Message{Headers: []kafka.Header{
+			{Key: AttemptsHeader, Value: []byte("2")},
+			{Key: RetryAtHeader, Value: []byte(fmt.Sprint(due.UnixNano() / int64(time.Millisecond)))},
+		}}
+
+		assert.Equal(2, MessageAttempts(message))
+		assert.True(due.Equal(retryAt(message)))
+	})
+}
+
+func TestIsPermanent(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("undecodable messages are not retried", func(t *testing.T) {
+		_, _, err := events.Unmarshal([]byte("not json"))
+		assert.True(isPermanent(err))
+
+		_, _, err = events.Unmarshal([]byte(`{"type":"product.created","schema_version":"2.0"}`))
+		assert.True(isPermanent(err))
+	})
+
+	t.Run("other errors are retried", func(t *testing.T) {
+		assert.False(isPermanent(errors.New("elasticsearch unavailable")))
+	})
+}
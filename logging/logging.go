@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"internship_project/config"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/api/trace"
+)
+
+// RequestIDHeader is used both for incoming HTTP requests and for Kafka
+// message headers, so a request can be followed through the whole pipeline.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// New creates the application logger. Entries logged with WithContext get
+// the request ID and trace ID of the context attached automatically.
+func New(conf config.LoggingConfig) (*logrus.Logger, error) {
+	logger := logrus.New()
+
+	level, err := logrus.ParseLevel(conf.Level)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+
+	if conf.Format == "text" {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	logger.AddHook(requestIDHook{})
+
+	return logger, nil
+}
+
+// Discard returns a logger that writes nothing, for use in tests.
+func Discard() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(requestIDHook{})
+	return logger
+}
+
+// Component returns a logger whose entries are tagged with the component name.
+func Component(logger *logrus.Logger, name string) *logrus.Entry {
+	return logger.WithField("component", name)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+type requestIDHook struct{}
+
+func (hook requestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook requestIDHook) Fire(entry *logrus.Entry) error {
+	if requestID := RequestID(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	if entry.Context != nil {
+		if spanContext := trace.SpanFromContext(entry.Context).SpanContext(); spanContext.IsValid() {
+			entry.Data["trace_id"] = spanContext.TraceID.String()
+		}
+	}
+	return nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware assigns every request an ID, taken from the X-Request-ID header
+// when the caller already has one, returns it in the response and logs the
+// outcome of the request.
+func Middleware(logger *logrus.Entry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = uuid.NewV4().String()
+			}
+
+			ctx := WithRequestID(r.Context(), requestID)
+			w.Header().Set(RequestIDHeader, requestID)
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method":   r.Method,
+				"path":     r.URL.Path,
+				"status":   recorder.status,
+				"duration": time.Since(start).String(),
+			}).Info("Request handled")
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"internship_project/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func bufferedLogger(t *testing.T) (*logrus.Logger, *bytes.Buffer) {
+	logger, err := New(config.LoggingConfig{Level: "debug", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+	logger.SetOutput(&buff)
+	return logger, &buff
+}
+
+func lastEntry(t *testing.T, buff *bytes.Buffer) map[string]interface{} {
+	lines := bytes.Split(bytes.TrimSpace(buff.Bytes()), []byte("\n"))
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(lines[len(lines)-1], &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("unknown level", func(t *testing.T) {
+		_, err := New(config.LoggingConfig{Level: "loud", Format: "json"})
+
+		assert.Error(err)
+	})
+
+	t.Run("request id is added from context", func(t *testing.T) {
+		logger, buff := bufferedLogger(t)
+		ctx := WithRequestID(context.Background(), "request-1")
+
+		Component(logger, "test").WithContext(ctx).Info("Hello")
+
+		entry := lastEntry(t, buff)
+		assert.Equal("request-1", entry["request_id"])
+		assert.Equal("test", entry["component"])
+	})
+
+	t.Run("no request id without context", func(t *testing.T) {
+		logger, buff := bufferedLogger(t)
+
+		logger.Info("Hello")
+
+		assert.NotContains(lastEntry(t, buff), "request_id")
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var seenRequestID string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		seenRequestID = RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	t.Run("generates request id", func(t *testing.T) {
+		logger, buff := bufferedLogger(t)
+		request := httptest.NewRequest("GET", "/product", nil)
+		recorder := httptest.NewRecorder()
+
+		Middleware(Component(logger, "http"))(http.HandlerFunc(handler)).ServeHTTP(recorder, request)
+
+		assert.NotEmpty(seenRequestID)
+		assert.Equal(seenRequestID, recorder.Header().Get(RequestIDHeader))
+
+		entry := lastEntry(t, buff)
+		assert.Equal(seenRequestID, entry["request_id"])
+		assert.Equal(float64(http.StatusTeapot), entry["status"])
+		assert.Equal("/product", entry["path"])
+	})
+
+	t.Run("reuses incoming request id", func(t *testing.T) {
+		logger, _ := bufferedLogger(t)
+		request := httptest.NewRequest("GET", "/product", nil)
+		request.Header.Set(RequestIDHeader, "incoming-id")
+		recorder := httptest.NewRecorder()
+
+		Middleware(Component(logger, "http"))(http.HandlerFunc(handler)).ServeHTTP(recorder, request)
+
+		assert.Equal("incoming-id", seenRequestID)
+		assert.Equal("incoming-id", recorder.Header().Get(RequestIDHeader))
+	})
+}
@@ -2,105 +2,162 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/codingsince1985/geo-golang/mapquest/nominatim"
+	"internship_project/cache"
+	"internship_project/cdc"
+	"internship_project/config"
 	"internship_project/controllers"
 	"internship_project/elasticsearch_helpers"
+	"internship_project/events"
+	"internship_project/health"
 	"internship_project/kafka_helpers"
+	"internship_project/logging"
+	"internship_project/metrics"
 	"internship_project/repositories"
 	"internship_project/services"
+	"internship_project/tracing"
 	"internship_project/utils"
 	"net/http"
 	"os"
+	"time"
 
 	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/lytics/confl"
-	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
-type DbConfig struct {
-	DbUsername      string `json:"db_username"`
-	DbPassword      string `json:"db_password"`
-	DatabaseURL     string `json:"database_url"`
-	TestDatabaseURL string `json:"test_database_url"`
-}
-
-
-type NominatimConfig struct {
-	Key string `json:"nominatim_key"`
-}
-
-type KafkaEsConfig struct {
-	MainKafkaTopic  string `json:"main_kafka_topic"`
-	RetryKafkaTopic string `json:"retry_kafka_topic"`
-	MainTopicTime   int    `json:"main_topic_time"`
-	RetryTopicTime  int    `json:"retry_topic_time"`
-	KafkaAddress    string `json:"kafka_address"`
-	KafkaGroupId    string `json:"kafka_group_id"`
-	EsAddress       string `json:"es_address"`
-}
-
 var (
 	userRepository repositories.UserRepository
 	userService    services.UserService
 )
 
 func main() {
-	var db_conf DbConfig
-	if _, err := confl.DecodeFile("dbconfig.conf", &db_conf); err != nil {
-		panic(err)
+	configPath := flag.String("config", "config.conf", "path to the configuration file")
+	catchUpFrom := flag.Duration("catch-up-from", 5*time.Minute, "reindex: apply the events written this long before the rebuild started")
+	flag.Parse()
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+			printConfig(conf)
+			return
+		}
+		if len(args) == 1 && args[0] == "reindex" {
+			runReindex(conf, *catchUpFrom)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", strings.Join(args, " "))
+		os.Exit(2)
+	}
+
+	if err := conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.New(conf.Logging)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	var kafka_es_conf KafkaEsConfig
-	if _, err := confl.DecodeFile("kafka_es_cofig.conf", &kafka_es_conf); err != nil {
-		panic(err)
+	shutdownTracing, err := tracing.Setup(conf.Tracing)
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to set up tracing")
 	}
+	defer shutdownTracing(context.Background())
 
-	connpool := getConnectionPool(db_conf)
+	connpool := getConnectionPool(conf.Database, logger)
 	defer connpool.Close()
+	metrics.RegisterPool(connpool)
 
-	kafkaWriter := kafka_helpers.GetWriter("ava-internship")
-	defer kafkaWriter.Close()
+	EsClient := elasticsearch_helpers.GetElasticsearchClient(conf.Elasticsearch, logger)
+	if err := EsClient.EnsureProductIndex(context.Background()); errors.Is(err, elasticsearch_helpers.ErrUnversionedProductIndex) {
+		logger.WithError(err).Fatal("Unable to serve the product index")
+	} else if err != nil {
+		logger.WithError(err).Error("Unable to create the product index")
+	}
 
-	EsClient := elasticsearch_helpers.GetElasticsearchClient(kafka_es_conf.EsAddress)
-	kafkaConsumer := kafka_helpers.NewConsumer(kafka_es_conf.MainKafkaTopic, kafka_es_conf.KafkaAddress, kafka_es_conf.KafkaGroupId, EsClient, kafka_es_conf.MainTopicTime)
-	go kafkaConsumer.Consume()
-	defer kafkaConsumer.Reader.Close()
+	broker, err := kafka_helpers.NewKafkaBroker(conf.Kafka)
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to set up the Kafka connection")
+	}
+	pipeline := kafka_helpers.NewPipeline(conf.Kafka, broker, &EsClient, repositories.ActiveCompanies(connpool), logger)
+	pipeline.Start()
+	defer pipeline.Close()
+	publisher := pipeline.Publisher
+	if conf.CDC.Enabled {
+		// The listener publishes the events of the captured aggregates in
+		// place of the repositories.
+		listener := cdc.NewListener(conf.CDC, conf.ReplicationURL(), pipeline.Publisher, logger)
+		go listener.Run(context.Background())
+		publisher = publisher.Without(conf.CDC.Aggregates...)
+	}
+	registerKafkaMetrics(conf, broker, logger)
 
-	kafkaRetryHandler := kafka_helpers.GetRetryHandler(kafka_es_conf.RetryKafkaTopic, kafka_es_conf.MainKafkaTopic, kafka_es_conf.KafkaAddress, kafka_es_conf.KafkaGroupId, kafka_es_conf.RetryTopicTime)
+	accessCache := cache.NewAccessCache(conf.AccessCache, connpool, logger)
+	go accessCache.Listen(context.Background())
 
-	employeeController := getEmployeeController(connpool)
-	productController := getProductController(connpool, &employeeController.Service.Repository, kafkaWriter, EsClient)
-	companyController := GetCompanyController(connpool, kafkaWriter)
-	ExternalRightController := getExternalRightController(connpool)
-	constraintController := getConstraintController(connpool)
-	userController := getUserController(connpool)
-	shopController := getShopController(connpool)
+	// Only the listener reads the actor messages, so they are not written
+	// without it.
+	unitOfWork := repositories.NewUnitOfWork(connpool, conf.CDC.Enabled && conf.CDC.ActorMessages)
 
-	userRepository = repositories.NewUserRepo(connpool)
-	userService = services.UserService{Repository: userRepository}
+	employeeController := getEmployeeController(connpool, unitOfWork, publisher, logger)
+	productController := getProductController(connpool, unitOfWork, &employeeController.Service.Repository, publisher, accessCache, &EsClient, conf.Products, logger)
+	companyController := GetCompanyController(connpool, unitOfWork, publisher, conf.Companies, logger)
+	ExternalRightController := getExternalRightController(connpool, unitOfWork, publisher, logger)
+	constraintController := getConstraintController(connpool, unitOfWork, publisher, logger)
+	userController := getUserController(connpool, conf.GoogleAuth, logger)
+	shopController := getShopController(connpool, unitOfWork, publisher, conf.Nominatim, logger)
+	deadLetterController := getDeadLetterController(connpool, unitOfWork, conf.Kafka, broker, logger)
+	defer deadLetterController.Service.Queue.Close()
+	healthController := getHealthController(conf, connpool, broker, EsClient, &pipeline.Consumer, logger)
+
+	userRepository = repositories.NewUserRepo(connpool, logger)
+	userService = services.UserService{Repository: userRepository, GoogleClientID: conf.GoogleAuth.ClientID, Logger: logging.Component(logger, "userService")}
+
+	go purgeArchivedCompanies(companyController.Service, conf.Companies, logger)
 
 	r := mux.NewRouter()
+	r.Use(otelmux.Middleware(conf.Tracing.ServiceName))
+	r.Use(logging.Middleware(logging.Component(logger, "http")))
+	r.Use(metrics.HTTPMiddleware)
+	r.Use(events.ActorMiddleware(requestActor))
 	s := http.StripPrefix("/static/", http.FileServer(http.Dir("./public/")))
 	r.PathPrefix("/static/").Handler(s)
 
+	// Health Routes
+	r.HandleFunc("/healthz", healthController.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", healthController.Readiness).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	// Sign In Routes
 	r.HandleFunc("/auth/google", userController.GoogleAuth).Methods("POST")
 
-	r.HandleFunc("/search", productController.SearchProducts).Methods("GET")
+	// Search Routes
+	searchRouter := r.PathPrefix("/search").Subrouter()
 
-	// Kafka routes
-	kafkaRouter := r.PathPrefix("/kafka").Subrouter()
-	kafkaRouter.HandleFunc("/retry", kafkaRetryHandler.TransferToMainTopic).Methods("POST")
+	searchRouter.HandleFunc("", productController.SearchProducts).Methods("GET")
+	searchRouter.HandleFunc("/suggest", productController.SuggestProducts).Methods("GET")
 
 	// Product Routes
 	productRouter := r.PathPrefix("/product").Subrouter()
 	productRouter.Headers("employeeID")
 
 	productRouter.HandleFunc("", productController.GetAllProducts).Methods("GET")
+	productRouter.HandleFunc("/import", productController.ImportProducts).Methods("POST")
+	productRouter.HandleFunc("/export", productController.ExportProducts).Methods("GET")
 	productRouter.HandleFunc("/{id}", productController.GetProductById).Methods("GET")
 	productRouter.HandleFunc("", productController.AddProduct).Methods("POST")
 	productRouter.HandleFunc("", productController.UpdateProduct).Methods("PUT")
@@ -115,11 +172,12 @@ func main() {
 	companyRouter.HandleFunc("", companyController.AddCompany).Methods("POST")
 	companyRouter.HandleFunc("", companyController.UpdateCompany).Methods("PUT")
 	companyRouter.HandleFunc("/{id}", companyController.DeleteCompany).Methods("DELETE")
+	companyRouter.HandleFunc("/{id}/restore", companyController.RestoreCompany).Methods("POST")
 	companyRouter.HandleFunc("/approve/{idear}", func(w http.ResponseWriter, r *http.Request) {
 		companyController.ChangeExternalRightApproveStatus(w, r, true)
 	}).Methods("PATCH")
 	companyRouter.HandleFunc("/disapprove/{idear}", func(w http.ResponseWriter, r *http.Request) {
-		companyController.ChangeExternalRightApproveStatus(w, r, true)
+		companyController.ChangeExternalRightApproveStatus(w, r, false)
 	}).Methods("PATCH")
 
 	// Employee Routes
@@ -160,16 +218,29 @@ func main() {
 	shopRouter.HandleFunc("/{id}", shopController.DeleteShop).Methods("DELETE")
 	shopRouter.HandleFunc("/{id}/address", shopController.GetAddress).Methods("GET")
 
+	// Dead Letter Routes
+	deadLetterRouter := r.PathPrefix("/kafka/dead-letters").Subrouter()
+
+	deadLetterRouter.HandleFunc("", deadLetterController.GetDeadLetters).Methods("GET")
+	deadLetterRouter.HandleFunc("/replay", deadLetterController.ReplayDeadLetters).Methods("POST")
+	deadLetterRouter.HandleFunc("/{id}", deadLetterController.GetDeadLetterById).Methods("GET")
+	deadLetterRouter.HandleFunc("/{id}/replay", deadLetterController.ReplayDeadLetter).Methods("POST")
+	deadLetterRouter.HandleFunc("/{id}", deadLetterController.DiscardDeadLetter).Methods("DELETE")
+
 	companyRouter.Use(googleAuthMiddleware)
 	constraintRouter.Use(googleAuthMiddleware)
 	employeeRouter.Use(googleAuthMiddleware)
 	earRouter.Use(googleAuthMiddleware)
 	productRouter.Use(googleAuthMiddleware)
+	searchRouter.Use(googleAuthMiddleware)
 	shopRouter.Use(googleAuthMiddleware)
-	kafkaRouter.Use(googleAuthMiddleware)
+	deadLetterRouter.Use(googleAuthMiddleware, operatorMiddleware(conf.Kafka.DeadLetterOperators))
 
 	http.Handle("/", r)
-	http.ListenAndServe(":8000", r)
+	logger.WithField("address", conf.Server.Address).Info("Server listening")
+	if err := http.ListenAndServe(conf.Server.Address, r); err != nil {
+		logger.WithError(err).Fatal("Server stopped")
+	}
 }
 
 func googleAuthMiddleware(next http.Handler) http.Handler {
@@ -191,92 +262,253 @@ func googleAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func getConnectionPool(conf DbConfig) *pgxpool.Pool {
-	poolConfig, _ := pgxpool.ParseConfig(conf.DatabaseURL)
+// operatorMiddleware only lets the users in operators through, by the
+// subject of their JWT.
+func operatorMiddleware(operators []string) mux.MiddlewareFunc {
+	allowed := map[string]bool{}
+	for _, id := range operators {
+		allowed[id] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowed[utils.JWTSubject(r.Header.Get("jwt"))] {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("Only operators can do this"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-	connection, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+// requestActor names the caller of a request for the events it publishes:
+// the employee of the employeeID header, or else the subject of the JWT. The
+// companyID header, where there is one, is the tenant.
+func requestActor(r *http.Request) (string, string) {
+	actor := r.Header.Get("employeeID")
+	if actor == "" {
+		actor = utils.JWTSubject(r.Header.Get("jwt"))
+	}
+	return actor, r.Header.Get("companyID")
+}
+
+func runReindex(conf config.Config, catchUpFrom time.Duration) {
+	if err := conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.New(conf.Logging)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := reindex(conf, catchUpFrom, logger); err != nil {
+		logger.WithError(err).Fatal("Reindex failed")
+	}
+}
+
+func printConfig(conf config.Config) {
+	if err := conf.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+}
+
+func getConnectionPool(conf config.DatabaseConfig, logger *logrus.Logger) *pgxpool.Pool {
+	poolConfig, _ := pgxpool.ParseConfig(conf.URL)
+	tracing.InstrumentConnConfig(poolConfig.ConnConfig)
+
+	connection, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	if err != nil {
+		logger.WithError(err).Fatal("Unable to connect to database")
+	}
 
-	fmt.Println("Connected to database.")
+	logger.Info("Connected to database")
 
 	return connection
 }
 
-func getProductController(connpool *pgxpool.Pool, employeeRepo *repositories.EmployeeRepository, kafkaWriter *kafka.Writer, esclient elasticsearch_helpers.ElasticsearchClient) controllers.ProductController {
-	productRepository := repositories.NewProductRepo(connpool, kafkaWriter)
-	productService := services.ProductService{ProductRepository: productRepository, EmployeeRepository: *employeeRepo}
-	productController := controllers.ProductController{Service: productService, ElasticsearchClient: esclient}
+func getProductController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, employeeRepo *repositories.EmployeeRepository, publisher *kafka_helpers.EventPublisher, accessCache *cache.AccessCache, index elasticsearch_helpers.SearchIndex, conf config.ProductsConfig, logger *logrus.Logger) controllers.ProductController {
+	productRepository := repositories.NewCachedProductRepo(connpool, unitOfWork, publisher, accessCache, logger)
+	employeeRepository := repositories.NewCachedEmployeeRepo(*employeeRepo, accessCache)
+	productService := services.ProductService{
+		ProductRepository:  productRepository,
+		EmployeeRepository: employeeRepository,
+		SearchIndex:        index,
+		UnitOfWork:         unitOfWork,
+		ImportBatchSize:    conf.ImportBatchSize,
+		ImportMaxRows:      conf.ImportMaxRows,
+		Logger:             logging.Component(logger, "productService"),
+	}
+	productController := controllers.ProductController{Service: productService, Logger: logging.Component(logger, "productController")}
 
-	fmt.Println("Product controller up and running.")
+	logger.Info("Product controller up and running")
 
 	return productController
 }
 
-func GetCompanyController(connpool *pgxpool.Pool, kafkaWriter *kafka.Writer) controllers.CompanyController {
-	companyRepository := repositories.NewCompanyRepo(connpool, kafkaWriter)
-	companyService := services.CompanyService{Repository: companyRepository}
-	companyController := controllers.CompanyController{Service: companyService}
+func GetCompanyController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, publisher *kafka_helpers.EventPublisher, conf config.CompaniesConfig, logger *logrus.Logger) controllers.CompanyController {
+	companyRepository := repositories.NewCompanyRepo(connpool, unitOfWork, publisher, logger)
+	companyService := services.CompanyService{
+		Repository:         companyRepository,
+		SoftDelete:         conf.DeleteMode == "soft",
+		RestoreGracePeriod: time.Duration(conf.RestoreGracePeriod) * time.Hour,
+		Logger:             logging.Component(logger, "companyService"),
+	}
+	companyController := controllers.CompanyController{Service: companyService, Logger: logging.Component(logger, "companyController")}
 
-	fmt.Println("Company controller up and running.")
+	logger.Info("Company controller up and running")
 
 	return companyController
 }
 
-func getEmployeeController(connpool *pgxpool.Pool) controllers.EmployeeController {
-	employeeRepository := repositories.NewEmployeeRepo(connpool)
-	employeeService := services.EmployeeService{Repository: employeeRepository}
-	employeeController := controllers.EmployeeController{Service: employeeService}
+// purgeArchivedCompanies deletes archived companies for good once their
+// restore grace period has passed. Companies archived in soft delete mode are
+// purged even after switching back to hard delete mode.
+func purgeArchivedCompanies(service services.CompanyService, conf config.CompaniesConfig, logger *logrus.Logger) {
+	ticker := time.NewTicker(time.Duration(conf.PurgeInterval) * time.Minute)
+	defer ticker.Stop()
 
-	fmt.Println("\nEmployee controller up and running.")
+	for range ticker.C {
+		purged, err := service.PurgeArchivedCompanies(context.Background())
+		if err != nil {
+			logger.WithError(err).Error("Unable to purge archived companies")
+			continue
+		}
+		if purged > 0 {
+			logger.WithField("companies", purged).Info("Purged archived companies")
+		}
+	}
+}
+
+func getDeadLetterController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, conf config.KafkaConfig, broker kafka_helpers.Broker, logger *logrus.Logger) controllers.DeadLetterController {
+	deadLetterService := services.DeadLetterService{
+		Queue:           kafka_helpers.NewDeadLetterQueue(conf, broker, logger),
+		Repository:      repositories.NewDeadLetterRepo(connpool, logger),
+		AuditRepository: repositories.NewAuditRepo(connpool, logger),
+		UnitOfWork:      unitOfWork,
+		Logger:          logging.Component(logger, "deadLetterService"),
+	}
+	deadLetterController := controllers.DeadLetterController{Service: deadLetterService, Logger: logging.Component(logger, "deadLetterController")}
+
+	logger.Info("Dead letter controller up and running")
+
+	return deadLetterController
+}
+
+func getEmployeeController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) controllers.EmployeeController {
+	employeeRepository := repositories.NewEmployeeRepo(connpool, unitOfWork, publisher, logger)
+	employeeService := services.EmployeeService{Repository: employeeRepository, Logger: logging.Component(logger, "employeeService")}
+	employeeController := controllers.EmployeeController{Service: employeeService, Logger: logging.Component(logger, "employeeController")}
+
+	logger.Info("Employee controller up and running")
 
 	return employeeController
 }
 
-func getExternalRightController(connpool *pgxpool.Pool) controllers.ExternalRightController {
-	earRepository := repositories.NewExternalRightRepo(connpool)
-	earService := services.ExternalRightService{Repository: earRepository}
-	ExternalRightController := controllers.ExternalRightController{Service: earService}
+func getExternalRightController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) controllers.ExternalRightController {
+	earRepository := repositories.NewExternalRightRepo(connpool, unitOfWork, publisher, logger)
+	earService := services.ExternalRightService{Repository: earRepository, Logger: logging.Component(logger, "externalRightService")}
+	ExternalRightController := controllers.ExternalRightController{Service: earService, Logger: logging.Component(logger, "externalRightController")}
 
-	fmt.Println("External access rights controller up and running.")
+	logger.Info("External access rights controller up and running")
 
 	return ExternalRightController
 }
 
-func getConstraintController(connpool *pgxpool.Pool) controllers.ConstraintController {
-	constraintRepository := repositories.NewConstraintRepo(connpool)
-	constraintService := services.ConstraintService{Repository: constraintRepository}
-	constraintController := controllers.ConstraintController{Service: constraintService}
+func getConstraintController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) controllers.ConstraintController {
+	constraintRepository := repositories.NewConstraintRepo(connpool, unitOfWork, publisher, logger)
+	constraintService := services.ConstraintService{Repository: constraintRepository, Logger: logging.Component(logger, "constraintService")}
+	constraintController := controllers.ConstraintController{Service: constraintService, Logger: logging.Component(logger, "constraintController")}
 
-	fmt.Println("Constraints controller up and running.")
+	logger.Info("Constraints controller up and running")
 
 	return constraintController
 }
 
-func getUserController(connpool *pgxpool.Pool) controllers.UserController {
-	userRepository := repositories.NewUserRepo(connpool)
-	userService := services.UserService{Repository: userRepository}
-	userController := controllers.UserController{Service: userService}
+func getUserController(connpool *pgxpool.Pool, conf config.GoogleAuthConfig, logger *logrus.Logger) controllers.UserController {
+	userRepository := repositories.NewUserRepo(connpool, logger)
+	userService := services.UserService{Repository: userRepository, GoogleClientID: conf.ClientID, Logger: logging.Component(logger, "userService")}
+	userController := controllers.UserController{Service: userService, Logger: logging.Component(logger, "userController")}
 
-	fmt.Println("User controller up and running.")
+	logger.Info("User controller up and running")
 
 	return userController
 }
 
-func getShopController(connpool *pgxpool.Pool) controllers.ShopController {
-	var conf NominatimConfig
-	if _, err := confl.DecodeFile("nominatim_config.conf", &conf); err != nil {
-		panic(err)
-	}
-
-	shopRepository := repositories.NewShopRepo(connpool)
+func getShopController(connpool *pgxpool.Pool, unitOfWork repositories.UnitOfWork, publisher *kafka_helpers.EventPublisher, conf config.NominatimConfig, logger *logrus.Logger) controllers.ShopController {
+	shopRepository := repositories.NewShopRepo(connpool, unitOfWork, publisher, logger)
 	geocoder := nominatim.Geocoder(conf.Key)
-	shopService := services.ShopService{Repository: shopRepository, Geocoder: geocoder}
-	shopController := controllers.ShopController{Service: shopService}
+	shopService := services.ShopService{Repository: shopRepository, Geocoder: geocoder, Logger: logging.Component(logger, "shopService")}
+	shopController := controllers.ShopController{Service: shopService, Logger: logging.Component(logger, "shopController")}
 
-	fmt.Println("Shop controller up and running.")
+	logger.Info("Shop controller up and running")
 
 	return shopController
 }
+
+func registerKafkaMetrics(conf config.Config, broker kafka_helpers.Broker, logger *logrus.Logger) {
+	timeout := time.Duration(conf.Health.CheckTimeout) * time.Millisecond
+
+	metrics.RegisterConsumerLag(conf.Kafka.MainTopic, timeout, func(ctx context.Context) (int64, error) {
+		return kafka_helpers.TopicLag(ctx, broker, conf.Kafka.GroupID, conf.Kafka.MainTopic)
+	}, logger)
+	for _, topic := range kafka_helpers.RetryTopics(conf.Kafka) {
+		topic := topic
+		metrics.RegisterRetryTopicDepth(topic, timeout, func(ctx context.Context) (int64, error) {
+			return kafka_helpers.TopicLag(ctx, broker, kafka_helpers.RetryGroupID(conf.Kafka, topic), topic)
+		}, logger)
+	}
+	// Nothing consumes the dead-letter topic, so its whole content counts.
+	metrics.RegisterRetryTopicDepth(conf.Kafka.DeadLetterTopic, timeout, func(ctx context.Context) (int64, error) {
+		return kafka_helpers.TopicLag(ctx, broker, kafka_helpers.RetryGroupID(conf.Kafka, conf.Kafka.DeadLetterTopic), conf.Kafka.DeadLetterTopic)
+	}, logger)
+}
+
+func getHealthController(conf config.Config, connpool *pgxpool.Pool, broker kafka_helpers.Broker, esclient elasticsearch_helpers.ElasticsearchClient, consumer *kafka_helpers.KafkaConsumer, logger *logrus.Logger) controllers.HealthController {
+	checks := []health.Check{
+		{
+			Name:     "postgres",
+			Critical: true,
+			Run: func(ctx context.Context) error {
+				_, err := connpool.Exec(ctx, "SELECT 1")
+				return err
+			},
+		},
+		{
+			Name:     "kafka",
+			Critical: true,
+			Run: func(ctx context.Context) error {
+				return broker.Ping(ctx, conf.Kafka.MainTopic)
+			},
+		},
+		{
+			Name:     "elasticsearch",
+			Critical: false,
+			Run:      esclient.ClusterHealth,
+		},
+		{
+			Name:     "kafka_consumer",
+			Critical: false,
+			Run: func(ctx context.Context) error {
+				return consumer.CheckLag(ctx, int64(conf.Health.MaxConsumerLag))
+			},
+		},
+	}
+
+	healthController := controllers.HealthController{
+		Checks:  checks,
+		Timeout: time.Duration(conf.Health.CheckTimeout) * time.Millisecond,
+	}
+
+	logger.Info("Health controller up and running")
+
+	return healthController
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"internship_project/logging"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	kafkaWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_producer_write_duration_seconds",
+		Help:    "Latency of Kafka producer writes by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	kafkaWriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_producer_write_errors_total",
+		Help: "Number of failed Kafka producer writes by topic.",
+	}, []string{"topic"})
+
+	kafkaRedeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_redeliveries_total",
+		Help: "Number of messages that failed and were moved to a retry or dead-letter topic, by source and destination topic.",
+	}, []string{"topic", "destination"})
+
+	kafkaSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_skipped_total",
+		Help: "Number of messages the consumer skipped because they were duplicates or older than the indexed document, by topic and reason.",
+	}, []string{"topic", "reason"})
+
+	esDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "elasticsearch_request_duration_seconds",
+		Help:    "Latency of Elasticsearch requests by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	esErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "elasticsearch_request_errors_total",
+		Help: "Number of failed Elasticsearch requests by operation.",
+	}, []string{"operation"})
+
+	accessCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "access_cache_lookups_total",
+		Help: "Number of access cache lookups by kind and result (hit, miss or bypass).",
+	}, []string{"kind", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, kafkaWriteDuration, kafkaWriteErrors, kafkaRedeliveries, kafkaSkipped, esDuration, esErrors, accessCacheLookups)
+}
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// HTTPMiddleware counts and times requests, labelled with the route template
+// of the matched mux route so that path parameters do not explode the number
+// of series.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r)
+
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// ObserveKafkaWrite records a producer write to topic that started at start.
+func ObserveKafkaWrite(topic string, start time.Time, err error) {
+	kafkaWriteDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		kafkaWriteErrors.WithLabelValues(topic).Inc()
+	}
+}
+
+// ObserveKafkaRedelivery records a message of topic that failed and was
+// moved to destination.
+func ObserveKafkaRedelivery(topic string, destination string) {
+	kafkaRedeliveries.WithLabelValues(topic, destination).Inc()
+}
+
+// ObserveKafkaSkip records a message of topic that was not applied for
+// reason.
+func ObserveKafkaSkip(topic string, reason string) {
+	kafkaSkipped.WithLabelValues(topic, reason).Inc()
+}
+
+// ObserveAccessCache records a lookup of kind in the access cache.
+func ObserveAccessCache(kind string, result string) {
+	accessCacheLookups.WithLabelValues(kind, result).Inc()
+}
+
+// ObserveElasticsearch records an Elasticsearch request that started at start.
+func ObserveElasticsearch(operation string, start time.Time, err error) {
+	esDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		esErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// offsetCollector exports a value that has to be looked up in Kafka on every
+// scrape, such as the lag of a consumer group.
+type offsetCollector struct {
+	desc    *prometheus.Desc
+	timeout time.Duration
+	read    func(ctx context.Context) (int64, error)
+	logger  *logrus.Entry
+}
+
+func (collector *offsetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.desc
+}
+
+func (collector *offsetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collector.timeout)
+	defer cancel()
+
+	value, err := collector.read(ctx)
+	if err != nil {
+		collector.logger.WithError(err).Warn("Unable to collect metric")
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(collector.desc, prometheus.GaugeValue, float64(value))
+}
+
+// RegisterConsumerLag exports the number of messages on topic that the
+// consumer group has not committed yet.
+func RegisterConsumerLag(topic string, timeout time.Duration, read func(ctx context.Context) (int64, error), logger *logrus.Logger) {
+	prometheus.MustRegister(&offsetCollector{
+		desc: prometheus.NewDesc("kafka_consumer_lag",
+			"Messages on the topic not yet committed by the consumer group.",
+			nil, prometheus.Labels{"topic": topic}),
+		timeout: timeout,
+		read:    read,
+		logger: logging.Component(logger, "metrics").WithFields(logrus.Fields{
+			"metric": "kafka_consumer_lag",
+			"topic":  topic,
+		}),
+	})
+}
+
+// RegisterRetryTopicDepth exports the number of messages waiting on a
+// retry tier or on the dead-letter topic.
+func RegisterRetryTopicDepth(topic string, timeout time.Duration, read func(ctx context.Context) (int64, error), logger *logrus.Logger) {
+	prometheus.MustRegister(&offsetCollector{
+		desc: prometheus.NewDesc("kafka_retry_topic_depth",
+			"Messages waiting on a retry or dead-letter topic.",
+			nil, prometheus.Labels{"topic": topic}),
+		timeout: timeout,
+		read:    read,
+		logger: logging.Component(logger, "metrics").WithFields(logrus.Fields{
+			"metric": "kafka_retry_topic_depth",
+			"topic":  topic,
+		}),
+	})
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	r := mux.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.HandleFunc("/product/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	t.Run("requests are labelled with the route template", func(t *testing.T) {
+		for _, id := range []string{"1", "2", "3"} {
+			req := httptest.NewRequest("GET", "/product/"+id, nil)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		assert.Equal(float64(3), testutil.ToFloat64(httpRequests.WithLabelValues("/product/{id}", "GET", "404")))
+		assert.Equal(float64(0), testutil.ToFloat64(httpRequests.WithLabelValues("/product/1", "GET", "404")))
+	})
+}
+
+func TestObserve(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("kafka write errors are counted", func(t *testing.T) {
+		ObserveKafkaWrite("test-topic", time.Now(), nil)
+		ObserveKafkaWrite("test-topic", time.Now(), errors.New("broker not available"))
+
+		assert.Equal(float64(1), testutil.ToFloat64(kafkaWriteErrors.WithLabelValues("test-topic")))
+	})
+
+	t.Run("elasticsearch errors are counted", func(t *testing.T) {
+		ObserveElasticsearch("search", time.Now(), errors.New("index_not_found_exception"))
+
+		assert.Equal(float64(1), testutil.ToFloat64(esErrors.WithLabelValues("search")))
+	})
+}
+
+func TestOffsetCollector(t *testing.T) {
+	assert := assert.New(t)
+
+	logger, hook := test.NewNullLogger()
+	collector := &offsetCollector{
+		desc:    prometheus.NewDesc("test_lag", "Test lag.", nil, nil),
+		timeout: time.Second,
+		read: func(ctx context.Context) (int64, error) {
+			return 0, errors.New("broker not available")
+		},
+		logger: logrus.NewEntry(logger),
+	}
+
+	assert.Equal(0, testutil.CollectAndCount(collector))
+	entry := hook.LastEntry()
+	assert.Equal(logrus.WarnLevel, entry.Level)
+	assert.EqualError(entry.Data[logrus.ErrorKey].(error), "broker not available")
+}
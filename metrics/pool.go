@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exports the statistics of a pgx connection pool.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+}
+
+// RegisterPool exports the statistics of pool.
+func RegisterPool(pool *pgxpool.Pool) {
+	prometheus.MustRegister(&poolCollector{
+		pool:                 pool,
+		acquireCount:         prometheus.NewDesc("pgxpool_acquire_count_total", "Number of successful connection acquires.", nil, nil),
+		acquireDuration:      prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil),
+		acquiredConns:        prometheus.NewDesc("pgxpool_acquired_conns", "Number of currently acquired connections.", nil, nil),
+		canceledAcquireCount: prometheus.NewDesc("pgxpool_canceled_acquire_count_total", "Number of acquires canceled by a context.", nil, nil),
+		constructingConns:    prometheus.NewDesc("pgxpool_constructing_conns", "Number of connections being established.", nil, nil),
+		emptyAcquireCount:    prometheus.NewDesc("pgxpool_empty_acquire_count_total", "Number of acquires that had to wait for a connection.", nil, nil),
+		idleConns:            prometheus.NewDesc("pgxpool_idle_conns", "Number of idle connections.", nil, nil),
+		maxConns:             prometheus.NewDesc("pgxpool_max_conns", "Maximum size of the pool.", nil, nil),
+		totalConns:           prometheus.NewDesc("pgxpool_total_conns", "Total number of connections in the pool.", nil, nil),
+	})
+}
+
+func (collector *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.acquireCount
+	ch <- collector.acquireDuration
+	ch <- collector.acquiredConns
+	ch <- collector.canceledAcquireCount
+	ch <- collector.constructingConns
+	ch <- collector.emptyAcquireCount
+	ch <- collector.idleConns
+	ch <- collector.maxConns
+	ch <- collector.totalConns
+}
+
+func (collector *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := collector.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(collector.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(collector.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(collector.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(collector.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(collector.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(collector.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+}
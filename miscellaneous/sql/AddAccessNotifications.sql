@@ -0,0 +1,48 @@
+-- The in-process access cache keeps employees and the access constraints of
+-- receiving companies until these triggers notify it of a change on the
+-- access_changes channel. Existing databases need them before upgrading,
+-- otherwise changes only show after access_cache.ttl. Databases that
+-- already have the other triggers need the function and the trigger on
+-- companies, which AddCompaniesDeletedAt.sql must have run before.
+
+CREATE OR REPLACE FUNCTION public.notify_access_change() RETURNS trigger AS $$
+DECLARE
+	changed record;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		changed := OLD;
+	ELSE
+		changed := NEW;
+	END IF;
+
+	IF TG_TABLE_NAME = 'employees' THEN
+		PERFORM pg_notify('access_changes', 'employee:' || changed.id);
+	ELSIF TG_TABLE_NAME = 'external_access_rights' THEN
+		PERFORM pg_notify('access_changes', 'company:' || changed.idrc);
+		IF TG_OP = 'UPDATE' AND OLD.idrc <> NEW.idrc THEN
+			PERFORM pg_notify('access_changes', 'company:' || OLD.idrc);
+		END IF;
+	ELSIF TG_TABLE_NAME = 'access_constraints' THEN
+		-- The right may already be gone when its constraints are deleted,
+		-- then every company is invalidated.
+		PERFORM pg_notify('access_changes', 'company:' || coalesce(
+			(SELECT ear.idrc::text FROM public.external_access_rights ear WHERE ear.id = changed.idear), ''));
+	ELSIF TG_TABLE_NAME = 'companies' THEN
+		-- Archiving or restoring a company hides or shows its products to
+		-- every company it shares them with.
+		PERFORM pg_notify('access_changes', 'company:' || ear.idrc)
+			FROM public.external_access_rights ear WHERE ear.idsc = changed.id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER employees_access_change AFTER INSERT OR UPDATE OR DELETE ON public.employees
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER external_access_rights_access_change AFTER INSERT OR UPDATE OR DELETE ON public.external_access_rights
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER access_constraints_access_change AFTER INSERT OR UPDATE OR DELETE ON public.access_constraints
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER companies_access_change AFTER UPDATE OF deleted_at ON public.companies
+	FOR EACH ROW WHEN (OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+	EXECUTE PROCEDURE public.notify_access_change();
@@ -0,0 +1,22 @@
+-- Replaying and discarding dead letters is recorded in the audit log, and
+-- the dead letters that were handled in dead_letter_resolutions. Existing
+-- databases need these tables before upgrading.
+
+CREATE TABLE IF NOT EXISTS public.audit_log (
+	id uuid NOT NULL,
+	actor varchar NOT NULL,
+	"action" varchar NOT NULL,
+	target varchar NOT NULL,
+	details jsonb NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT audit_log_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON public.audit_log USING btree (target);
+
+CREATE TABLE IF NOT EXISTS public.dead_letter_resolutions (
+	topic varchar NOT NULL,
+	message_id varchar NOT NULL,
+	status varchar NOT NULL,
+	resolved_at timestamptz NOT NULL,
+	CONSTRAINT dead_letter_resolutions_pk PRIMARY KEY (topic, message_id)
+);
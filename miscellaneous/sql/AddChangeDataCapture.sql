@@ -0,0 +1,14 @@
+-- Change data capture reads the changes of these tables from a logical
+-- replication slot. Postgres needs wal_level = logical, and the role in
+-- cdc.url the REPLICATION attribute. Full replica identity writes the whole
+-- old row for updates and deletes, so that deletions carry the product
+-- version and approval changes can be told from other updates. The name of
+-- the publication is cdc.publication.
+
+ALTER TABLE public.products REPLICA IDENTITY FULL;
+ALTER TABLE public.companies REPLICA IDENTITY FULL;
+ALTER TABLE public.external_access_rights REPLICA IDENTITY FULL;
+
+CREATE PUBLICATION ava_internship
+	FOR TABLE public.products, public.companies, public.external_access_rights
+	WITH (publish = 'insert, update, delete');
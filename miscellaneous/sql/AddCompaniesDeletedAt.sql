@@ -0,0 +1,4 @@
+-- Archived (soft deleted) companies keep their row until the restore grace
+-- period has passed. Existing databases need this column before upgrading.
+
+ALTER TABLE public.companies ADD COLUMN IF NOT EXISTS deleted_at timestamptz NULL;
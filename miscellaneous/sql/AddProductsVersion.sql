@@ -0,0 +1,5 @@
+-- Every change of a product increments its version, which product events
+-- carry so that Elasticsearch can ignore stale ones. Existing databases need
+-- this column before upgrading.
+
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS "version" int8 NOT NULL DEFAULT 1;
@@ -4,6 +4,7 @@ CREATE TABLE public.companies (
 	id uuid NOT NULL,
 	"name" varchar(30) NOT NULL,
 	ismain bool NOT NULL,
+	deleted_at timestamptz NULL,
 	CONSTRAINT companies_pk PRIMARY KEY (id)
 );
 
@@ -34,6 +35,7 @@ CREATE TABLE public.products (
 	price float4 NOT NULL,
 	quantity int4 NOT NULL,
 	idc uuid NOT NULL,
+	"version" int8 NOT NULL DEFAULT 1,
 	CONSTRAINT products_pk PRIMARY KEY (id)
 );
 
@@ -126,3 +128,78 @@ CREATE TABLE public.shops (
 
 ALTER TABLE public.shops ADD CONSTRAINT shops_fk FOREIGN KEY (idc) REFERENCES companies(id);
 
+
+-- public.audit_log definition
+
+-- Drop table
+
+-- DROP TABLE public.audit_log;
+
+CREATE TABLE public.audit_log (
+	id uuid NOT NULL,
+	actor varchar NOT NULL,
+	"action" varchar NOT NULL,
+	target varchar NOT NULL,
+	details jsonb NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT audit_log_pk PRIMARY KEY (id)
+);
+CREATE INDEX audit_log_target_idx ON public.audit_log USING btree (target);
+
+-- public.dead_letter_resolutions definition
+
+-- Drop table
+
+-- DROP TABLE public.dead_letter_resolutions;
+
+CREATE TABLE public.dead_letter_resolutions (
+	topic varchar NOT NULL,
+	message_id varchar NOT NULL,
+	status varchar NOT NULL,
+	resolved_at timestamptz NOT NULL,
+	CONSTRAINT dead_letter_resolutions_pk PRIMARY KEY (topic, message_id)
+);
+
+-- Access change notifications, see AddAccessNotifications.sql
+
+CREATE OR REPLACE FUNCTION public.notify_access_change() RETURNS trigger AS $$
+DECLARE
+	changed record;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		changed := OLD;
+	ELSE
+		changed := NEW;
+	END IF;
+
+	IF TG_TABLE_NAME = 'employees' THEN
+		PERFORM pg_notify('access_changes', 'employee:' || changed.id);
+	ELSIF TG_TABLE_NAME = 'external_access_rights' THEN
+		PERFORM pg_notify('access_changes', 'company:' || changed.idrc);
+		IF TG_OP = 'UPDATE' AND OLD.idrc <> NEW.idrc THEN
+			PERFORM pg_notify('access_changes', 'company:' || OLD.idrc);
+		END IF;
+	ELSIF TG_TABLE_NAME = 'access_constraints' THEN
+		-- The right may already be gone when its constraints are deleted,
+		-- then every company is invalidated.
+		PERFORM pg_notify('access_changes', 'company:' || coalesce(
+			(SELECT ear.idrc::text FROM public.external_access_rights ear WHERE ear.id = changed.idear), ''));
+	ELSIF TG_TABLE_NAME = 'companies' THEN
+		-- Archiving or restoring a company hides or shows its products to
+		-- every company it shares them with.
+		PERFORM pg_notify('access_changes', 'company:' || ear.idrc)
+			FROM public.external_access_rights ear WHERE ear.idsc = changed.id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER employees_access_change AFTER INSERT OR UPDATE OR DELETE ON public.employees
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER external_access_rights_access_change AFTER INSERT OR UPDATE OR DELETE ON public.external_access_rights
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER access_constraints_access_change AFTER INSERT OR UPDATE OR DELETE ON public.access_constraints
+	FOR EACH ROW EXECUTE PROCEDURE public.notify_access_change();
+CREATE TRIGGER companies_access_change AFTER UPDATE OF deleted_at ON public.companies
+	FOR EACH ROW WHEN (OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+	EXECUTE PROCEDURE public.notify_access_change();
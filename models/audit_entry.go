@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AuditEntry records who did what to which resource.
+type AuditEntry struct {
+	ID        string                 `json:"id"`
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	Target    string                 `json:"target"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DeadLetterPending   = "pending"
+	DeadLetterReplayed  = "replayed"
+	DeadLetterDiscarded = "discarded"
+)
+
+// DeadLetter is a message of the dead-letter topic. Its ID is made of its
+// partition and offset. The original partition and offset are -1 for
+// messages dead-lettered before they were recorded.
+type DeadLetter struct {
+	ID                string    `json:"id"`
+	Partition         int       `json:"partition"`
+	Offset            int64     `json:"offset"`
+	Key               string    `json:"key"`
+	EventType         string    `json:"event_type,omitempty"`
+	Error             string    `json:"error"`
+	Attempts          int       `json:"attempts"`
+	OriginalTopic     string    `json:"original_topic"`
+	OriginalPartition int       `json:"original_partition"`
+	OriginalOffset    int64     `json:"original_offset"`
+	FailedAt          time.Time `json:"failed_at"`
+	Status            string    `json:"status"`
+	Value             string    `json:"value,omitempty"`
+}
+
+// DeadLetterFilter selects dead letters to replay. Empty fields match every
+// dead letter.
+type DeadLetterFilter struct {
+	IDs           []string  `json:"ids"`
+	Key           string    `json:"key"`
+	EventType     string    `json:"event_type"`
+	ErrorContains string    `json:"error_contains"`
+	FailedAfter   time.Time `json:"failed_after"`
+	FailedBefore  time.Time `json:"failed_before"`
+}
+
+func (filter DeadLetterFilter) Matches(deadLetter DeadLetter) bool {
+	if len(filter.IDs) != 0 && !containsString(filter.IDs, deadLetter.ID) {
+		return false
+	}
+	if filter.Key != "" && filter.Key != deadLetter.Key {
+		return false
+	}
+	if filter.EventType != "" && filter.EventType != deadLetter.EventType {
+		return false
+	}
+	if filter.ErrorContains != "" && !strings.Contains(deadLetter.Error, filter.ErrorContains) {
+		return false
+	}
+	if !filter.FailedAfter.IsZero() && deadLetter.FailedAt.Before(filter.FailedAfter) {
+		return false
+	}
+	if !filter.FailedBefore.IsZero() && !deadLetter.FailedAt.Before(filter.FailedBefore) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProductFormat is a file format products are imported from and exported
+// to.
+type ProductFormat string
+
+const (
+	ProductFormatCSV    ProductFormat = "csv"
+	ProductFormatNDJSON ProductFormat = "ndjson"
+)
+
+// MaxReportedRowErrors limits the row errors an import result lists.
+const MaxReportedRowErrors = 100
+
+// maxNDJSONLine is the longest line an NDJSON import may have.
+const maxNDJSONLine = 1024 * 1024
+
+// productColumns are the columns of a CSV export. An import needs name,
+// price and quantity, id is ignored.
+var productColumns = []string{"id", "name", "price", "quantity", "idc"}
+
+// ParseProductFormat reads the format query parameter.
+func ParseProductFormat(value string) (ProductFormat, error) {
+	switch strings.ToLower(value) {
+	case "csv":
+		return ProductFormatCSV, nil
+	case "ndjson", "jsonl":
+		return ProductFormatNDJSON, nil
+	}
+	return "", errors.New("format must be csv or ndjson")
+}
+
+// ProductFormatOf returns the format of a media type, as sent in the
+// Content-Type or Accept header.
+func ProductFormatOf(mediaType string) (ProductFormat, bool) {
+	mediaType, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case "text/csv":
+		return ProductFormatCSV, true
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ProductFormatNDJSON, true
+	}
+	return "", false
+}
+
+func (format ProductFormat) ContentType() string {
+	if format == ProductFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Validate checks a product against the limits of the products table.
+func (product Product) Validate() error {
+	if strings.TrimSpace(product.Name) == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(product.Name) > 30 {
+		return errors.New("name can have at most 30 characters")
+	}
+	if math.IsNaN(float64(product.Price)) || math.IsInf(float64(product.Price), 0) || product.Price < 0 {
+		return errors.New("price must be a number that is not negative")
+	}
+	if product.Quantity < 0 {
+		return errors.New("quantity must not be negative")
+	}
+	return nil
+}
+
+// ProductRowError is a problem with one row of an import. The rows after it
+// can still be read.
+type ProductRowError struct {
+	// Row counts the products of the file from 1, without the CSV header
+	// and blank NDJSON lines.
+	Row     int    `json:"row"`
+	Message string `json:"error"`
+}
+
+func (err *ProductRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", err.Row, err.Message)
+}
+
+type ProductImportResult struct {
+	DryRun bool `json:"dry_run"`
+	Rows   int  `json:"rows"`
+	// Imported is zero unless every row is valid and it is no dry run.
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+	// Errors lists the first MaxReportedRowErrors of the failed rows.
+	Errors []ProductRowError `json:"errors"`
+}
+
+func (result *ProductImportResult) AddError(err ProductRowError) {
+	result.Failed++
+	if len(result.Errors) < MaxReportedRowErrors {
+		result.Errors = append(result.Errors, err)
+	}
+}
+
+// ProductDecoder reads the products of an import one row at a time.
+type ProductDecoder interface {
+	// Decode returns the next product, or io.EOF after the last one. A
+	// *ProductRowError is about that row only, any other error ends the
+	// import.
+	Decode() (Product, error)
+	// Row is the number of the row Decode returned last.
+	Row() int
+}
+
+func NewProductDecoder(format ProductFormat, r io.Reader) ProductDecoder {
+	if format == ProductFormatNDJSON {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+		return &ndjsonProductDecoder{scanner: scanner}
+	}
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	return &csvProductDecoder{reader: reader}
+}
+
+type csvProductDecoder struct {
+	reader *csv.Reader
+	// columns maps the column names of the header to their index.
+	columns map[string]int
+	row     int
+}
+
+func (decoder *csvProductDecoder) Row() int {
+	return decoder.row
+}
+
+func (decoder *csvProductDecoder) Decode() (Product, error) {
+	if decoder.columns == nil {
+		err := decoder.readHeader()
+		if err != nil {
+			return Product{}, err
+		}
+	}
+
+	record, err := decoder.reader.Read()
+	if err == io.EOF {
+		return Product{}, err
+	}
+	decoder.row++
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Product{}, &ProductRowError{Row: decoder.row, Message: parseErr.Err.Error()}
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	value := func(column string) string {
+		index, ok := decoder.columns[column]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	product := Product{Name: value("name"), IDC: value("idc")}
+	price, err := strconv.ParseFloat(value("price"), 32)
+	if err != nil {
+		return Product{}, &ProductRowError{Row: decoder.row, Message: "price must be a number"}
+	}
+	product.Price = float32(price)
+	quantity, err := strconv.ParseInt(value("quantity"), 10, 32)
+	if err != nil {
+		return Product{}, &ProductRowError{Row: decoder.row, Message: "quantity must be a whole number"}
+	}
+	product.Quantity = int32(quantity)
+	return product, nil
+}
+
+func (decoder *csvProductDecoder) readHeader() error {
+	header, err := decoder.reader.Read()
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read the CSV header: %v", err)
+	}
+
+	columns := map[string]int{}
+	for index, column := range header {
+		if index == 0 {
+			// Spreadsheets often start UTF-8 files with a byte order mark.
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !isProductColumn(column) {
+			return fmt.Errorf("Unknown CSV column %q, the columns are %s", column, strings.Join(productColumns, ", "))
+		}
+		if _, ok := columns[column]; ok {
+			return fmt.Errorf("The CSV column %q appears twice", column)
+		}
+		columns[column] = index
+	}
+	for _, column := range []string{"name", "price", "quantity"} {
+		if _, ok := columns[column]; !ok {
+			return errors.New("The CSV header needs the columns name, price and quantity")
+		}
+	}
+	decoder.columns = columns
+	return nil
+}
+
+func isProductColumn(column string) bool {
+	for _, productColumn := range productColumns {
+		if column == productColumn {
+			return true
+		}
+	}
+	return false
+}
+
+type ndjsonProductDecoder struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+// ndjsonProduct tells missing values from zero ones.
+type ndjsonProduct struct {
+	Name     *string  `json:"name"`
+	Price    *float32 `json:"price"`
+	Quantity *int32   `json:"quantity"`
+	IDC      string   `json:"idc"`
+}
+
+func (decoder *ndjsonProductDecoder) Row() int {
+	return decoder.row
+}
+
+func (decoder *ndjsonProductDecoder) Decode() (Product, error) {
+	var line []byte
+	for len(line) == 0 {
+		if !decoder.scanner.Scan() {
+			err := decoder.scanner.Err()
+			if err == bufio.ErrTooLong {
+				return Product{}, fmt.Errorf("Row %d is longer than %d bytes", decoder.row+1, maxNDJSONLine)
+			}
+			if err != nil {
+				return Product{}, err
+			}
+			return Product{}, io.EOF
+		}
+		line = bytes.TrimSpace(decoder.scanner.Bytes())
+	}
+	decoder.row++
+
+	var row ndjsonProduct
+	err := json.Unmarshal(line, &row)
+	if err != nil {
+		return Product{}, &ProductRowError{Row: decoder.row, Message: "not a valid product: " + err.Error()}
+	}
+	if row.Name == nil || row.Price == nil || row.Quantity == nil {
+		return Product{}, &ProductRowError{Row: decoder.row, Message: "name, price and quantity are required"}
+	}
+	return Product{Name: *row.Name, Price: *row.Price, Quantity: *row.Quantity, IDC: row.IDC}, nil
+}
+
+// ProductEncoder writes the products of an export.
+type ProductEncoder interface {
+	Encode(Product) error
+	// Close writes what is still buffered. A CSV export without products
+	// still gets its header.
+	Close() error
+}
+
+func NewProductEncoder(format ProductFormat, w io.Writer) ProductEncoder {
+	if format == ProductFormatNDJSON {
+		buffered := bufio.NewWriter(w)
+		return &ndjsonProductEncoder{writer: buffered, encoder: json.NewEncoder(buffered)}
+	}
+	return &csvProductEncoder{writer: csv.NewWriter(w)}
+}
+
+type csvProductEncoder struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (encoder *csvProductEncoder) writeHeader() error {
+	if encoder.headerWritten {
+		return nil
+	}
+	encoder.headerWritten = true
+	return encoder.writer.Write(productColumns)
+}
+
+func (encoder *csvProductEncoder) Encode(product Product) error {
+	err := encoder.writeHeader()
+	if err != nil {
+		return err
+	}
+	return encoder.writer.Write([]string{
+		product.ID,
+		product.Name,
+		strconv.FormatFloat(float64(product.Price), 'f', -1, 32),
+		strconv.FormatInt(int64(product.Quantity), 10),
+		product.IDC,
+	})
+}
+
+func (encoder *csvProductEncoder) Close() error {
+	err := encoder.writeHeader()
+	if err != nil {
+		return err
+	}
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
+
+type ndjsonProductEncoder struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (encoder *ndjsonProductEncoder) Encode(product Product) error {
+	return encoder.encoder.Encode(product)
+}
+
+func (encoder *ndjsonProductEncoder) Close() error {
+	return encoder.writer.Flush()
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeAll reads every row, collecting the products and row errors.
+func decodeAll(decoder ProductDecoder) ([]Product, []string, error) {
+	products := []Product{}
+	rowErrors := []string{}
+	for {
+		product, err := decoder.Decode()
+		if err == io.EOF {
+			return products, rowErrors, nil
+		}
+		if rowErr, ok := err.(*ProductRowError); ok {
+			rowErrors = append(rowErrors, rowErr.Error())
+			continue
+		}
+		if err != nil {
+			return products, rowErrors, err
+		}
+		products = append(products, product)
+	}
+}
+
+func TestProductDecoder(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("csv", func(t *testing.T) {
+		input := "\ufeffName, Price,quantity\nMilk,1.5,10\n\"Bread, white\",2,0\n"
+
+		products, rowErrors, err := decodeAll(NewProductDecoder(ProductFormatCSV, strings.NewReader(input)))
+
+		assert.NoError(err)
+		assert.Empty(rowErrors)
+		assert.Equal([]Product{
+			{Name: "Milk", Price: 1.5, Quantity: 10},
+			{Name: "Bread, white", Price: 2, Quantity: 0},
+		}, products)
+	})
+
+	t.Run("csv row errors", func(t *testing.T) {
+		input := "name,price,quantity,idc\nMilk,cheap,10,company-1\nMilk,1.5\nMilk,1.5,2.5,company-1\nBread,2,3,company-1\n"
+
+		products, rowErrors, err := decodeAll(NewProductDecoder(ProductFormatCSV, strings.NewReader(input)))
+
+		assert.NoError(err)
+		assert.Equal([]Product{{Name: "Bread", Price: 2, Quantity: 3, IDC: "company-1"}}, products)
+		assert.Equal([]string{
+			"row 1: price must be a number",
+			"row 2: wrong number of fields",
+			"row 3: quantity must be a whole number",
+		}, rowErrors)
+	})
+
+	t.Run("csv header", func(t *testing.T) {
+		for _, header := range []string{"name,price\n", "name,price,quantity,colour\n", "name,price,quantity,name\n"} {
+			_, _, err := decodeAll(NewProductDecoder(ProductFormatCSV, strings.NewReader(header+"Milk,1.5,10\n")))
+
+			assert.Error(err, header)
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		for _, format := range []ProductFormat{ProductFormatCSV, ProductFormatNDJSON} {
+			products, _, err := decodeAll(NewProductDecoder(format, strings.NewReader("")))
+
+			assert.NoError(err)
+			assert.Empty(products)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		input := `{"name":"Milk","price":1.5,"quantity":10}` + "\n\n" +
+			`{"name":"Bread","price":2}` + "\n" +
+			`{"name":"Bread","price":"2","quantity":1}` + "\n" +
+			`{"id":"ignored","name":"Bread","price":2,"quantity":0,"idc":"company-1"}`
+
+		decoder := NewProductDecoder(ProductFormatNDJSON, strings.NewReader(input))
+		products, rowErrors, err := decodeAll(decoder)
+
+		assert.NoError(err)
+		assert.Equal(4, decoder.Row())
+		assert.Equal([]Product{
+			{Name: "Milk", Price: 1.5, Quantity: 10},
+			{Name: "Bread", Price: 2, Quantity: 0, IDC: "company-1"},
+		}, products)
+		assert.Len(rowErrors, 2)
+		assert.Equal("row 2: name, price and quantity are required", rowErrors[0])
+		assert.Contains(rowErrors[1], "row 3: not a valid product")
+	})
+
+	t.Run("ndjson line too long", func(t *testing.T) {
+		input := `{"name":"` + strings.Repeat("a", maxNDJSONLine) + `"}`
+
+		_, _, err := decodeAll(NewProductDecoder(ProductFormatNDJSON, strings.NewReader(input)))
+
+		assert.Error(err)
+	})
+}
+
+func TestProductEncoder(t *testing.T) {
+	assert := assert.New(t)
+
+	products := []Product{
+		{ID: "product-1", Name: "Milk", Price: 1.1, Quantity: 10, IDC: "company-1"},
+		{ID: "product-2", Name: "Bread, white", Price: 2, Quantity: 0, IDC: "company-1"},
+	}
+
+	encode := func(format ProductFormat, products []Product) string {
+		var buff bytes.Buffer
+		encoder := NewProductEncoder(format, &buff)
+		for _, product := range products {
+			assert.NoError(encoder.Encode(product))
+		}
+		assert.NoError(encoder.Close())
+		return buff.String()
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		assert.Equal("id,name,price,quantity,idc\n"+
+			"product-1,Milk,1.1,10,company-1\n"+
+			"product-2,\"Bread, white\",2,0,company-1\n", encode(ProductFormatCSV, products))
+	})
+
+	t.Run("csv without products", func(t *testing.T) {
+		assert.Equal("id,name,price,quantity,idc\n", encode(ProductFormatCSV, nil))
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		assert.Equal(`{"id":"product-1","name":"Milk","price":1.1,"quantity":10,"idc":"company-1"}`+"\n"+
+			`{"id":"product-2","name":"Bread, white","price":2,"quantity":0,"idc":"company-1"}`+"\n", encode(ProductFormatNDJSON, products))
+	})
+
+	t.Run("exports can be imported", func(t *testing.T) {
+		for _, format := range []ProductFormat{ProductFormatCSV, ProductFormatNDJSON} {
+			imported, _, err := decodeAll(NewProductDecoder(format, strings.NewReader(encode(format, products))))
+
+			assert.NoError(err)
+			assert.Len(imported, 2)
+			assert.Equal(products[1].Name, imported[1].Name)
+			assert.Equal(products[0].Price, imported[0].Price)
+		}
+	})
+}
+
+func TestProductValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := Product{Name: "Milk", Price: 1.5, Quantity: 10}
+	assert.NoError(valid.Validate())
+
+	invalid := []Product{
+		{Name: " ", Price: 1.5, Quantity: 10},
+		{Name: strings.Repeat("ž", 31), Price: 1.5, Quantity: 10},
+		{Name: "Milk", Price: -1, Quantity: 10},
+		{Name: "Milk", Price: 1.5, Quantity: -1},
+	}
+	for _, product := range invalid {
+		assert.Error(product.Validate(), product.Name)
+	}
+}
+
+func TestProductFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := ParseProductFormat("JSONL")
+	assert.NoError(err)
+	assert.Equal(ProductFormatNDJSON, format)
+
+	_, err = ParseProductFormat("xlsx")
+	assert.Error(err)
+
+	format, ok := ProductFormatOf("text/csv; charset=utf-8")
+	assert.True(ok)
+	assert.Equal(ProductFormatCSV, format)
+
+	_, ok = ProductFormatOf("application/json")
+	assert.False(ok)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	SortByRelevance = "relevance"
+	SortByPrice     = "price"
+	SortByQuantity  = "quantity"
+)
+
+// ProductSearchRequest describes a product search. Empty fields do not
+// restrict the search.
+type ProductSearchRequest struct {
+	// Query is matched against the product name, tolerating typos.
+	Query       string   `json:"query"`
+	MinPrice    *float32 `json:"min_price,omitempty"`
+	MaxPrice    *float32 `json:"max_price,omitempty"`
+	MinQuantity *int32   `json:"min_quantity,omitempty"`
+	MaxQuantity *int32   `json:"max_quantity,omitempty"`
+	// CompanyIDs limits the hits, but not the facets, to these companies.
+	CompanyIDs []string `json:"company_ids,omitempty"`
+	// Sort is one of SortByRelevance, SortByPrice or SortByQuantity, Order
+	// is "asc" or "desc".
+	Sort  string `json:"sort"`
+	Order string `json:"order"`
+	Size  int    `json:"size"`
+	// SearchAfter holds the sort values of the last hit of the previous
+	// page.
+	SearchAfter   []interface{} `json:"search_after,omitempty"`
+	PriceInterval float64       `json:"price_interval"`
+}
+
+type ProductSearchResult struct {
+	Total int64              `json:"total"`
+	Hits  []ProductSearchHit `json:"hits"`
+	// SearchAfter is passed with the request for the next page. It is empty
+	// on the last page.
+	SearchAfter    []interface{}     `json:"search_after,omitempty"`
+	Companies      []FacetBucket     `json:"companies"`
+	PriceHistogram []HistogramBucket `json:"price_histogram"`
+}
+
+type ProductSearchHit struct {
+	Product Product `json:"product"`
+	Score   float64 `json:"score"`
+	// Highlights holds the fragments of every field that matched, with the
+	// matching terms in <em> tags.
+	Highlights map[string][]string `json:"highlights,omitempty"`
+	Sort       []interface{}       `json:"sort"`
+}
+
+type FacetBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+type HistogramBucket struct {
+	From  float64 `json:"from"`
+	Count int64   `json:"count"`
+}
+
+// Normalize fills in the defaults of a search request and checks it.
+func (request *ProductSearchRequest) Normalize() error {
+	switch request.Sort {
+	case "":
+		request.Sort = SortByRelevance
+	case SortByRelevance, SortByPrice, SortByQuantity:
+	default:
+		return fmt.Errorf("Unknown sort %q, expected relevance, price or quantity", request.Sort)
+	}
+
+	switch request.Order {
+	case "":
+		request.Order = "asc"
+		if request.Sort == SortByRelevance {
+			request.Order = "desc"
+		}
+	case "asc", "desc":
+	default:
+		return fmt.Errorf("Unknown order %q, expected asc or desc", request.Order)
+	}
+
+	if request.Size == 0 {
+		request.Size = 20
+	}
+	if request.Size < 0 || request.Size > 100 {
+		return errors.New("The page size must be between 1 and 100")
+	}
+
+	if request.PriceInterval == 0 {
+		request.PriceInterval = 10
+	}
+	if request.PriceInterval < 0 {
+		return errors.New("The price interval must be positive")
+	}
+
+	if request.MinPrice != nil && request.MaxPrice != nil && *request.MinPrice > *request.MaxPrice {
+		return errors.New("The minimum price is greater than the maximum price")
+	}
+	if request.MinQuantity != nil && request.MaxQuantity != nil && *request.MinQuantity > *request.MaxQuantity {
+		return errors.New("The minimum quantity is greater than the maximum quantity")
+	}
+	return nil
+}
+
+// ProductSuggestion is a product whose name starts with, or has a word
+// starting with, the typed prefix.
+type ProductSuggestion struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Text is the part of the name that matched.
+	Text string `json:"text"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeProductSearchRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("defaults", func(t *testing.T) {
+		request := ProductSearchRequest{}
+		assert.NoError(request.Normalize())
+		assert.Equal(SortByRelevance, request.Sort)
+		assert.Equal("desc", request.Order)
+		assert.Equal(20, request.Size)
+
+		request = ProductSearchRequest{Sort: SortByPrice}
+		assert.NoError(request.Normalize())
+		assert.Equal("asc", request.Order)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		min, max := float32(10), float32(1)
+		for _, request := range []ProductSearchRequest{
+			{Sort: "name"},
+			{Order: "up"},
+			{Size: 101},
+			{PriceInterval: -1},
+			{MinPrice: &min, MaxPrice: &max},
+		} {
+			assert.Error(request.Normalize())
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
+	"internship_project/kafka_helpers"
+	"internship_project/models"
+	"internship_project/repositories"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reindex rebuilds the product index from Postgres into a new versioned
+// index and points the product alias to it, while the running consumers keep
+// writing to the old one. Events written to the main topic since the rebuild
+// started are applied to the new index before and right after the swap, so
+// that it misses nothing. Thanks to external versions, replaying events that
+// the snapshot already contains changes nothing. Reading the topic starts
+// catchUpFrom before the snapshot to cover events of transactions that were
+// not committed yet when it was taken.
+func reindex(conf config.Config, catchUpFrom time.Duration, logger *logrus.Logger) error {
+	ctx := context.Background()
+	since := time.Now().Add(-catchUpFrom)
+
+	connpool := getConnectionPool(conf.Database, logger)
+	defer connpool.Close()
+	broker, err := kafka_helpers.NewKafkaBroker(conf.Kafka)
+	if err != nil {
+		return err
+	}
+	publisher := kafka_helpers.NewEventPublisher(conf.Kafka, broker, logger)
+	defer publisher.Close()
+	productRepository := repositories.NewProductRepo(connpool, repositories.NewUnitOfWork(connpool, false), publisher, logger)
+
+	esClient := elasticsearch_helpers.GetElasticsearchClient(conf.Elasticsearch, logger)
+	index, err := esClient.NextIndex(ctx)
+	if err != nil {
+		return err
+	}
+	err = esClient.CreateIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	target := esClient.WithIndex(index)
+
+	indexed, err := loadProducts(ctx, productRepository, &target, conf.Kafka.BatchSize)
+	if err != nil {
+		return fmt.Errorf("Unable to load products into %s: %w", index, err)
+	}
+	logger.WithFields(logrus.Fields{"index": index, "products": indexed}).Info("Loaded products")
+
+	// Catch up until little enough is left that the swap does not leave a
+	// noticeable gap.
+	catchUp := kafka_helpers.NewCatchUp(conf.Kafka, broker, &target, repositories.ActiveCompanies(connpool), logger)
+	for {
+		read, err := catchUp.Run(ctx, since)
+		if err != nil {
+			return fmt.Errorf("Unable to apply events to %s: %w", index, err)
+		}
+		logger.WithFields(logrus.Fields{"index": index, "messages": read}).Info("Applied events written during the rebuild")
+		if read < conf.Kafka.BatchSize {
+			break
+		}
+	}
+
+	err = target.Refresh(ctx, index)
+	if err != nil {
+		return err
+	}
+	previous, err := esClient.SwapAlias(ctx, elasticsearch_helpers.ProductIndex, index)
+	if err != nil {
+		return err
+	}
+
+	// Events that went to the old index before the swap.
+	read, err := catchUp.Run(ctx, since)
+	if err != nil {
+		return fmt.Errorf("Unable to apply events to %s after the swap: %w", index, err)
+	}
+	logger.WithFields(logrus.Fields{
+		"index":    index,
+		"messages": read,
+		"previous": previous,
+	}).Info("Reindexed products, the previous indices can be deleted")
+	return nil
+}
+
+func loadProducts(ctx context.Context, repository repositories.ProductRepository, index elasticsearch_helpers.SearchIndex, batchSize int) (int, error) {
+	indexed := 0
+	operations := make([]elasticsearch_helpers.BulkOperation, 0, batchSize)
+	flush := func() error {
+		if len(operations) == 0 {
+			return nil
+		}
+		results, err := index.Bulk(ctx, operations)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result != nil {
+				return fmt.Errorf("product %s: %w", operations[i].ID, result)
+			}
+		}
+		indexed += len(operations)
+		operations = operations[:0]
+		return nil
+	}
+
+	err := repository.ForEachProduct(ctx, func(product models.Product, version int64) error {
+		body, err := elasticsearch_helpers.ProductDocument(product)
+		if err != nil {
+			return err
+		}
+		operations = append(operations, elasticsearch_helpers.BulkOperation{
+			Action:  elasticsearch_helpers.BulkIndex,
+			ID:      product.ID,
+			Body:    body,
+			Version: version,
+		})
+		if len(operations) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return indexed, err
+	}
+	return indexed, flush()
+}
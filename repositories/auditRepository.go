@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"internship_project/logging"
+	"internship_project/models"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type AuditRepository interface {
+	AddEntry(context.Context, *models.AuditEntry) error
+	GetEntries(context.Context, string) ([]models.AuditEntry, error)
+}
+
+type auditRepository struct {
+	DB     *pgxpool.Pool
+	Logger *logrus.Entry
+}
+
+func NewAuditRepo(db *pgxpool.Pool, logger *logrus.Logger) AuditRepository {
+	if db == nil {
+		panic("AuditRepository not created, pgxpool is nil")
+	}
+	return &auditRepository{
+		DB:     db,
+		Logger: logging.Component(logger, "auditRepository"),
+	}
+}
+
+// AddEntry writes entry to the audit trail. Within a unit of work the entry
+// is only kept if the audited change is committed.
+func (repository *auditRepository) AddEntry(ctx context.Context, entry *models.AuditEntry) error {
+	tx, err := beginTx(ctx, repository.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = uuid.NewV4().String()
+	entry.CreatedAt = time.Now().UTC()
+
+	_, err = tx.Exec(ctx, `INSERT INTO audit_log (id, actor, "action", target, details, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`, entry.ID, entry.Actor, entry.Action, entry.Target, details, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetEntries returns the audit trail of target, newest first.
+func (repository *auditRepository) GetEntries(ctx context.Context, target string) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+	rows, err := repository.DB.Query(ctx, `select id::text, actor, "action", target, details, created_at
+	from audit_log where target = $1 order by created_at desc`, target)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		var details []byte
+		err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &details, &entry.CreatedAt)
+		if err != nil {
+			return entries, err
+		}
+		if len(details) != 0 {
+			err = json.Unmarshal(details, &entry.Details)
+			if err != nil {
+				return entries, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -2,22 +2,31 @@ package repositories
 
 import (
 	"context"
-	"github.com/segmentio/kafka-go"
+	"errors"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 )
 
 type CompanyRepository interface {
-	GetAllCompanies() ([]models.Company, error)
-	GetCompany(string) (models.Company, error)
-	AddCompany(*models.Company) error
-	UpdateCompany(models.Company) error
-	DeleteCompany(string) error
-	ChangeExternalRightApproveStatus(string, bool) error
+	GetAllCompanies(context.Context) ([]models.Company, error)
+	GetCompany(context.Context, string) (models.Company, error)
+	AddCompany(context.Context, *models.Company) error
+	UpdateCompany(context.Context, models.Company) error
+	DeleteCompany(context.Context, string) error
+	ArchiveCompany(context.Context, string) error
+	RestoreCompany(context.Context, string, time.Time) error
+	GetCompaniesArchivedBefore(context.Context, time.Time) ([]string, error)
+	ChangeExternalRightApproveStatus(context.Context, string, bool) error
 }
 
 type companyRepository struct {
@@ -25,23 +34,37 @@ type companyRepository struct {
 	ProductRepo        ProductRepository
 	ExternalRightsRepo ExternalRightRepository
 	EmployeeRepo       EmployeeRepository
+	ShopRepo           ShopRepository
+	UnitOfWork         UnitOfWork
+	publisher          *kafka_helpers.EventPublisher
+	Logger             *logrus.Entry
 }
 
-func NewCompanyRepo(db *pgxpool.Pool, writer *kafka.Writer) CompanyRepository {
+func NewCompanyRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) CompanyRepository {
 	if db == nil {
 		panic("CompanyRepository not created, pgxpool is nil")
 	}
+	if unitOfWork == nil {
+		panic("CompanyRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("CompanyRepository not created, publisher is nil")
+	}
 	return &companyRepository{
 		DB:                 db,
-		ProductRepo:        NewProductRepo(db, writer),
-		ExternalRightsRepo: NewExternalRightRepo(db),
-		EmployeeRepo:       NewEmployeeRepo(db),
+		ProductRepo:        NewProductRepo(db, unitOfWork, publisher, logger),
+		ExternalRightsRepo: NewExternalRightRepo(db, unitOfWork, publisher, logger),
+		EmployeeRepo:       NewEmployeeRepo(db, unitOfWork, publisher, logger),
+		ShopRepo:           NewShopRepo(db, unitOfWork, publisher, logger),
+		UnitOfWork:         unitOfWork,
+		publisher:          publisher,
+		Logger:             logging.Component(logger, "companyRepository"),
 	}
 }
 
-func (repository *companyRepository) GetAllCompanies() ([]models.Company, error) {
+func (repository *companyRepository) GetAllCompanies(ctx context.Context) ([]models.Company, error) {
 	companies := []models.Company{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.companies")
+	rows, err := repository.DB.Query(ctx, "select * from public.companies where deleted_at is null")
 	defer rows.Close()
 
 	if err != nil {
@@ -67,7 +90,7 @@ func (repository *companyRepository) GetAllCompanies() ([]models.Company, error)
 	return companies, nil
 }
 
-func (repository *companyRepository) GetCompany(id string) (models.Company, error) {
+func (repository *companyRepository) GetCompany(ctx context.Context, id string) (models.Company, error) {
 	var company models.Company
 
 	Uuid, err := uuid.FromString(id)
@@ -75,7 +98,7 @@ func (repository *companyRepository) GetCompany(id string) (models.Company, erro
 		return company, err
 	}
 
-	rows, err := repository.DB.Query(context.Background(), `select * from public.companies where id = $1`, Uuid)
+	rows, err := repository.DB.Query(ctx, `select * from public.companies where id = $1 and deleted_at is null`, Uuid)
 	defer rows.Close()
 
 	if err != nil {
@@ -104,99 +127,278 @@ func (repository *companyRepository) GetCompany(id string) (models.Company, erro
 	return company, nil
 }
 
-func (repository *companyRepository) AddCompany(company *models.Company) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *companyRepository) AddCompany(ctx context.Context, company *models.Company) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	company.ID = uuid.NewV4().String()
-	companyPers := persistence.Companies{
-		Name:   company.Name,
-		Ismain: company.IsMain,
-	}
-	companyPers.Id.Set(company.ID)
+		company.ID = uuid.NewV4().String()
+		companyPers := persistence.Companies{
+			Name:   company.Name,
+			Ismain: company.IsMain,
+		}
+		companyPers.Id.Set(company.ID)
 
-	_, err = companyPers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		_, err = companyPers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
+
+		err = repository.publisher.Publish(ctx, company.ID, &events.CompanyCreated{Company: *company})
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *companyRepository) UpdateCompany(company models.Company) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *companyRepository) UpdateCompany(ctx context.Context, company models.Company) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	companyPers := persistence.Companies{
-		Name:   company.Name,
-		Ismain: company.IsMain,
-	}
-	companyPers.Id.Set(company.ID)
+		companyPers := persistence.Companies{
+			Name:   company.Name,
+			Ismain: company.IsMain,
+		}
+		companyPers.Id.Set(company.ID)
 
-	commandTag, err := companyPers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		commandTag, err := companyPers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, company.ID, &events.CompanyUpdated{Company: company})
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *companyRepository) DeleteCompany(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+// DeleteCompany removes a company, archived or not, together with everything
+// that refers to it, in a single transaction.
+func (repository *companyRepository) DeleteCompany(ctx context.Context, id string) error {
+	err := repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		// The foreign keys reference the company, so its rows go last.
+		err := repository.ExternalRightsRepo.DeleteExternalRightsForCompany(ctx, id)
+		if err != nil {
+			return err
+		}
 
-	companyPers := persistence.Companies{}
-	companyPers.Id.Set(id)
+		err = repository.EmployeeRepo.DeleteEmployeesFromCompany(ctx, id)
+		if err != nil {
+			return err
+		}
 
-	commandTag, err := companyPers.DeleteTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		err = repository.ProductRepo.DeleteProductsFromCompany(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		err = repository.ShopRepo.DeleteShopsFromCompany(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		companyPers := persistence.Companies{}
+		companyPers.Id.Set(id)
+
+		commandTag, err := companyPers.DeleteTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.CompanyDeleted{ID: id})
+		if err != nil {
+			return err
+		}
 
-	// Fallback
-	err = repository.ProductRepo.DeleteProductsFromCompany(id)
+		return tx.Commit(ctx)
+	})
 	if err != nil {
-		tx.Rollback(context.Background())
 		return err
 	}
 
-	err = repository.EmployeeRepo.DeleteEmployeesFromCompany(id)
+	repository.Logger.WithContext(ctx).WithField("company_id", id).Info("Deleted company with its products, employees, shops and external rights")
+	return nil
+}
+
+// ArchiveCompany soft deletes a company. It disappears from every company
+// query but keeps its row, and the rows referring to it, until it is
+// restored or deleted for good. Its products are hidden from product queries
+// and removed from the search index.
+func (repository *companyRepository) ArchiveCompany(ctx context.Context, id string) error {
+	err := repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		var archivedAt time.Time
+		err = tx.QueryRow(ctx, `UPDATE public.companies SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`, id).Scan(&archivedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return utils.NoDataError
+		}
+		if err != nil {
+			return err
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.CompanyArchived{ID: id, ArchivedAt: archivedAt})
+		if err != nil {
+			return err
+		}
+		err = repository.publisher.Publish(ctx, id, &events.ProductsArchivedForCompany{CompanyID: id})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 	if err != nil {
-		tx.Rollback(context.Background())
 		return err
 	}
 
-	err = repository.ExternalRightsRepo.DeleteExternalRightsForCompany(id)
+	repository.Logger.WithContext(ctx).WithField("company_id", id).Info("Archived company")
+	return nil
+}
+
+// RestoreCompany undoes ArchiveCompany for a company archived after
+// archivedAfter, and publishes its products again.
+func (repository *companyRepository) RestoreCompany(ctx context.Context, id string, archivedAfter time.Time) error {
+	err := repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		var archivedAt *time.Time
+		err = tx.QueryRow(ctx, `select deleted_at from public.companies where id = $1 for update`, id).Scan(&archivedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return utils.NoDataError
+		}
+		if err != nil {
+			return err
+		}
+		if archivedAt == nil {
+			return utils.NotArchivedError
+		}
+		if archivedAt.Before(archivedAfter) {
+			return utils.RestorePeriodExpiredError
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE public.companies SET deleted_at = NULL WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.CompanyRestored{ID: id})
+		if err != nil {
+			return err
+		}
+
+		err = repository.ProductRepo.RepublishProductsOfCompany(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 	if err != nil {
-		tx.Rollback(context.Background())
 		return err
 	}
 
-	return tx.Commit(context.Background())
+	repository.Logger.WithContext(ctx).WithField("company_id", id).Info("Restored company")
+	return nil
 }
 
-func (repository *companyRepository) ChangeExternalRightApproveStatus(idear string, status bool) error {
-	commandTag, err := repository.DB.Exec(context.Background(), "UPDATE external_access_rights SET approved = $1 WHERE id = $2;", status, idear)
+// GetCompaniesArchivedBefore returns the IDs of the companies archived before
+// archivedBefore.
+func (repository *companyRepository) GetCompaniesArchivedBefore(ctx context.Context, archivedBefore time.Time) ([]string, error) {
+	ids := []string{}
+	rows, err := repository.DB.Query(ctx, `select id::text from public.companies where deleted_at < $1`, archivedBefore)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if commandTag.RowsAffected() != 1 {
-		return utils.NoDataError
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
 	}
-	return nil
+	return ids, rows.Err()
+}
+
+// ActiveCompanies returns the filter with which the consumer indexes only the
+// products of companies that exist and are not archived.
+func ActiveCompanies(db *pgxpool.Pool) kafka_helpers.CompanyFilter {
+	return func(ctx context.Context, ids []string) (map[string]bool, error) {
+		active := map[string]bool{}
+		rows, err := db.Query(ctx, `select id::text from public.companies where id::text = any($1::text[]) and deleted_at is null`, ids)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			err := rows.Scan(&id)
+			if err != nil {
+				return nil, err
+			}
+			active[id] = true
+		}
+		return active, rows.Err()
+	}
+}
+
+func (repository *companyRepository) ChangeExternalRightApproveStatus(ctx context.Context, idear string, status bool) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		commandTag, err := tx.Exec(ctx, "UPDATE external_access_rights SET approved = $1 WHERE id = $2;", status, idear)
+		if err != nil {
+			return err
+		}
+		if commandTag.RowsAffected() != 1 {
+			return utils.NoDataError
+		}
+		err = repository.publisher.Publish(ctx, idear, &events.ExternalRightApprovalChanged{ID: idear, Approved: status})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
@@ -1,9 +1,11 @@
 package repositories
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/utils"
 	"testing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
@@ -15,14 +17,14 @@ func TestAddCompany(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := CompanyRepo.AddCompany(&utils.TestCompany)
+		err := CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
 		assert.Error(err, "Error was not thrown while inserting in non-existing table")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		oldCompanies, _ := CompanyRepo.GetAllCompanies()
-		err := CompanyRepo.AddCompany(&utils.TestCompany)
-		newCompanies, _ := CompanyRepo.GetAllCompanies()
+		oldCompanies, _ := CompanyRepo.GetAllCompanies(context.Background())
+		err := CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
+		newCompanies, _ := CompanyRepo.GetAllCompanies(context.Background())
 
 		assert.NoError(err)
 		assert.Equal(1, len(newCompanies)-len(oldCompanies), "Company was not added.")
@@ -35,7 +37,7 @@ func TestGetAllCompanies(t *testing.T) {
 	assert := assert.New(t)
 
 	t.Run("successful query", func(t *testing.T) {
-		allCompanies, err := CompanyRepo.GetAllCompanies()
+		allCompanies, err := CompanyRepo.GetAllCompanies(context.Background())
 
 		assert.NoError(err, "Error was thrown while reading companies")
 		assert.NotNil(allCompanies, "Companies returned are nil.")
@@ -50,25 +52,25 @@ func TestGetCompany(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		_, err := CompanyRepo.GetCompany(uuid.NewV4().String())
+		_, err := CompanyRepo.GetCompany(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while getting from non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		_, err := CompanyRepo.GetCompany(uuid)
+		_, err := CompanyRepo.GetCompany(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		_, err := CompanyRepo.GetCompany(uuid)
+		_, err := CompanyRepo.GetCompany(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		CompanyRepo.AddCompany(&utils.TestCompany)
-		company, err := CompanyRepo.GetCompany(utils.TestCompany.ID)
+		CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
+		company, err := CompanyRepo.GetCompany(context.Background(), utils.TestCompany.ID)
 		assert.NotNil(company, "Result is nil")
 		assert.NoError(err, "There was error while getting company")
 		assert.Equal(utils.TestCompany.ID, company.ID, "Returned company ID and test ID do not match.")
@@ -81,28 +83,28 @@ func TestUpdateCompany(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := CompanyRepo.UpdateCompany(utils.TestCompany)
+		err := CompanyRepo.UpdateCompany(context.Background(), utils.TestCompany)
 		assert.Error(err, "Error was not thrown while updating in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
 		utils.TestCompany.ID = uuid
-		err := CompanyRepo.UpdateCompany(utils.TestCompany)
+		err := CompanyRepo.UpdateCompany(context.Background(), utils.TestCompany)
 		assert.NotNil(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
 		utils.TestCompany.ID = uuid
-		err := CompanyRepo.UpdateCompany(utils.TestCompany)
+		err := CompanyRepo.UpdateCompany(context.Background(), utils.TestCompany)
 		assert.NotNil(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		CompanyRepo.AddCompany(&utils.TestCompany)
+		CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
 		utils.TestCompany.Name = "Updated name"
-		err := CompanyRepo.UpdateCompany(utils.TestCompany)
+		err := CompanyRepo.UpdateCompany(context.Background(), utils.TestCompany)
 		assert.NoError(err, "Company was not updated.")
 	})
 
@@ -114,26 +116,166 @@ func TestDeleteCompany(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := CompanyRepo.DeleteCompany(uuid.NewV4().String())
+		err := CompanyRepo.DeleteCompany(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while deleting in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		err := CompanyRepo.DeleteCompany(uuid)
+		err := CompanyRepo.DeleteCompany(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		err := CompanyRepo.DeleteCompany(uuid)
+		err := CompanyRepo.DeleteCompany(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		CompanyRepo.AddCompany(&utils.TestCompany)
-		err := CompanyRepo.DeleteCompany(utils.TestCompany.ID)
+		CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
+		companyEvents := TopicSize(KafkaConf.CompanyTopic)
+		err := CompanyRepo.DeleteCompany(context.Background(), utils.TestCompany.ID)
 		assert.NoError(err, "Company was not deleted.")
+		assert.Equal(companyEvents+1, TopicSize(KafkaConf.CompanyTopic))
+	})
+
+	t.Run("every deleted row is published", func(t *testing.T) {
+		defer utils.SetUpTables(Connpool)
+		count := func(query string) int64 {
+			var rows int64
+			err := Connpool.QueryRow(context.Background(), query, utils.TestCompany1.ID).Scan(&rows)
+			assert.NoError(err)
+			return rows
+		}
+		employees := count(`select count(*) from employees where idc = $1`)
+		externalRights := count(`select count(*) from external_access_rights where idsc = $1 or idrc = $1`)
+		constraints := count(`select count(*) from access_constraints where idear in
+		(select id from external_access_rights where idsc = $1 or idrc = $1)`)
+		employeeEvents := TopicSize(KafkaConf.EmployeeTopic)
+		externalRightEvents := TopicSize(KafkaConf.ExternalRightTopic)
+		constraintEvents := TopicSize(KafkaConf.ConstraintTopic)
+
+		err := CompanyRepo.DeleteCompany(context.Background(), utils.TestCompany1.ID)
+
+		assert.NoError(err)
+		assert.NotZero(employees)
+		assert.NotZero(externalRights)
+		assert.Equal(employeeEvents+employees, TopicSize(KafkaConf.EmployeeTopic))
+		assert.Equal(externalRightEvents+externalRights, TopicSize(KafkaConf.ExternalRightTopic))
+		assert.Equal(constraintEvents+constraints, TopicSize(KafkaConf.ConstraintTopic))
+	})
+
+	t.Run("no events when a later step fails", func(t *testing.T) {
+		defer utils.SetUpTables(Connpool)
+		productEvents := TopicSize(KafkaConf.MainTopic)
+
+		err := NewUnitOfWork(Connpool, false).Do(context.Background(), func(ctx context.Context) error {
+			err := ProductRepo.DeleteProductsFromCompany(ctx, utils.TestCompany1.ID)
+			if err != nil {
+				return err
+			}
+			return utils.NoDataError
+		})
+
+		assert.Error(err)
+		assert.Equal(productEvents, TopicSize(KafkaConf.MainTopic))
+	})
+
+	t.Run("no events when the commit fails", func(t *testing.T) {
+		defer utils.SetUpTables(Connpool)
+		productEvents := TopicSize(KafkaConf.MainTopic)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		err := NewUnitOfWork(Connpool, false).Do(ctx, func(ctx context.Context) error {
+			err := ProductRepo.DeleteProductsFromCompany(ctx, utils.TestCompany1.ID)
+			cancel()
+			return err
+		})
+
+		assert.Error(err)
+		assert.Equal(productEvents, TopicSize(KafkaConf.MainTopic))
+	})
+}
+
+func TestArchiveCompany(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("non-existing uuid", func(t *testing.T) {
+		err := CompanyRepo.ArchiveCompany(context.Background(), uuid.NewV4().String())
+		assert.Equal(utils.NoDataError, err)
+	})
+
+	t.Run("archived company is hidden", func(t *testing.T) {
+		CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
+		err := CompanyRepo.ArchiveCompany(context.Background(), utils.TestCompany.ID)
+		assert.NoError(err, "Company was not archived.")
+
+		_, err = CompanyRepo.GetCompany(context.Background(), utils.TestCompany.ID)
+		assert.Equal(utils.NoDataError, err)
+	})
+
+	t.Run("already archived", func(t *testing.T) {
+		err := CompanyRepo.ArchiveCompany(context.Background(), utils.TestCompany.ID)
+		assert.Equal(utils.NoDataError, err)
+	})
+
+	t.Run("products of an archived company are hidden until it is restored", func(t *testing.T) {
+		defer utils.SetUpTables(Connpool)
+		productEvents := TopicSize(KafkaConf.MainTopic)
+
+		err := CompanyRepo.ArchiveCompany(context.Background(), utils.TestCompany1.ID)
+		assert.NoError(err)
+		assert.Equal(productEvents+1, TopicSize(KafkaConf.MainTopic))
+
+		constraints, err := ProductRepo.GetReadConstraints(context.Background(), utils.TestCompany2.ID)
+		assert.NoError(err)
+		assert.Empty(constraints)
+		products, err := ProductRepo.GetAllProducts(context.Background(), utils.TestCompany2.ID)
+		assert.NoError(err)
+		for _, product := range products {
+			assert.NotEqual(utils.TestCompany1.ID, product.IDC)
+		}
+		_, err = ProductRepo.GetProduct(context.Background(), utils.TestProduct.ID, utils.TestCompany1.ID)
+		assert.Error(err)
+
+		err = CompanyRepo.RestoreCompany(context.Background(), utils.TestCompany1.ID, time.Now().Add(-time.Hour))
+		assert.NoError(err)
+		// Every product of the company is published again.
+		assert.Equal(productEvents+4, TopicSize(KafkaConf.MainTopic))
+		products, err = ProductRepo.GetAllProducts(context.Background(), utils.TestCompany1.ID)
+		assert.NoError(err)
+		assert.Len(products, 3)
+	})
+}
+
+func TestRestoreCompany(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("not archived", func(t *testing.T) {
+		err := CompanyRepo.RestoreCompany(context.Background(), utils.TestCompany1.ID, time.Now().Add(-time.Hour))
+		assert.Equal(utils.NotArchivedError, err)
+	})
+
+	t.Run("grace period expired", func(t *testing.T) {
+		CompanyRepo.AddCompany(context.Background(), &utils.TestCompany)
+		CompanyRepo.ArchiveCompany(context.Background(), utils.TestCompany.ID)
+
+		err := CompanyRepo.RestoreCompany(context.Background(), utils.TestCompany.ID, time.Now().Add(time.Hour))
+		assert.Equal(utils.RestorePeriodExpiredError, err)
+
+		ids, err := CompanyRepo.GetCompaniesArchivedBefore(context.Background(), time.Now().Add(time.Hour))
+		assert.NoError(err)
+		assert.Contains(ids, utils.TestCompany.ID)
+	})
+
+	t.Run("successful query", func(t *testing.T) {
+		err := CompanyRepo.RestoreCompany(context.Background(), utils.TestCompany.ID, time.Now().Add(-time.Hour))
+		assert.NoError(err, "Company was not restored.")
+
+		_, err = CompanyRepo.GetCompany(context.Background(), utils.TestCompany.ID)
+		assert.NoError(err)
 	})
 }
 
@@ -143,24 +285,24 @@ func TestChangeExternalRightApproveStatus(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := CompanyRepo.ChangeExternalRightApproveStatus(uuid.NewV4().String(), true)
+		err := CompanyRepo.ChangeExternalRightApproveStatus(context.Background(), uuid.NewV4().String(), true)
 		assert.Error(err, "Error was not thrown while updating ear in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		err := CompanyRepo.ChangeExternalRightApproveStatus(uuid, true)
+		err := CompanyRepo.ChangeExternalRightApproveStatus(context.Background(), uuid, true)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		err := CompanyRepo.ChangeExternalRightApproveStatus(uuid, true)
+		err := CompanyRepo.ChangeExternalRightApproveStatus(context.Background(), uuid, true)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		err := CompanyRepo.ChangeExternalRightApproveStatus(utils.Ear1to2Disapproved.ID, true)
+		err := CompanyRepo.ChangeExternalRightApproveStatus(context.Background(), utils.Ear1to2Disapproved.ID, true)
 		assert.NoError(err, "Ear was not approved.")
 	})
 }
@@ -3,39 +3,55 @@ package repositories
 import (
 	"context"
 	"errors"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 )
 
 type ConstraintRepository interface {
-	GetAllConstraints() ([]models.AccessConstraint, error)
-	GetConstraint(string) (models.AccessConstraint, error)
-	AddConstraint(*models.AccessConstraint) error
-	UpdateConstraint(models.AccessConstraint) error
-	DeleteConstraint(string) error
-	DeleteConstraintsForCompany(string) error
+	GetAllConstraints(context.Context) ([]models.AccessConstraint, error)
+	GetConstraint(context.Context, string) (models.AccessConstraint, error)
+	AddConstraint(context.Context, *models.AccessConstraint) error
+	UpdateConstraint(context.Context, models.AccessConstraint) error
+	DeleteConstraint(context.Context, string) error
+	DeleteConstraintsForCompany(context.Context, string) error
 }
 
 type constraintRepository struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	UnitOfWork UnitOfWork
+	publisher  *kafka_helpers.EventPublisher
+	Logger     *logrus.Entry
 }
 
-func NewConstraintRepo(db *pgxpool.Pool) ConstraintRepository {
+func NewConstraintRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) ConstraintRepository {
 	if db == nil {
 		panic("ConstraintRepository not created, pgxpool is nil")
 	}
+	if unitOfWork == nil {
+		panic("ConstraintRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("ConstraintRepository not created, publisher is nil")
+	}
 	return &constraintRepository{
-		DB: db,
+		DB:         db,
+		UnitOfWork: unitOfWork,
+		publisher:  publisher,
+		Logger:     logging.Component(logger, "constraintRepository"),
 	}
 }
 
-func (repository *constraintRepository) GetAllConstraints() ([]models.AccessConstraint, error) {
+func (repository *constraintRepository) GetAllConstraints(ctx context.Context) ([]models.AccessConstraint, error) {
 	constraints := []models.AccessConstraint{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.access_constraints")
+	rows, err := repository.DB.Query(ctx, "select * from public.access_constraints")
 	defer rows.Close()
 
 	if err != nil {
@@ -69,7 +85,7 @@ func (repository *constraintRepository) GetAllConstraints() ([]models.AccessCons
 	return constraints, nil
 }
 
-func (repository *constraintRepository) GetConstraint(id string) (models.AccessConstraint, error) {
+func (repository *constraintRepository) GetConstraint(ctx context.Context, id string) (models.AccessConstraint, error) {
 	var constraint models.AccessConstraint
 
 	Uuid, err := uuid.FromString(id)
@@ -77,7 +93,7 @@ func (repository *constraintRepository) GetConstraint(id string) (models.AccessC
 		return constraint, err
 	}
 
-	rows, err := repository.DB.Query(context.Background(), `select * from access_constraints where id = $1`, Uuid)
+	rows, err := repository.DB.Query(ctx, `select * from access_constraints where id = $1`, Uuid)
 	defer rows.Close()
 
 	if err != nil {
@@ -114,93 +130,136 @@ func (repository *constraintRepository) GetConstraint(id string) (models.AccessC
 	return constraint, nil
 }
 
-func (repository *constraintRepository) AddConstraint(constraint *models.AccessConstraint) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *constraintRepository) AddConstraint(ctx context.Context, constraint *models.AccessConstraint) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	constraint.ID = uuid.NewV4().String()
-	constraintPers := persistence.AccessConstraints{
-		OperatorId:    constraint.OperatorID,
-		PropertyId:    constraint.PropertyID,
-		PropertyValue: constraint.PropertyValue,
-	}
-	constraintPers.Id.Set(constraint.ID)
-	constraintPers.Idear.Set(constraint.IDEAR)
+		constraint.ID = uuid.NewV4().String()
+		constraintPers := persistence.AccessConstraints{
+			OperatorId:    constraint.OperatorID,
+			PropertyId:    constraint.PropertyID,
+			PropertyValue: constraint.PropertyValue,
+		}
+		constraintPers.Id.Set(constraint.ID)
+		constraintPers.Idear.Set(constraint.IDEAR)
 
-	_, err = constraintPers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		_, err = constraintPers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, constraint.ID, &events.ConstraintCreated{Constraint: *constraint})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *constraintRepository) UpdateConstraint(constraint models.AccessConstraint) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *constraintRepository) UpdateConstraint(ctx context.Context, constraint models.AccessConstraint) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	constraintPers := persistence.AccessConstraints{
-		OperatorId:    constraint.OperatorID,
-		PropertyId:    constraint.PropertyID,
-		PropertyValue: constraint.PropertyValue,
-	}
-	constraintPers.Id.Set(constraint.ID)
-	constraintPers.Idear.Set(constraint.IDEAR)
+		constraintPers := persistence.AccessConstraints{
+			OperatorId:    constraint.OperatorID,
+			PropertyId:    constraint.PropertyID,
+			PropertyValue: constraint.PropertyValue,
+		}
+		constraintPers.Id.Set(constraint.ID)
+		constraintPers.Idear.Set(constraint.IDEAR)
 
-	commandTag, err := constraintPers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		commandTag, err := constraintPers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, constraint.ID, &events.ConstraintUpdated{Constraint: constraint})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *constraintRepository) DeleteConstraint(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *constraintRepository) DeleteConstraint(ctx context.Context, id string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	constraintPers := persistence.AccessConstraints{}
-	constraintPers.Id.Set(id)
+		constraintPers := persistence.AccessConstraints{}
+		constraintPers.Id.Set(id)
 
-	commandTag, err := constraintPers.DeleteTx(&tx)
+		commandTag, err := constraintPers.DeleteTx(&tx)
 
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
-	return tx.Commit(context.Background())
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.ConstraintDeleted{ID: id})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *constraintRepository) DeleteConstraintsForCompany(idc string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *constraintRepository) DeleteConstraintsForCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	query := `DELETE FROM access_constraints ac where ac.idear in
-	(select id from external_access_rights where idrc = $1
-	or idsc = $1);`
+		rows, err := tx.Query(ctx, `DELETE FROM access_constraints ac where ac.idear in
+		(select id from external_access_rights where idrc = $1
+		or idsc = $1) RETURNING ac.id::text`, idc)
+		if err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(context.Background(), query, idc)
+		ids := []string{}
+		for rows.Next() {
+			var id string
+			err = rows.Scan(&id)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
 
-	if err != nil {
-		return err
-	}
+		for _, id := range ids {
+			err = repository.publisher.Publish(ctx, id, &events.ConstraintDeleted{ID: id})
+			if err != nil {
+				return err
+			}
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
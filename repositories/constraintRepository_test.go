@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/utils"
 	"testing"
@@ -15,14 +16,14 @@ func TestAddConstraint(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := ConstraintRepo.AddConstraint(&utils.TestConstraint)
+		err := ConstraintRepo.AddConstraint(context.Background(), &utils.TestConstraint)
 		assert.Error(err, "Error was not thrown while inserting in non-existing table")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		oldConstraints, _ := ConstraintRepo.GetAllConstraints()
-		err := ConstraintRepo.AddConstraint(&utils.TestConstraint)
-		newConstraints, _ := ConstraintRepo.GetAllConstraints()
+		oldConstraints, _ := ConstraintRepo.GetAllConstraints(context.Background())
+		err := ConstraintRepo.AddConstraint(context.Background(), &utils.TestConstraint)
+		newConstraints, _ := ConstraintRepo.GetAllConstraints(context.Background())
 
 		assert.NoError(err)
 		assert.Equal(1, len(newConstraints)-len(oldConstraints), "Constraint was not added.")
@@ -35,7 +36,7 @@ func TestGetAllConstraints(t *testing.T) {
 	assert := assert.New(t)
 
 	t.Run("successful query", func(t *testing.T) {
-		allConstraints, err := ConstraintRepo.GetAllConstraints()
+		allConstraints, err := ConstraintRepo.GetAllConstraints(context.Background())
 
 		assert.NoError(err, "Error was thrown while reading constraints")
 		assert.NotNil(allConstraints, "Constraints returned are nil.")
@@ -50,25 +51,25 @@ func TestGetConstraint(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		_, err := ConstraintRepo.GetConstraint(uuid.NewV4().String())
+		_, err := ConstraintRepo.GetConstraint(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while getting from non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		_, err := ConstraintRepo.GetConstraint(uuid)
+		_, err := ConstraintRepo.GetConstraint(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		_, err := ConstraintRepo.GetConstraint(uuid)
+		_, err := ConstraintRepo.GetConstraint(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		ConstraintRepo.AddConstraint(&utils.TestConstraint)
-		constraint, err := ConstraintRepo.GetConstraint(utils.TestConstraint.ID)
+		ConstraintRepo.AddConstraint(context.Background(), &utils.TestConstraint)
+		constraint, err := ConstraintRepo.GetConstraint(context.Background(), utils.TestConstraint.ID)
 		assert.NotNil(constraint, "Result is nil")
 		assert.NoError(err, "There was error while getting constraint")
 		assert.Equal(utils.TestConstraint.ID, constraint.ID, "Returned constraint ID and test ID do not match.")
@@ -81,28 +82,28 @@ func TestUpdateConstraint(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := ConstraintRepo.UpdateConstraint(utils.TestConstraint)
+		err := ConstraintRepo.UpdateConstraint(context.Background(), utils.TestConstraint)
 		assert.Error(err, "Error was not thrown while updating in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
 		utils.TestConstraint.ID = uuid
-		err := ConstraintRepo.UpdateConstraint(utils.TestConstraint)
+		err := ConstraintRepo.UpdateConstraint(context.Background(), utils.TestConstraint)
 		assert.NotNil(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
 		utils.TestConstraint.ID = uuid
-		err := ConstraintRepo.UpdateConstraint(utils.TestConstraint)
+		err := ConstraintRepo.UpdateConstraint(context.Background(), utils.TestConstraint)
 		assert.NotNil(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		ConstraintRepo.AddConstraint(&utils.TestConstraint)
+		ConstraintRepo.AddConstraint(context.Background(), &utils.TestConstraint)
 		utils.TestConstraint.PropertyValue = 30
-		err := ConstraintRepo.UpdateConstraint(utils.TestConstraint)
+		err := ConstraintRepo.UpdateConstraint(context.Background(), utils.TestConstraint)
 		assert.NoError(err, "Constraint was not updated.")
 	})
 
@@ -114,25 +115,25 @@ func TestDeleteConstraint(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := ConstraintRepo.DeleteConstraint(uuid.NewV4().String())
+		err := ConstraintRepo.DeleteConstraint(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while deleting in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		err := ConstraintRepo.DeleteConstraint(uuid)
+		err := ConstraintRepo.DeleteConstraint(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		err := ConstraintRepo.DeleteConstraint(uuid)
+		err := ConstraintRepo.DeleteConstraint(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		ConstraintRepo.AddConstraint(&utils.TestConstraint)
-		err := ConstraintRepo.DeleteConstraint(utils.TestConstraint.ID)
+		ConstraintRepo.AddConstraint(context.Background(), &utils.TestConstraint)
+		err := ConstraintRepo.DeleteConstraint(context.Background(), utils.TestConstraint.ID)
 		assert.NoError(err, "Constraint was not deleted.")
 	})
 }
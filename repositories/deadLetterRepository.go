@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"internship_project/logging"
+	"internship_project/utils"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// DeadLetterRepository keeps track of the dead letters that were replayed or
+// discarded. Kafka cannot remove single messages from a topic, so they stay
+// on the dead-letter topic until its retention removes them.
+type DeadLetterRepository interface {
+	GetStatuses(context.Context, string) (map[string]string, error)
+	Resolve(context.Context, string, string, string) error
+}
+
+type deadLetterRepository struct {
+	DB     *pgxpool.Pool
+	Logger *logrus.Entry
+}
+
+func NewDeadLetterRepo(db *pgxpool.Pool, logger *logrus.Logger) DeadLetterRepository {
+	if db == nil {
+		panic("DeadLetterRepository not created, pgxpool is nil")
+	}
+	return &deadLetterRepository{
+		DB:     db,
+		Logger: logging.Component(logger, "deadLetterRepository"),
+	}
+}
+
+// GetStatuses returns the status of every resolved dead letter of topic by
+// its ID.
+func (repository *deadLetterRepository) GetStatuses(ctx context.Context, topic string) (map[string]string, error) {
+	statuses := map[string]string{}
+	rows, err := repository.DB.Query(ctx, `select message_id, status from dead_letter_resolutions where topic = $1`, topic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, status string
+		err := rows.Scan(&id, &status)
+		if err != nil {
+			return statuses, err
+		}
+		statuses[id] = status
+	}
+	return statuses, rows.Err()
+}
+
+// Resolve records that the dead letter with id was replayed or discarded. A
+// dead letter can only be resolved once.
+func (repository *deadLetterRepository) Resolve(ctx context.Context, topic string, id string, status string) error {
+	tx, err := beginTx(ctx, repository.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	commandTag, err := tx.Exec(ctx, `INSERT INTO dead_letter_resolutions (topic, message_id, status, resolved_at)
+	VALUES ($1, $2, $3, now()) ON CONFLICT DO NOTHING`, topic, id, status)
+	if err != nil {
+		return err
+	}
+	if commandTag.RowsAffected() != 1 {
+		return utils.AlreadyResolvedError
+	}
+
+	return tx.Commit(ctx)
+}
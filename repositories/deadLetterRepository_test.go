@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"internship_project/models"
+	"internship_project/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDeadLetter(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("successful query", func(t *testing.T) {
+		err := DeadLetterRepo.Resolve(context.Background(), "dead-letter", "0-1", models.DeadLetterReplayed)
+		assert.NoError(err)
+
+		statuses, err := DeadLetterRepo.GetStatuses(context.Background(), "dead-letter")
+		assert.NoError(err)
+		assert.Equal(models.DeadLetterReplayed, statuses["0-1"])
+	})
+
+	t.Run("already resolved", func(t *testing.T) {
+		err := DeadLetterRepo.Resolve(context.Background(), "dead-letter", "0-1", models.DeadLetterDiscarded)
+		assert.Equal(utils.AlreadyResolvedError, err)
+	})
+
+	t.Run("rolled back with its unit of work", func(t *testing.T) {
+		failed := errors.New("replay failed")
+		err := NewUnitOfWork(Connpool, false).Do(context.Background(), func(ctx context.Context) error {
+			err := DeadLetterRepo.Resolve(ctx, "dead-letter", "0-2", models.DeadLetterReplayed)
+			if err != nil {
+				return err
+			}
+			err = AuditRepo.AddEntry(ctx, &models.AuditEntry{Actor: "test", Action: "dead_letter.replay", Target: "dead-letter/0-2"})
+			if err != nil {
+				return err
+			}
+			return failed
+		})
+		assert.Equal(failed, err)
+
+		statuses, err := DeadLetterRepo.GetStatuses(context.Background(), "dead-letter")
+		assert.NoError(err)
+		assert.NotContains(statuses, "0-2")
+
+		entries, err := AuditRepo.GetEntries(context.Background(), "dead-letter/0-2")
+		assert.NoError(err)
+		assert.Empty(entries)
+	})
+}
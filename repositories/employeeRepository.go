@@ -3,43 +3,85 @@ package repositories
 import (
 	"context"
 	"errors"
+	"internship_project/cache"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 )
 
 type EmployeeRepository interface {
-	GetAllEmployees(string) ([]models.Employee, error)
-	GetEmployeeByID(id string) (models.Employee, error)
-	AddEmployee(*models.Employee) error
-	UpdateEmployee(models.Employee) error
-	DeleteEmployee(string) error
-	GetEmployeeExternalPermissions(string, models.Product) (models.ExternalRights, error)
-	CheckCompaniesSharingEmployeeData(string, string) error
-	DeleteEmployeesFromCompany(string) error
+	GetAllEmployees(context.Context, string) ([]models.Employee, error)
+	GetEmployeeByID(ctx context.Context, id string) (models.Employee, error)
+	AddEmployee(context.Context, *models.Employee) error
+	UpdateEmployee(context.Context, models.Employee) error
+	DeleteEmployee(context.Context, string) error
+	GetEmployeeExternalPermissions(context.Context, string, models.Product) (models.ExternalRights, error)
+	CheckCompaniesSharingEmployeeData(context.Context, string, string) error
+	DeleteEmployeesFromCompany(context.Context, string) error
 }
 
 type employeeRepository struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	UnitOfWork UnitOfWork
+	publisher  *kafka_helpers.EventPublisher
+	Logger     *logrus.Entry
 }
 
-func NewEmployeeRepo(db *pgxpool.Pool) EmployeeRepository {
+func NewEmployeeRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) EmployeeRepository {
 	if db == nil {
 		panic("EmployeeRepository not created, pgxpool is nil")
 	}
+	if unitOfWork == nil {
+		panic("EmployeeRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("EmployeeRepository not created, publisher is nil")
+	}
 	return &employeeRepository{
-		DB: db,
+		DB:         db,
+		UnitOfWork: unitOfWork,
+		publisher:  publisher,
+		Logger:     logging.Component(logger, "employeeRepository"),
+	}
+}
+
+// cachedEmployeeRepository looks employees up in the access cache, every
+// other call goes to the wrapped repository.
+type cachedEmployeeRepository struct {
+	EmployeeRepository
+	cache *cache.AccessCache
+}
+
+// NewCachedEmployeeRepo wraps repository so that GetEmployeeByID, which every
+// product request calls, is served from accessCache.
+func NewCachedEmployeeRepo(repository EmployeeRepository, accessCache *cache.AccessCache) EmployeeRepository {
+	if accessCache == nil {
+		panic("EmployeeRepository not created, access cache is nil")
+	}
+	return &cachedEmployeeRepository{
+		EmployeeRepository: repository,
+		cache:              accessCache,
 	}
 }
 
+func (repository *cachedEmployeeRepository) GetEmployeeByID(ctx context.Context, id string) (models.Employee, error) {
+	return repository.cache.Employee(id, func() (models.Employee, error) {
+		return repository.EmployeeRepository.GetEmployeeByID(ctx, id)
+	})
+}
+
 // GetAllEmployees .
-func (repository *employeeRepository) GetAllEmployees(employeeIdc string) ([]models.Employee, error) {
+func (repository *employeeRepository) GetAllEmployees(ctx context.Context, employeeIdc string) ([]models.Employee, error) {
 	allEmployees := []models.Employee{}
 	query := "select * from employees e where e.idc = $1 or idc in (select idsc from external_access_rights ear where ear.idrc = $2 and approved = true);"
-	rows, err := repository.DB.Query(context.Background(), query, employeeIdc, employeeIdc)
+	rows, err := repository.DB.Query(ctx, query, employeeIdc, employeeIdc)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -76,7 +118,7 @@ func (repository *employeeRepository) GetAllEmployees(employeeIdc string) ([]mod
 }
 
 // GetEmployeeByID .
-func (repository *employeeRepository) GetEmployeeByID(id string) (models.Employee, error) {
+func (repository *employeeRepository) GetEmployeeByID(ctx context.Context, id string) (models.Employee, error) {
 	var employee models.Employee
 
 	Uuid, err := uuid.FromString(id)
@@ -84,7 +126,7 @@ func (repository *employeeRepository) GetEmployeeByID(id string) (models.Employe
 		return employee, err
 	}
 
-	rows, err := repository.DB.Query(context.Background(), "select * from employees where id=$1", Uuid)
+	rows, err := repository.DB.Query(ctx, "select * from employees where id=$1", Uuid)
 	defer rows.Close()
 
 	if err != nil {
@@ -125,94 +167,115 @@ func (repository *employeeRepository) GetEmployeeByID(id string) (models.Employe
 }
 
 // AddEmployee .
-func (repository *employeeRepository) AddEmployee(employee *models.Employee) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *employeeRepository) AddEmployee(ctx context.Context, employee *models.Employee) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	employee.ID = uuid.NewV4().String()
+		employee.ID = uuid.NewV4().String()
 
-	employeePers := persistence.Employees{
-		Firstname: employee.FirstName,
-		Lastname:  employee.LastName,
-		C:         employee.C,
-		R:         employee.R,
-		U:         employee.U,
-		D:         employee.D,
-	}
-	employeePers.Idc.Set(employee.CompanyID)
-	employeePers.Id.Set(employee.ID)
+		employeePers := persistence.Employees{
+			Firstname: employee.FirstName,
+			Lastname:  employee.LastName,
+			C:         employee.C,
+			R:         employee.R,
+			U:         employee.U,
+			D:         employee.D,
+		}
+		employeePers.Idc.Set(employee.CompanyID)
+		employeePers.Id.Set(employee.ID)
 
-	_, err = employeePers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		_, err = employeePers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
+
+		err = repository.publisher.Publish(ctx, employee.ID, &events.EmployeeCreated{Employee: *employee})
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
 // UpdateEmployee .
-func (repository *employeeRepository) UpdateEmployee(employee models.Employee) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
-
-	employeePers := persistence.Employees{
-		Firstname: employee.FirstName,
-		Lastname:  employee.LastName,
-		C:         employee.C,
-		R:         employee.R,
-		U:         employee.U,
-		D:         employee.D,
-	}
-	employeePers.Idc.Set(employee.CompanyID)
-	employeePers.Id.Set(employee.ID)
+func (repository *employeeRepository) UpdateEmployee(ctx context.Context, employee models.Employee) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	commandTag, err := employeePers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		employeePers := persistence.Employees{
+			Firstname: employee.FirstName,
+			Lastname:  employee.LastName,
+			C:         employee.C,
+			R:         employee.R,
+			U:         employee.U,
+			D:         employee.D,
+		}
+		employeePers.Idc.Set(employee.CompanyID)
+		employeePers.Id.Set(employee.ID)
+
+		commandTag, err := employeePers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, employee.ID, &events.EmployeeUpdated{Employee: employee})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
 // DeleteEmployee .
-func (repository *employeeRepository) DeleteEmployee(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *employeeRepository) DeleteEmployee(ctx context.Context, id string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	employeePers := persistence.Employees{}
-	employeePers.Id.Set(id)
+		employeePers := persistence.Employees{}
+		employeePers.Id.Set(id)
 
-	commandTag, err := employeePers.DeleteTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		commandTag, err := employeePers.DeleteTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, id, &events.EmployeeDeleted{ID: id})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
 // GetEmployeeExternalPermissions .
-func (repository *employeeRepository) GetEmployeeExternalPermissions(idReceivingCompany string, product models.Product) (models.ExternalRights, error) {
+func (repository *employeeRepository) GetEmployeeExternalPermissions(ctx context.Context, idReceivingCompany string, product models.Product) (models.ExternalRights, error) {
 	allRights := []models.ExternalRights{}
 	var rights models.ExternalRights
 
 	// 1. Using idReceivingCompany and idSharingCompany, acquire all external access rules for these two companies
 	queryExternalAccess := "SELECT * FROM external_access_rights WHERE idrc = $1 AND idsc = $2 AND approved = true;"
-	rows, err := repository.DB.Query(context.Background(), queryExternalAccess, idReceivingCompany, product.IDC)
+	rows, err := repository.DB.Query(ctx, queryExternalAccess, idReceivingCompany, product.IDC)
 	defer rows.Close()
 
 	if err != nil {
@@ -267,7 +330,7 @@ func (repository *employeeRepository) GetEmployeeExternalPermissions(idReceiving
 	default:
 		// 2b. Otherwise, we need to acquire constraints, using ID of all constraints
 		for _, right := range allRights {
-			rows, err := repository.DB.Query(context.Background(), `select * from access_constraints where idear = $1`, right.ID)
+			rows, err := repository.DB.Query(ctx, `select * from access_constraints where idear = $1`, right.ID)
 			defer rows.Close()
 
 			if err != nil {
@@ -314,13 +377,13 @@ func (repository *employeeRepository) GetEmployeeExternalPermissions(idReceiving
 }
 
 // CheckCompaniesSharingEmployeeData .
-func (repository *employeeRepository) CheckCompaniesSharingEmployeeData(idReceivingCompany, idSharingCompany string) error {
+func (repository *employeeRepository) CheckCompaniesSharingEmployeeData(ctx context.Context, idReceivingCompany, idSharingCompany string) error {
 	allRights := []models.ExternalRights{}
 
 	// 1. Using idReceivingCompany and idSharingCompany, acquire all external access rules for these two companies
 
 	queryExternalAccess := "SELECT * FROM external_access_rights WHERE idrc = $1 AND idsc = $2 AND approved = true;"
-	rows, err := repository.DB.Query(context.Background(), queryExternalAccess, idReceivingCompany, idSharingCompany)
+	rows, err := repository.DB.Query(ctx, queryExternalAccess, idReceivingCompany, idSharingCompany)
 	defer rows.Close()
 
 	if err != nil {
@@ -386,20 +449,41 @@ func checkConstraint(accessConstraint models.AccessConstraint, product models.Pr
 	}
 }
 
-func (repository *employeeRepository) DeleteEmployeesFromCompany(idc string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *employeeRepository) DeleteEmployeesFromCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	query := `DELETE FROM employees WHERE idc=$1`
+		rows, err := tx.Query(ctx, `DELETE FROM employees WHERE idc=$1 RETURNING id::text`, idc)
+		if err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(context.Background(), query, idc)
+		ids := []string{}
+		for rows.Next() {
+			var id string
+			err = rows.Scan(&id)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
 
-	if err != nil {
-		return err
-	}
+		for _, id := range ids {
+			err = repository.publisher.Publish(ctx, id, &events.EmployeeDeleted{ID: id})
+			if err != nil {
+				return err
+			}
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/utils"
 	"testing"
@@ -16,14 +17,14 @@ func TestAddEmployee(t *testing.T) {
 		defer utils.SetUpTables(Connpool)
 
 		assert.False(DoesTableExist("employees", Connpool))
-		err := EmployeeRepo.AddEmployee(&utils.Employee1Company1)
+		err := EmployeeRepo.AddEmployee(context.Background(), &utils.Employee1Company1)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		oldEmployees, _ := EmployeeRepo.GetAllEmployees(utils.AdminCompany1.CompanyID)
-		err := EmployeeRepo.AddEmployee(&utils.Employee1Company1)
-		newEmployees, _ := EmployeeRepo.GetAllEmployees(utils.AdminCompany1.CompanyID)
+		oldEmployees, _ := EmployeeRepo.GetAllEmployees(context.Background(), utils.AdminCompany1.CompanyID)
+		err := EmployeeRepo.AddEmployee(context.Background(), &utils.Employee1Company1)
+		newEmployees, _ := EmployeeRepo.GetAllEmployees(context.Background(), utils.AdminCompany1.CompanyID)
 
 		assert.NoError(err)
 		assert.Equal(1, len(newEmployees)-len(oldEmployees), "Employee was not added.")
@@ -31,7 +32,7 @@ func TestAddEmployee(t *testing.T) {
 
 	t.Run("add an existing employee", func(t *testing.T) {
 		existingEmployee := &models.Employee{ID: utils.Employee1Company1.ID}
-		err := EmployeeRepo.AddEmployee(existingEmployee)
+		err := EmployeeRepo.AddEmployee(context.Background(), existingEmployee)
 
 		assert.Error(err)
 	})
@@ -41,7 +42,7 @@ func TestGetAllEmployees(t *testing.T) {
 	assert := assert.New(t)
 
 	t.Run("successful GetAll query", func(t *testing.T) {
-		allEmployees, err := EmployeeRepo.GetAllEmployees(utils.AdminCompany1.ID)
+		allEmployees, err := EmployeeRepo.GetAllEmployees(context.Background(), utils.AdminCompany1.ID)
 
 		assert.NoError(err)
 		assert.NotNil(allEmployees, "Employees returned were nil.")
@@ -56,20 +57,20 @@ func TestGetEmployeeByID(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		_, err := EmployeeRepo.GetEmployeeByID(invalidID)
+		_, err := EmployeeRepo.GetEmployeeByID(context.Background(), invalidID)
 		assert.Error(err)
 	})
 
 	t.Run("id does not exist", func(t *testing.T) {
 		randomUUID := "c5ef08c6-60eb-4687-bcbb-df37ebc9e105"
 		assert.True(IsValidUUID(randomUUID))
-		_, err := EmployeeRepo.GetEmployeeByID(randomUUID)
+		_, err := EmployeeRepo.GetEmployeeByID(context.Background(), randomUUID)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
 		testID := utils.Employee1Company1.ID
-		employee, err := EmployeeRepo.GetEmployeeByID(testID)
+		employee, err := EmployeeRepo.GetEmployeeByID(context.Background(), testID)
 
 		assert.NoError(err)
 		assert.NotNil(employee, "Employee returned was nil.")
@@ -86,7 +87,7 @@ func TestUpdateEmployee(t *testing.T) {
 		invalidID := "123-asd-321"
 		invalidEmployee := models.Employee{ID: invalidID, FirstName: "Test", LastName: "Test", CompanyID: utils.Employee1Company1.CompanyID}
 		assert.False(IsValidUUID(invalidID))
-		err := EmployeeRepo.UpdateEmployee(invalidEmployee)
+		err := EmployeeRepo.UpdateEmployee(context.Background(), invalidEmployee)
 		assert.Error(err)
 	})
 
@@ -94,15 +95,15 @@ func TestUpdateEmployee(t *testing.T) {
 		randomUUID := "7d91a563-3386-4069-b785-09c52b5201b5"
 		randomEmployee := models.Employee{ID: randomUUID, FirstName: "Test", LastName: "Test", CompanyID: utils.Employee1Company1.CompanyID}
 		assert.True(IsValidUUID(randomUUID))
-		err := EmployeeRepo.UpdateEmployee(randomEmployee)
+		err := EmployeeRepo.UpdateEmployee(context.Background(), randomEmployee)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		employeeForUpdate, _ := EmployeeRepo.GetEmployeeByID(utils.Employee1Company1.ID)
+		employeeForUpdate, _ := EmployeeRepo.GetEmployeeByID(context.Background(), utils.Employee1Company1.ID)
 		employeeForUpdate.LastName = "UPDATED Last Name"
 
-		err := EmployeeRepo.UpdateEmployee(employeeForUpdate)
+		err := EmployeeRepo.UpdateEmployee(context.Background(), employeeForUpdate)
 
 		assert.NoError(err, "Employee was not updated.")
 	})
@@ -114,19 +115,19 @@ func TestDeleteEmployee(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		err := EmployeeRepo.DeleteEmployee(invalidID)
+		err := EmployeeRepo.DeleteEmployee(context.Background(), invalidID)
 		assert.Error(err)
 	})
 
 	t.Run("id does not exist", func(t *testing.T) {
 		randomUUID := "7d91a563-3386-4069-b785-09c52b5201b5"
 		assert.True(IsValidUUID(randomUUID))
-		err := EmployeeRepo.DeleteEmployee(randomUUID)
+		err := EmployeeRepo.DeleteEmployee(context.Background(), randomUUID)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		err := EmployeeRepo.DeleteEmployee(utils.Employee1Company1.ID)
+		err := EmployeeRepo.DeleteEmployee(context.Background(), utils.Employee1Company1.ID)
 
 		assert.NoError(err, "Employee was not deleted.")
 	})
@@ -138,19 +139,19 @@ func TestGetEmployeeExternalPermissions(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		_, err := EmployeeRepo.GetEmployeeExternalPermissions(invalidID, utils.TestProduct)
+		_, err := EmployeeRepo.GetEmployeeExternalPermissions(context.Background(), invalidID, utils.TestProduct)
 		assert.Error(err)
 	})
 
 	t.Run("company with id does not exist", func(t *testing.T) {
 		randomUUID := "7d91a563-3386-4069-b785-09c52b5201b5"
 		assert.True(IsValidUUID(randomUUID))
-		_, err := EmployeeRepo.GetEmployeeExternalPermissions(randomUUID, utils.TestProduct)
+		_, err := EmployeeRepo.GetEmployeeExternalPermissions(context.Background(), randomUUID, utils.TestProduct)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		_, err := EmployeeRepo.GetEmployeeExternalPermissions(utils.TestCompany2.ID, utils.TestProduct)
+		_, err := EmployeeRepo.GetEmployeeExternalPermissions(context.Background(), utils.TestCompany2.ID, utils.TestProduct)
 
 		assert.NoError(err, "Could not get employee's EAR")
 	})
@@ -162,19 +163,19 @@ func TestCheckCompaniesSharingEmployeeData(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(invalidID, utils.TestProduct.IDC)
+		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(context.Background(), invalidID, utils.TestProduct.IDC)
 		assert.Error(err)
 	})
 
 	t.Run("company with id does not exist", func(t *testing.T) {
 		randomUUID := "7d91a563-3386-4069-b785-09c52b5201b5"
 		assert.True(IsValidUUID(randomUUID))
-		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(randomUUID, utils.TestProduct.IDC)
+		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(context.Background(), randomUUID, utils.TestProduct.IDC)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(utils.TestCompany2.ID, utils.TestCompany1.ID)
+		err := EmployeeRepo.CheckCompaniesSharingEmployeeData(context.Background(), utils.TestCompany2.ID, utils.TestCompany1.ID)
 
 		assert.NoError(err, "Companies do not share data")
 	})
@@ -3,41 +3,57 @@ package repositories
 import (
 	"context"
 	"errors"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 )
 
 type ExternalRightRepository interface {
-	GetAllEars() ([]models.ExternalRights, error)
-	GetEar(id string) (models.ExternalRights, error)
-	AddEar(ear *models.ExternalRights) error
-	UpdateEar(ear models.ExternalRights) error
-	DeleteEar(id string) error
-	DeleteExternalRightsForCompany(string) error
+	GetAllEars(ctx context.Context) ([]models.ExternalRights, error)
+	GetEar(ctx context.Context, id string) (models.ExternalRights, error)
+	AddEar(ctx context.Context, ear *models.ExternalRights) error
+	UpdateEar(ctx context.Context, ear models.ExternalRights) error
+	DeleteEar(ctx context.Context, id string) error
+	DeleteExternalRightsForCompany(ctx context.Context, idc string) error
 }
 
 type externalRightRepository struct {
 	DB              *pgxpool.Pool
+	UnitOfWork      UnitOfWork
 	ConstraintsRepo ConstraintRepository
+	publisher       *kafka_helpers.EventPublisher
+	Logger          *logrus.Entry
 }
 
-func NewExternalRightRepo(db *pgxpool.Pool) ExternalRightRepository {
+func NewExternalRightRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) ExternalRightRepository {
 	if db == nil {
 		panic("ExternalRightRepository not created, pgxpool is nil")
 	}
+	if unitOfWork == nil {
+		panic("ExternalRightRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("ExternalRightRepository not created, publisher is nil")
+	}
 	return &externalRightRepository{
 		DB:              db,
-		ConstraintsRepo: NewConstraintRepo(db),
+		UnitOfWork:      unitOfWork,
+		ConstraintsRepo: NewConstraintRepo(db, unitOfWork, publisher, logger),
+		publisher:       publisher,
+		Logger:          logging.Component(logger, "externalRightRepository"),
 	}
 }
 
-func (repository *externalRightRepository) GetAllEars() ([]models.ExternalRights, error) {
+func (repository *externalRightRepository) GetAllEars(ctx context.Context) ([]models.ExternalRights, error) {
 	ears := []models.ExternalRights{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.external_access_rights")
+	rows, err := repository.DB.Query(ctx, "select * from public.external_access_rights")
 	defer rows.Close()
 
 	if err != nil {
@@ -79,7 +95,7 @@ func (repository *externalRightRepository) GetAllEars() ([]models.ExternalRights
 	return ears, nil
 }
 
-func (repository *externalRightRepository) GetEar(id string) (models.ExternalRights, error) {
+func (repository *externalRightRepository) GetEar(ctx context.Context, id string) (models.ExternalRights, error) {
 	var ear models.ExternalRights
 
 	Uuid, err := uuid.FromString(id)
@@ -87,7 +103,7 @@ func (repository *externalRightRepository) GetEar(id string) (models.ExternalRig
 		return ear, err
 	}
 
-	rows, err := repository.DB.Query(context.Background(), `select * from external_access_rights where id = $1`, Uuid)
+	rows, err := repository.DB.Query(ctx, `select * from external_access_rights where id = $1`, Uuid)
 	defer rows.Close()
 
 	if err != nil {
@@ -132,101 +148,144 @@ func (repository *externalRightRepository) GetEar(id string) (models.ExternalRig
 	return ear, nil
 }
 
-func (repository *externalRightRepository) AddEar(ear *models.ExternalRights) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
-
-	ear.ID = uuid.NewV4().String()
-	earPers := persistence.ExternalAccessRights{
-		R:        ear.Read,
-		U:        ear.Update,
-		D:        ear.Delete,
-		Approved: ear.Approved,
-	}
-	earPers.Id.Set(ear.ID)
-	earPers.Idsc.Set(ear.IDSC)
-	earPers.Idrc.Set(ear.IDRC)
+func (repository *externalRightRepository) AddEar(ctx context.Context, ear *models.ExternalRights) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	_, err = earPers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		ear.ID = uuid.NewV4().String()
+		earPers := persistence.ExternalAccessRights{
+			R:        ear.Read,
+			U:        ear.Update,
+			D:        ear.Delete,
+			Approved: ear.Approved,
+		}
+		earPers.Id.Set(ear.ID)
+		earPers.Idsc.Set(ear.IDSC)
+		earPers.Idrc.Set(ear.IDRC)
+
+		_, err = earPers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, ear.ID, &events.ExternalRightCreated{ExternalRight: *ear})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *externalRightRepository) UpdateEar(ear models.ExternalRights) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *externalRightRepository) UpdateEar(ctx context.Context, ear models.ExternalRights) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	earPers := persistence.ExternalAccessRights{
-		R:        ear.Read,
-		U:        ear.Update,
-		D:        ear.Delete,
-		Approved: ear.Approved,
-	}
-	earPers.Id.Set(ear.ID)
-	earPers.Idsc.Set(ear.IDSC)
-	earPers.Idrc.Set(ear.IDRC)
+		earPers := persistence.ExternalAccessRights{
+			R:        ear.Read,
+			U:        ear.Update,
+			D:        ear.Delete,
+			Approved: ear.Approved,
+		}
+		earPers.Id.Set(ear.ID)
+		earPers.Idsc.Set(ear.IDSC)
+		earPers.Idrc.Set(ear.IDRC)
 
-	commandTag, err := earPers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		commandTag, err := earPers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, ear.ID, &events.ExternalRightUpdated{ExternalRight: ear})
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *externalRightRepository) DeleteEar(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *externalRightRepository) DeleteEar(ctx context.Context, id string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	earPers := persistence.ExternalAccessRights{}
-	earPers.Id.Set(id)
+		earPers := persistence.ExternalAccessRights{}
+		earPers.Id.Set(id)
 
-	commandTag, err := earPers.DeleteTx(&tx)
+		commandTag, err := earPers.DeleteTx(&tx)
 
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
-	return tx.Commit(context.Background())
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.ExternalRightDeleted{ID: id})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *externalRightRepository) DeleteExternalRightsForCompany(idc string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *externalRightRepository) DeleteExternalRightsForCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	query := `DELETE FROM external_access_rights WHERE idsc=$1 or idrc = $1`
+		// The constraints reference the external rights, so they go first.
+		err = repository.ConstraintsRepo.DeleteConstraintsForCompany(withTx(ctx, tx), idc)
+		if err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(context.Background(), query, idc)
+		rows, err := tx.Query(ctx, `DELETE FROM external_access_rights WHERE idsc=$1 or idrc = $1 RETURNING id::text`, idc)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		ids := []string{}
+		for rows.Next() {
+			var id string
+			err = rows.Scan(&id)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
 
-	err = repository.ConstraintsRepo.DeleteConstraintsForCompany(idc)
-	if err != nil {
-		tx.Rollback(context.Background())
-		return err
-	}
+		for _, id := range ids {
+			err = repository.publisher.Publish(ctx, id, &events.ExternalRightDeleted{ID: id})
+			if err != nil {
+				return err
+			}
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
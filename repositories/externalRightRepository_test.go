@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/utils"
 	"testing"
@@ -15,14 +16,14 @@ func TestAddEar(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := EarRepo.AddEar(&utils.TestEar)
+		err := EarRepo.AddEar(context.Background(), &utils.TestEar)
 		assert.Error(err, "Error was not thrown while inserting in non-existing table")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		oldEars, _ := EarRepo.GetAllEars()
-		err := EarRepo.AddEar(&utils.TestEar)
-		newEars, _ := EarRepo.GetAllEars()
+		oldEars, _ := EarRepo.GetAllEars(context.Background())
+		err := EarRepo.AddEar(context.Background(), &utils.TestEar)
+		newEars, _ := EarRepo.GetAllEars(context.Background())
 
 		assert.NoError(err)
 		assert.Equal(1, len(newEars)-len(oldEars), "Ear was not added.")
@@ -35,7 +36,7 @@ func TestGetAllEars(t *testing.T) {
 	assert := assert.New(t)
 
 	t.Run("successful query", func(t *testing.T) {
-		allEars, err := EarRepo.GetAllEars()
+		allEars, err := EarRepo.GetAllEars(context.Background())
 
 		assert.NoError(err, "Error was thrown while reading ears")
 		assert.NotNil(allEars, "Ears returned are nil.")
@@ -50,25 +51,25 @@ func TestGetEar(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		_, err := EarRepo.GetEar(uuid.NewV4().String())
+		_, err := EarRepo.GetEar(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while getting from non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		_, err := EarRepo.GetEar(uuid)
+		_, err := EarRepo.GetEar(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		_, err := EarRepo.GetEar(uuid)
+		_, err := EarRepo.GetEar(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		EarRepo.AddEar(&utils.TestEar)
-		ear, err := EarRepo.GetEar(utils.TestEar.ID)
+		EarRepo.AddEar(context.Background(), &utils.TestEar)
+		ear, err := EarRepo.GetEar(context.Background(), utils.TestEar.ID)
 		assert.NotNil(ear, "Result is nil")
 		assert.NoError(err, "There was error while getting ear")
 		assert.Equal(utils.TestEar.ID, ear.ID, "Returned ear ID and test ID do not match.")
@@ -81,28 +82,28 @@ func TestUpdateEar(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := EarRepo.UpdateEar(utils.TestEar)
+		err := EarRepo.UpdateEar(context.Background(), utils.TestEar)
 		assert.Error(err, "Error was not thrown while updating in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
 		utils.TestEar.ID = uuid
-		err := EarRepo.UpdateEar(utils.TestEar)
+		err := EarRepo.UpdateEar(context.Background(), utils.TestEar)
 		assert.NotNil(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
 		utils.TestEar.ID = uuid
-		err := EarRepo.UpdateEar(utils.TestEar)
+		err := EarRepo.UpdateEar(context.Background(), utils.TestEar)
 		assert.NotNil(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		EarRepo.AddEar(&utils.TestEar)
+		EarRepo.AddEar(context.Background(), &utils.TestEar)
 		utils.TestEar.Delete = true
-		err := EarRepo.UpdateEar(utils.TestEar)
+		err := EarRepo.UpdateEar(context.Background(), utils.TestEar)
 		assert.NoError(err, "Ear was not updated.")
 	})
 
@@ -114,25 +115,25 @@ func TestDeleteEar(t *testing.T) {
 	t.Run("table does not exist", func(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
-		err := EarRepo.DeleteEar(uuid.NewV4().String())
+		err := EarRepo.DeleteEar(context.Background(), uuid.NewV4().String())
 		assert.Error(err, "Error was not thrown while deleting in non-existing table")
 	})
 
 	t.Run("invalid uuid", func(t *testing.T) {
 		uuid := "invalidUUID"
-		err := EarRepo.DeleteEar(uuid)
+		err := EarRepo.DeleteEar(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for invalid uuid")
 	})
 
 	t.Run("non-existing uuid", func(t *testing.T) {
 		uuid := uuid.NewV4().String()
-		err := EarRepo.DeleteEar(uuid)
+		err := EarRepo.DeleteEar(context.Background(), uuid)
 		assert.Error(err, "Error was not thrown for non-existing uuid")
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		EarRepo.AddEar(&utils.TestEar)
-		err := EarRepo.DeleteEar(utils.TestEar.ID)
+		EarRepo.AddEar(context.Background(), &utils.TestEar)
+		err := EarRepo.DeleteEar(context.Background(), utils.TestEar.ID)
 		assert.NoError(err, "Ear was not deleted.")
 	})
 }
@@ -3,66 +3,104 @@ package repositories
 import (
 	"bytes"
 	"context"
-	json "encoding/json"
 	"errors"
-	"fmt"
+	"internship_project/cache"
+	"internship_project/events"
 	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 	"strings"
 	"text/template"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
-	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 )
 
 type ProductRepository interface {
-	GetAllProducts(string) ([]models.Product, error)
-	GetProduct(string, string) (models.Product, error)
-	AddProduct(*models.Product) error
-	UpdateProduct(models.Product) error
-	DeleteProduct(string) error
-	DeleteProductsFromCompany(string) error
+	GetAllProducts(context.Context, string) ([]models.Product, error)
+	GetProduct(context.Context, string, string) (models.Product, error)
+	AddProduct(context.Context, *models.Product) error
+	AddProducts(context.Context, []models.Product, int) error
+	UpdateProduct(context.Context, models.Product) error
+	DeleteProduct(context.Context, string) error
+	DeleteProductsFromCompany(context.Context, string) error
+	RepublishProductsOfCompany(context.Context, string) error
+	ForEachProduct(context.Context, func(models.Product, int64) error) error
+	ForEachReadableProduct(context.Context, string, func(models.Product) error) error
+	GetReadConstraints(context.Context, string) ([]models.EarConstraint, error)
 }
 
 type productRepository struct {
-	DB    *pgxpool.Pool
-	kafka *kafka_helpers.KafkaProducer
+	DB         *pgxpool.Pool
+	UnitOfWork UnitOfWork
+	publisher  *kafka_helpers.EventPublisher
+	cache      *cache.AccessCache
+	Logger     *logrus.Entry
 }
 
-func NewProductRepo(db *pgxpool.Pool, writer *kafka.Writer) ProductRepository {
+func NewProductRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) ProductRepository {
 	if db == nil {
 		panic("ProductRepository not created, pgxpool is nil")
 	}
-	if writer == nil {
-		panic("ProductRepository not created, kafkaWriter is nil")
+	if unitOfWork == nil {
+		panic("ProductRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("ProductRepository not created, publisher is nil")
 	}
 
 	return &productRepository{
-		DB: db,
-		kafka: &kafka_helpers.KafkaProducer{
-			Writer: writer,
-		},
+		DB:         db,
+		UnitOfWork: unitOfWork,
+		publisher:  publisher,
+		Logger:     logging.Component(logger, "productRepository"),
 	}
 }
 
-func (repository *productRepository) GetAllProducts(employeeIdc string) ([]models.Product, error) {
+// NewCachedProductRepo is NewProductRepo with the read constraints served
+// from accessCache.
+func NewCachedProductRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, accessCache *cache.AccessCache, logger *logrus.Logger) ProductRepository {
+	if accessCache == nil {
+		panic("ProductRepository not created, access cache is nil")
+	}
+	repository := NewProductRepo(db, unitOfWork, publisher, logger).(*productRepository)
+	repository.cache = accessCache
+	return repository
+}
+
+// GetReadConstraints returns a row for every access constraint of the
+// approved external access rights that let company employeeIdc read the
+// products of another company that is not archived. A right without
+// constraints has a single row with an empty operator.
+func (repository *productRepository) GetReadConstraints(ctx context.Context, employeeIdc string) ([]models.EarConstraint, error) {
+	if repository.cache != nil {
+		return repository.cache.ReadConstraints(employeeIdc, func() ([]models.EarConstraint, error) {
+			return repository.queryReadConstraints(ctx, employeeIdc)
+		})
+	}
+	return repository.queryReadConstraints(ctx, employeeIdc)
+}
+
+func (repository *productRepository) queryReadConstraints(ctx context.Context, employeeIdc string) ([]models.EarConstraint, error) {
 	earConstraints := []models.EarConstraint{}
 
 	query := `select ear.id "idear", ear.idrc, ear.idsc, coalesce(p.name::varchar(20), '') as "property",
 	coalesce(o2.name::varchar(5), '') as "operator", coalesce(ac.property_value::int4, 0)
-    from external_access_rights ear left outer join access_constraints ac on ear.id = ac.idear
+    from external_access_rights ear join companies c on c.id = ear.idsc and c.deleted_at is null
+	left outer join access_constraints ac on ear.id = ac.idear
 	left outer join operators o2 on o2.id = ac.operator_id 
 	left outer join properties p on p.id = ac.property_id 
 	where ear.idrc = $1 and ear.r = true and ear.approved = true;`
 
-	rows, err := repository.DB.Query(context.Background(), query, employeeIdc)
-	defer rows.Close()
+	rows, err := repository.DB.Query(ctx, query, employeeIdc)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var earConstraint models.EarConstraint
@@ -72,9 +110,33 @@ func (repository *productRepository) GetAllProducts(employeeIdc string) ([]model
 		}
 		earConstraints = append(earConstraints, earConstraint)
 	}
+	return earConstraints, rows.Err()
+}
+
+func (repository *productRepository) GetAllProducts(ctx context.Context, employeeIdc string) ([]models.Product, error) {
+	products := []models.Product{}
+	err := repository.ForEachReadableProduct(ctx, employeeIdc, func(product models.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ForEachReadableProduct calls fn with every product company employeeIdc
+// may read, its own ones and those external access rights let it see. The
+// products of archived companies are left out.
+func (repository *productRepository) ForEachReadableProduct(ctx context.Context, employeeIdc string, fn func(models.Product) error) error {
+	earConstraints, err := repository.GetReadConstraints(ctx, employeeIdc)
+	if err != nil {
+		return err
+	}
 
 	finalQueryTemplate := `
-	select * from products p where p.idc = $1
+	select p.* from products p join companies c on c.id = p.idc
+	where c.deleted_at is null and (p.idc = $1
 	{{- range . -}}	
 		{{- if .Operator}}
 			or (p.idc = '{{.IDSC}}' and p.{{.Property}} {{.Operator}} {{.PropertyValue}})
@@ -82,27 +144,24 @@ func (repository *productRepository) GetAllProducts(employeeIdc string) ([]model
 			or (p.idc = '{{.IDSC}}')
 		{{- end -}}
 	{{- end -}}
-	;`
+	);`
 
 	var buff bytes.Buffer
 	t := template.Must(template.New("getProducts").Parse(finalQueryTemplate))
 
 	err = t.Execute(&buff, earConstraints)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	finalQuery := strings.TrimSpace(buff.String())
-	fmt.Println(finalQuery)
-
-	rowsProducts, err := repository.DB.Query(context.Background(), finalQuery, employeeIdc)
-	defer rowsProducts.Close()
+	repository.Logger.WithContext(ctx).WithField("constraints", len(earConstraints)).Debug("Resolved external access constraints")
 
+	rowsProducts, err := repository.DB.Query(ctx, finalQuery, employeeIdc)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	products := []models.Product{}
+	defer rowsProducts.Close()
 
 	for rowsProducts.Next() {
 		var productPers persistence.Products
@@ -111,65 +170,49 @@ func (repository *productRepository) GetAllProducts(employeeIdc string) ([]model
 		var productUUID string
 		err = productPers.Id.AssignTo(&productUUID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		var companyUUID string
 		err = productPers.Idc.AssignTo(&companyUUID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		product := models.Product{
+		err = fn(models.Product{
 			ID:       productUUID,
 			Name:     productPers.Name,
 			Price:    productPers.Price,
 			Quantity: productPers.Quantity,
 			IDC:      companyUUID,
+		})
+		if err != nil {
+			return err
 		}
-
-		products = append(products, product)
 	}
 
-	return products, nil
+	return rowsProducts.Err()
 }
 
-func (repository *productRepository) GetProduct(id string, employeeIdc string) (models.Product, error) {
+func (repository *productRepository) GetProduct(ctx context.Context, id string, employeeIdc string) (models.Product, error) {
 	product := models.Product{}
-	earConstraints := []models.EarConstraint{}
-
-	query := `select ear.id "idear", ear.idrc, ear.idsc, coalesce(p.name::varchar(20), '') as "property",
-	coalesce(o2.name::varchar(5), '') as "operator", coalesce(ac.property_value::int4, 0)
-    from external_access_rights ear left outer join access_constraints ac on ear.id = ac.idear
-	left outer join operators o2 on o2.id = ac.operator_id 
-	left outer join properties p on p.id = ac.property_id 
-	where ear.idrc = $1 and ear.r = true and ear.approved = true;`
 
-	rows, err := repository.DB.Query(context.Background(), query, employeeIdc)
-	defer rows.Close()
+	earConstraints, err := repository.GetReadConstraints(ctx, employeeIdc)
 	if err != nil {
 		return product, err
 	}
 
-	for rows.Next() {
-		var earConstraint models.EarConstraint
-		err := rows.Scan(&earConstraint.IDEAR, &earConstraint.IDRC, &earConstraint.IDSC, &earConstraint.Property, &earConstraint.Operator, &earConstraint.PropertyValue)
-		if err != nil {
-			return product, err
-		}
-		earConstraints = append(earConstraints, earConstraint)
-	}
-
-	fmt.Println(earConstraints)
+	repository.Logger.WithContext(ctx).WithField("constraints", len(earConstraints)).Debug("Resolved external access constraints")
 
 	finalQueryTemplate := `
-	select * from products p where p.id = $1
+	select p.* from products p join companies c on c.id = p.idc
+	where c.deleted_at is null and (p.id = $1
 	{{- range . -}}	
 		{{- if .Operator}}
 			or (p.idc = '{{.IDSC}}' and p.{{.Property}} {{.Operator}} {{.PropertyValue}})
 		{{- end -}}
 	{{- end -}}
-	;`
+	);`
 
 	var buff bytes.Buffer
 	t := template.Must(template.New("getProduct").Parse(finalQueryTemplate))
@@ -180,9 +223,8 @@ func (repository *productRepository) GetProduct(id string, employeeIdc string) (
 	}
 
 	finalQuery := strings.TrimSpace(buff.String())
-	fmt.Println(finalQuery)
 
-	rowsProducts, err := repository.DB.Query(context.Background(), finalQuery, id)
+	rowsProducts, err := repository.DB.Query(ctx, finalQuery, id)
 	defer rowsProducts.Close()
 
 	if err != nil {
@@ -221,136 +263,263 @@ func (repository *productRepository) GetProduct(id string, employeeIdc string) (
 	return product, nil
 }
 
-func (repository *productRepository) AddProduct(product *models.Product) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *productRepository) AddProduct(ctx context.Context, product *models.Product) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	product.ID = uuid.NewV4().String()
+		product.ID = uuid.NewV4().String()
 
-	productPers := persistence.Products{
-		Name:     product.Name,
-		Price:    product.Price,
-		Quantity: product.Quantity,
-	}
-	productPers.Idc.Set(product.IDC)
-	productPers.Id.Set(product.ID)
+		productPers := persistence.Products{
+			Name:     product.Name,
+			Price:    product.Price,
+			Quantity: product.Quantity,
+		}
+		productPers.Idc.Set(product.IDC)
+		productPers.Id.Set(product.ID)
 
-	_, err = productPers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		_, err = productPers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
 
-	message := make(map[string]interface{}, 2)
+		// New products start at version 1, the column default.
+		err = repository.publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: *product, Version: 1})
+		if err != nil {
+			return err
+		}
 
-	message["operation"] = kafka_helpers.OperationEnumString(kafka_helpers.Created)
-	message["product"] = product
+		return tx.Commit(ctx)
+	})
+}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
+// AddProducts inserts products in one transaction, batchSize rows per
+// statement, and assigns their IDs. Their events are written together once
+// the transaction has committed.
+func (repository *productRepository) AddProducts(ctx context.Context, products []models.Product, batchSize int) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		for start := 0; start < len(products); start += batchSize {
+			end := start + batchSize
+			if end > len(products) {
+				end = len(products)
+			}
+
+			batch := make([]persistence.Products, 0, end-start)
+			for i := start; i < end; i++ {
+				products[i].ID = uuid.NewV4().String()
+				productPers := persistence.Products{
+					Name:     products[i].Name,
+					Price:    products[i].Price,
+					Quantity: products[i].Quantity,
+				}
+				productPers.Idc.Set(products[i].IDC)
+				productPers.Id.Set(products[i].ID)
+				batch = append(batch, productPers)
+			}
+
+			_, err = persistence.BatchInsertProducts(&tx, &batch)
+			if err != nil {
+				return err
+			}
+		}
 
-	err = repository.kafka.WriteMessage(string(jsonMessage), product.ID)
-	if err != nil {
-		return err
-	}
+		// The unit of work holds the events back until it has committed.
+		for _, product := range products {
+			err = repository.publisher.Publish(ctx, product.ID, &events.ProductCreated{Product: product, Version: 1})
+			if err != nil {
+				return err
+			}
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *productRepository) UpdateProduct(product models.Product) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *productRepository) UpdateProduct(ctx context.Context, product models.Product) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	productPers := persistence.Products{
-		Name:     product.Name,
-		Price:    product.Price,
-		Quantity: product.Quantity,
-	}
-	productPers.Idc.Set(product.IDC)
-	productPers.Id.Set(product.ID)
+		productPers := persistence.Products{
+			Name:     product.Name,
+			Price:    product.Price,
+			Quantity: product.Quantity,
+		}
+		productPers.Idc.Set(product.IDC)
+		productPers.Id.Set(product.ID)
 
-	commandTag, err := productPers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
-	message := make(map[string]interface{}, 2)
+		commandTag, err := productPers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+		version, err := nextProductVersion(ctx, tx, product.ID)
+		if err != nil {
+			return err
+		}
+		err = repository.publisher.Publish(ctx, product.ID, &events.ProductUpdated{Product: product, Version: version})
+		if err != nil {
+			return err
+		}
 
-	message["operation"] = kafka_helpers.OperationEnumString(kafka_helpers.Updated)
-	message["product"] = product
+		return tx.Commit(ctx)
+	})
+}
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
+func (repository *productRepository) DeleteProduct(ctx context.Context, id string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	err = repository.kafka.WriteMessage(string(jsonMessage), product.ID)
-	if err != nil {
-		return err
-	}
+		// The deletion gets a version of its own, so that it wins over every
+		// earlier change of the product.
+		version, err := nextProductVersion(ctx, tx, id)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
-}
+		productPers := persistence.Products{}
+		productPers.Id.Set(id)
 
-func (repository *productRepository) DeleteProduct(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+		commandTag, err := productPers.DeleteTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
 
-	productPers := persistence.Products{}
-	productPers.Id.Set(id)
+		err = repository.publisher.Publish(ctx, id, &events.ProductDeleted{ID: id, Version: version})
+		if err != nil {
+			return err
+		}
 
-	commandTag, err := productPers.DeleteTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		return tx.Commit(ctx)
+	})
+}
 
-	message := make(map[string]interface{}, 2)
+func (repository *productRepository) DeleteProductsFromCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	message["operation"] = kafka_helpers.OperationEnumString(kafka_helpers.Deleted)
-	message["id"] = id
+		query := `DELETE FROM products WHERE idc=$1`
 
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
+		commandTag, err := tx.Exec(ctx, query, idc)
 
-	err = repository.kafka.WriteMessage(string(jsonMessage), id)
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		if commandTag.RowsAffected() > 0 {
+			err = repository.publisher.Publish(ctx, idc, &events.ProductsDeletedForCompany{CompanyID: idc})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *productRepository) DeleteProductsFromCompany(idc string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+// RepublishProductsOfCompany gives every product of company idc a new
+// version and publishes it again, e.g. once the company is restored, so that
+// it is indexed again after ProductsArchivedForCompany removed it. The
+// version grows by two, because the delete by query that removed the
+// documents counted their versions in the index up by one.
+func (repository *productRepository) RepublishProductsOfCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		rows, err := tx.Query(ctx, `UPDATE products SET version = version + 2 WHERE idc = $1
+		RETURNING id::text, name, price, quantity, idc::text, version`, idc)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		updated := []events.ProductUpdated{}
+		for rows.Next() {
+			var event events.ProductUpdated
+			err := rows.Scan(&event.Product.ID, &event.Product.Name, &event.Product.Price, &event.Product.Quantity, &event.Product.IDC, &event.Version)
+			if err != nil {
+				return err
+			}
+			updated = append(updated, event)
+		}
+		if rows.Err() != nil {
+			return rows.Err()
+		}
 
-	query := `DELETE FROM products WHERE idc=$1`
+		for i := range updated {
+			err = repository.publisher.Publish(ctx, updated[i].Product.ID, &updated[i])
+			if err != nil {
+				return err
+			}
+		}
 
-	_, err = tx.Exec(context.Background(), query, idc)
+		return tx.Commit(ctx)
+	})
+}
 
+// nextProductVersion increments the sequence number of a product and returns
+// it. The row stays locked until tx ends, so concurrent changes of the same
+// product get increasing versions in the order they are committed.
+func nextProductVersion(ctx context.Context, tx pgx.Tx, id string) (int64, error) {
+	var version int64
+	err := tx.QueryRow(ctx, `UPDATE products SET version = version + 1 WHERE id = $1 RETURNING version`, id).Scan(&version)
+	if err == pgx.ErrNoRows {
+		return 0, utils.NoDataError
+	}
+	return version, err
+}
+
+// ForEachProduct calls fn with every product of the companies that are not
+// archived and its version, without loading all of them at once. It stops at
+// the first error fn returns.
+func (repository *productRepository) ForEachProduct(ctx context.Context, fn func(models.Product, int64) error) error {
+	rows, err := repository.DB.Query(ctx, `select p.id::text, p.name, p.price, p.quantity, p.idc::text, p.version
+	from products p join companies c on c.id = p.idc where c.deleted_at is null`)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	return tx.Commit(context.Background())
+	for rows.Next() {
+		var product models.Product
+		var version int64
+		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Quantity, &product.IDC, &version)
+		if err != nil {
+			return err
+		}
+		err = fn(product, version)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
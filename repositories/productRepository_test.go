@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/utils"
 	"testing"
+	"time"
 
+	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,31 +18,101 @@ func TestAddProduct(t *testing.T) {
 		utils.DropTables(Connpool)
 		defer utils.SetUpTables(Connpool)
 		assert.False(DoesTableExist("products", Connpool))
-		err := ProductRepo.AddProduct(&utils.TestProduct)
+		err := ProductRepo.AddProduct(context.Background(), &utils.TestProduct)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		oldProducts, _ := ProductRepo.GetAllProducts(utils.AdminCompany1.CompanyID)
-		err := ProductRepo.AddProduct(&utils.TestProduct)
-		newProducts, _ := ProductRepo.GetAllProducts(utils.AdminCompany1.CompanyID)
+		oldProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
+		err := ProductRepo.AddProduct(context.Background(), &utils.TestProduct)
+		newProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
 		assert.NoError(err)
 		assert.Equal(1, len(newProducts)-len(oldProducts), "Product was not added.")
 	})
 
+	t.Run("product is indexed", func(t *testing.T) {
+		assert.Eventually(func() bool {
+			_, ok := SearchIndex.Get(utils.TestProduct.ID)
+			return ok
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
 	t.Run("add an existing product", func(t *testing.T) {
 		existingProduct := &models.Product{ID: utils.TestProduct.ID}
-		err := ProductRepo.AddProduct(existingProduct)
+		err := ProductRepo.AddProduct(context.Background(), existingProduct)
 
 		assert.Error(err)
 	})
 }
 
+func TestAddProducts(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("products are inserted in batches and indexed", func(t *testing.T) {
+		defer utils.SetUpTables(Connpool)
+		products := []models.Product{
+			{Name: "Milk", Price: 1.5, Quantity: 10, IDC: utils.TestCompany1.ID},
+			{Name: "Bread", Price: 2, Quantity: 3, IDC: utils.TestCompany1.ID},
+			{Name: "Eggs", Price: 3, Quantity: 0, IDC: utils.TestCompany1.ID},
+		}
+
+		productEvents := TopicSize(KafkaConf.MainTopic)
+		oldProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
+		err := ProductRepo.AddProducts(context.Background(), products, 2)
+		newProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
+
+		assert.NoError(err)
+		assert.Equal(3, len(newProducts)-len(oldProducts), "Products were not added.")
+		assert.Equal(productEvents+3, TopicSize(KafkaConf.MainTopic))
+		assert.NotEmpty(products[2].ID)
+		assert.Eventually(func() bool {
+			_, ok := SearchIndex.Get(products[2].ID)
+			return ok
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("nothing is inserted when a batch fails", func(t *testing.T) {
+		products := []models.Product{
+			{Name: "Milk", Price: 1.5, Quantity: 10, IDC: utils.TestCompany1.ID},
+			{Name: "Bread", Price: 2, Quantity: 3, IDC: uuid.NewV4().String()},
+		}
+
+		productEvents := TopicSize(KafkaConf.MainTopic)
+		oldProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
+		err := ProductRepo.AddProducts(context.Background(), products, 1)
+		newProducts, _ := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
+
+		assert.Error(err)
+		assert.Equal(len(oldProducts), len(newProducts))
+		assert.Equal(productEvents, TopicSize(KafkaConf.MainTopic), "Events were published for rolled back products.")
+	})
+
+	t.Run("no events when the commit fails", func(t *testing.T) {
+		products := []models.Product{
+			{Name: "Milk", Price: 1.5, Quantity: 10, IDC: utils.TestCompany1.ID},
+			{Name: "Bread", Price: 2, Quantity: 3, IDC: utils.TestCompany1.ID},
+		}
+		productEvents := TopicSize(KafkaConf.MainTopic)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The outer unit of work cannot commit after its context is gone.
+		err := NewUnitOfWork(Connpool, false).Do(ctx, func(ctx context.Context) error {
+			err := ProductRepo.AddProducts(ctx, products, 1)
+			cancel()
+			return err
+		})
+
+		assert.Error(err)
+		assert.Equal(productEvents, TopicSize(KafkaConf.MainTopic), "Events were published for rolled back products.")
+	})
+}
+
 func TestGetAllProducts(t *testing.T) {
 	assert := assert.New(t)
 
 	t.Run("successful GetAll query", func(t *testing.T) {
-		allProducts, err := ProductRepo.GetAllProducts(utils.AdminCompany1.CompanyID)
+		allProducts, err := ProductRepo.GetAllProducts(context.Background(), utils.AdminCompany1.CompanyID)
 
 		assert.NoError(err)
 		assert.NotNil(allProducts, "Products were nil.")
@@ -53,20 +126,20 @@ func TestGetProduct(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		_, err := ProductRepo.GetProduct(invalidID)
+		_, err := ProductRepo.GetProduct(context.Background(), invalidID, utils.AdminCompany1.CompanyID)
 		assert.Error(err)
 	})
 
 	t.Run("id does not exist", func(t *testing.T) {
 		randomUUID := "c5ef08c6-60eb-4687-bcbb-df37ebc9e105"
 		assert.True(IsValidUUID(randomUUID))
-		_, err := ProductRepo.GetProduct(randomUUID)
+		_, err := ProductRepo.GetProduct(context.Background(), randomUUID, utils.AdminCompany1.CompanyID)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
 		testID := utils.TestProduct.ID
-		product, err := ProductRepo.GetProduct(testID)
+		product, err := ProductRepo.GetProduct(context.Background(), testID, utils.AdminCompany1.CompanyID)
 
 		assert.NoError(err)
 		assert.NotNil(product, "Product is nil")
@@ -84,7 +157,7 @@ func TestUpdateProduct(t *testing.T) {
 		invalidID := "123-asd-321"
 		invalidProduct := models.Product{ID: invalidID}
 		assert.False(IsValidUUID(invalidID))
-		err := ProductRepo.UpdateProduct(invalidProduct)
+		err := ProductRepo.UpdateProduct(context.Background(), invalidProduct)
 		assert.Error(err)
 	})
 
@@ -92,18 +165,29 @@ func TestUpdateProduct(t *testing.T) {
 		randomUUID := "e323a287-c350-4b27-a567-d8c92c52f1d9"
 		randomProduct := models.Product{ID: randomUUID, IDC: utils.TestProduct.IDC, Name: utils.TestProduct.Name, Price: utils.TestProduct.Price, Quantity: utils.TestProduct.Quantity}
 		assert.True(IsValidUUID(randomUUID))
-		err := ProductRepo.UpdateProduct(randomProduct)
+		err := ProductRepo.UpdateProduct(context.Background(), randomProduct)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
 		utils.TestProduct.Name = "UPDATED Name"
 
-		err := ProductRepo.UpdateProduct(utils.TestProduct)
+		err := ProductRepo.UpdateProduct(context.Background(), utils.TestProduct)
 
 		assert.NoError(err, "Product was not updated.")
 	})
 
+	t.Run("version is incremented", func(t *testing.T) {
+		var before, after int64
+		Connpool.QueryRow(context.Background(), "select version from products where id = $1", utils.TestProduct.ID).Scan(&before)
+
+		err := ProductRepo.UpdateProduct(context.Background(), utils.TestProduct)
+		assert.NoError(err)
+
+		Connpool.QueryRow(context.Background(), "select version from products where id = $1", utils.TestProduct.ID).Scan(&after)
+		assert.Equal(before+1, after)
+	})
+
 }
 
 func TestDeleteProduct(t *testing.T) {
@@ -112,19 +196,19 @@ func TestDeleteProduct(t *testing.T) {
 	t.Run("invalid id", func(t *testing.T) {
 		invalidID := "123-asd-321"
 		assert.False(IsValidUUID(invalidID))
-		err := ProductRepo.DeleteProduct(invalidID)
+		err := ProductRepo.DeleteProduct(context.Background(), invalidID)
 		assert.Error(err)
 	})
 
 	t.Run("id does not exist", func(t *testing.T) {
 		randomUUID := "7d91a563-3386-4069-b785-09c52b5201b5"
 		assert.True(IsValidUUID(randomUUID))
-		err := ProductRepo.DeleteProduct(randomUUID)
+		err := ProductRepo.DeleteProduct(context.Background(), randomUUID)
 		assert.Error(err)
 	})
 
 	t.Run("successful query", func(t *testing.T) {
-		err := ProductRepo.DeleteProduct(utils.TestProduct.ID)
+		err := ProductRepo.DeleteProduct(context.Background(), utils.TestProduct.ID)
 
 		assert.NoError(err, "Product was not deleted.")
 	})
@@ -3,24 +3,19 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"internship_project/config"
+	"internship_project/elasticsearch_helpers"
 	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/utils"
 	"os"
 	"testing"
 
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/lytics/confl"
 	uuid "github.com/satori/go.uuid"
 )
 
-type config struct {
-	Username        string `json:"username"`
-	Password        string `json:"password"`
-	DatabaseURL     string `json:"database_url"`
-	TestDatabaseURL string `json:"test_database_url"`
-}
-
 var (
 	Connpool       *pgxpool.Pool
 	EmployeeRepo   EmployeeRepository
@@ -28,20 +23,39 @@ var (
 	CompanyRepo    CompanyRepository
 	EarRepo        ExternalRightRepository
 	ConstraintRepo ConstraintRepository
+	DeadLetterRepo DeadLetterRepository
+	AuditRepo      AuditRepository
+	SearchIndex    *elasticsearch_helpers.MemoryIndex
+	Broker         *kafka_helpers.MemoryBroker
+	KafkaConf      config.KafkaConfig
 )
 
 func TestMain(m *testing.M) {
-	Connpool = getConnPool()
-	defer Connpool.Close()
+	conf, err := config.Load("./../config.conf")
+	if err != nil {
+		panic(err)
+	}
 
-	kafkaWriter := kafka_helpers.GetWriter("ava-internship")
-	defer kafkaWriter.Close()
+	Connpool = getConnPool(conf.Database)
+	defer Connpool.Close()
 
-	EmployeeRepo = NewEmployeeRepo(Connpool)
-	ProductRepo = NewProductRepo(Connpool, kafkaWriter)
-	CompanyRepo = NewCompanyRepo(Connpool, kafkaWriter)
-	EarRepo = NewExternalRightRepo(Connpool)
-	ConstraintRepo = NewConstraintRepo(Connpool)
+	// Events go through an in-memory broker to an in-memory search index.
+	SearchIndex = elasticsearch_helpers.NewMemoryIndex()
+	Broker = kafka_helpers.NewMemoryBroker(1)
+	KafkaConf = conf.Kafka
+	pipeline := kafka_helpers.NewPipeline(conf.Kafka, Broker, SearchIndex, nil, logging.Discard())
+	pipeline.Start()
+	defer pipeline.Close()
+	publisher := pipeline.Publisher
+	unitOfWork := NewUnitOfWork(Connpool, false)
+
+	EmployeeRepo = NewEmployeeRepo(Connpool, unitOfWork, publisher, logging.Discard())
+	ProductRepo = NewProductRepo(Connpool, unitOfWork, publisher, logging.Discard())
+	CompanyRepo = NewCompanyRepo(Connpool, unitOfWork, publisher, logging.Discard())
+	EarRepo = NewExternalRightRepo(Connpool, unitOfWork, publisher, logging.Discard())
+	ConstraintRepo = NewConstraintRepo(Connpool, unitOfWork, publisher, logging.Discard())
+	DeadLetterRepo = NewDeadLetterRepo(Connpool, logging.Discard())
+	AuditRepo = NewAuditRepo(Connpool, logging.Discard())
 
 	utils.SetUpTables(Connpool)
 
@@ -50,6 +64,15 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// TopicSize returns how many events were written to topic.
+func TopicSize(topic string) int64 {
+	offsets, err := Broker.Offsets(context.Background(), topic)
+	if err != nil {
+		panic(err)
+	}
+	return offsets[0].LastOffset
+}
+
 func IsValidUUID(u string) bool {
 	_, err := uuid.FromString(u)
 	return err == nil
@@ -64,13 +87,8 @@ func DoesTableExist(tableName string, connpool *pgxpool.Pool) bool {
 	return true
 }
 
-func getConnPool() *pgxpool.Pool {
-	var conf config
-	if _, configerr := confl.DecodeFile("./../dbconfig.conf", &conf); configerr != nil {
-		panic(configerr)
-	}
-
-	poolConfig, poolerr := pgxpool.ParseConfig(conf.TestDatabaseURL)
+func getConnPool(conf config.DatabaseConfig) *pgxpool.Pool {
+	poolConfig, poolerr := pgxpool.ParseConfig(conf.TestURL)
 	if poolerr != nil {
 		panic("Error configuring pool")
 	}
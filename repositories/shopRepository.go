@@ -5,36 +5,53 @@ import (
 	"errors"
 	"github.com/jackc/pgx/v4/pgxpool"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 )
 
 type ShopRepository interface {
-	GetAllShops() ([]models.Shop, error)
-	GetShopsByLatLon(float64, float64) ([]models.Shop, error)
-	GetShop(string) (models.Shop, error)
-	AddShop(*models.Shop) error
-	UpdateShop(models.Shop) error
-	DeleteShop(string) error
+	GetAllShops(context.Context) ([]models.Shop, error)
+	GetShopsByLatLon(context.Context, float64, float64) ([]models.Shop, error)
+	GetShop(context.Context, string) (models.Shop, error)
+	AddShop(context.Context, *models.Shop) error
+	UpdateShop(context.Context, models.Shop) error
+	DeleteShop(context.Context, string) error
+	DeleteShopsFromCompany(context.Context, string) error
 }
 
 type shopRepository struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	UnitOfWork UnitOfWork
+	publisher  *kafka_helpers.EventPublisher
+	Logger     *logrus.Entry
 }
 
-func NewShopRepo(db *pgxpool.Pool) ShopRepository {
+func NewShopRepo(db *pgxpool.Pool, unitOfWork UnitOfWork, publisher *kafka_helpers.EventPublisher, logger *logrus.Logger) ShopRepository {
 	if db == nil {
 		panic("ShopRepository not created, pgxpool is nil")
 	}
-	return &shopRepository {
-		DB: db,
+	if unitOfWork == nil {
+		panic("ShopRepository not created, unit of work is nil")
+	}
+	if publisher == nil {
+		panic("ShopRepository not created, publisher is nil")
+	}
+	return &shopRepository{
+		DB:         db,
+		UnitOfWork: unitOfWork,
+		publisher:  publisher,
+		Logger:     logging.Component(logger, "shopRepository"),
 	}
 }
 
-func (repository *shopRepository) GetAllShops() ([]models.Shop, error) {
+func (repository *shopRepository) GetAllShops(ctx context.Context) ([]models.Shop, error) {
 	shops := []models.Shop{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.shops")
+	rows, err := repository.DB.Query(ctx, "select * from public.shops")
 	defer rows.Close()
 
 	if err != nil {
@@ -58,19 +75,19 @@ func (repository *shopRepository) GetAllShops() ([]models.Shop, error) {
 		}
 
 		shops = append(shops, models.Shop{
-			ID:     stringUUID_ID,
-			Name:	shop.Name,
-			IDC:	stringUUID_IDC,
-			Lat:	shop.Lat,
-			Lon:	shop.Lon,
+			ID:   stringUUID_ID,
+			Name: shop.Name,
+			IDC:  stringUUID_IDC,
+			Lat:  shop.Lat,
+			Lon:  shop.Lon,
 		})
 	}
 	return shops, nil
 }
 
-func (repository *shopRepository) GetShopsByLatLon(lat, lon float64) ([]models.Shop, error) {
+func (repository *shopRepository) GetShopsByLatLon(ctx context.Context, lat, lon float64) ([]models.Shop, error) {
 	shops := []models.Shop{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.shops where lat=$1 and lon=$2", lat, lon)
+	rows, err := repository.DB.Query(ctx, "select * from public.shops where lat=$1 and lon=$2", lat, lon)
 	defer rows.Close()
 
 	if err != nil {
@@ -94,20 +111,20 @@ func (repository *shopRepository) GetShopsByLatLon(lat, lon float64) ([]models.S
 		}
 
 		shops = append(shops, models.Shop{
-			ID:     stringUUID_ID,
-			Name:	shop.Name,
-			IDC:	stringUUID_IDC,
-			Lat:	shop.Lat,
-			Lon:	shop.Lon,
+			ID:   stringUUID_ID,
+			Name: shop.Name,
+			IDC:  stringUUID_IDC,
+			Lat:  shop.Lat,
+			Lon:  shop.Lon,
 		})
 	}
 	return shops, nil
 }
 
-func (repository *shopRepository) GetShop(id string) (models.Shop, error) {
+func (repository *shopRepository) GetShop(ctx context.Context, id string) (models.Shop, error) {
 	var shop models.Shop
 
-	rows, err := repository.DB.Query(context.Background(), `select * from public.shops where id = $1`, id)
+	rows, err := repository.DB.Query(ctx, `select * from public.shops where id = $1`, id)
 	defer rows.Close()
 
 	if err != nil {
@@ -134,90 +151,149 @@ func (repository *shopRepository) GetShop(id string) (models.Shop, error) {
 	}
 
 	shop = models.Shop{
-		ID:     stringUUID_ID,
-		Name:	shopPers.Name,
-		IDC:	stringUUID_IDC,
-		Lat:	shopPers.Lat,
-		Lon:	shopPers.Lon,
+		ID:   stringUUID_ID,
+		Name: shopPers.Name,
+		IDC:  stringUUID_IDC,
+		Lat:  shopPers.Lat,
+		Lon:  shopPers.Lon,
 	}
 	return shop, nil
 }
 
-func (repository *shopRepository) AddShop(shop *models.Shop) error {
+func (repository *shopRepository) AddShop(ctx context.Context, shop *models.Shop) error {
 	if shop == nil {
 		return errors.New("Shop parameter was nil")
 	}
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
 
-	defer tx.Rollback(context.Background())
+		defer tx.Rollback(ctx)
 
-	shop.ID = uuid.NewV4().String()
+		shop.ID = uuid.NewV4().String()
 
-	shopPers := persistence.Shops{
-		Name:	shop.Name,
-		Lat:	shop.Lat,
-		Lon:	shop.Lon,
-	}
+		shopPers := persistence.Shops{
+			Name: shop.Name,
+			Lat:  shop.Lat,
+			Lon:  shop.Lon,
+		}
 
-	shopPers.Id.Set(shop.ID)
-	shopPers.Idc.Set(shop.IDC)
+		shopPers.Id.Set(shop.ID)
+		shopPers.Idc.Set(shop.IDC)
 
-	_, err = shopPers.InsertTx(&tx)
-	if err != nil {
-		return err
-	}
+		_, err = shopPers.InsertTx(&tx)
+		if err != nil {
+			return err
+		}
+
+		err = repository.publisher.Publish(ctx, shop.ID, &events.ShopCreated{Shop: *shop})
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(context.Background())
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *shopRepository) UpdateShop(shop models.Shop) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *shopRepository) UpdateShop(ctx context.Context, shop models.Shop) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	shopPers := persistence.Shops{
-		Name:	shop.Name,
-		Lat:	shop.Lat,
-		Lon:	shop.Lon,
-	}
+		shopPers := persistence.Shops{
+			Name: shop.Name,
+			Lat:  shop.Lat,
+			Lon:  shop.Lon,
+		}
 
-	shopPers.Id.Set(shop.ID)
-	shopPers.Idc.Set(shop.IDC)
+		shopPers.Id.Set(shop.ID)
+		shopPers.Idc.Set(shop.IDC)
 
-	commandTag, err := shopPers.UpdateTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
+		commandTag, err := shopPers.UpdateTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
 
-	return tx.Commit(context.Background())
+		err = repository.publisher.Publish(ctx, shop.ID, &events.ShopUpdated{Shop: shop})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
-func (repository *shopRepository) DeleteShop(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
+func (repository *shopRepository) DeleteShop(ctx context.Context, id string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
 
-	shopPers := persistence.Shops{}
-	shopPers.Id.Set(id)
+		shopPers := persistence.Shops{}
+		shopPers.Id.Set(id)
 
-	commandTag, err := shopPers.DeleteTx(&tx)
-	if err != nil {
-		return err
-	}
-	if commandTag != 1 {
-		return utils.NoDataError
-	}
-	return tx.Commit(context.Background())
+		commandTag, err := shopPers.DeleteTx(&tx)
+		if err != nil {
+			return err
+		}
+		if commandTag != 1 {
+			return utils.NoDataError
+		}
+
+		err = repository.publisher.Publish(ctx, id, &events.ShopDeleted{ID: id})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
+func (repository *shopRepository) DeleteShopsFromCompany(ctx context.Context, idc string) error {
+	return repository.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		tx, err := beginTx(ctx, repository.DB)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		rows, err := tx.Query(ctx, `DELETE FROM shops WHERE idc=$1 RETURNING id::text`, idc)
+		if err != nil {
+			return err
+		}
 
+		ids := []string{}
+		for rows.Next() {
+			var id string
+			err = rows.Scan(&id)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+
+		for _, id := range ids {
+			err = repository.publisher.Publish(ctx, id, &events.ShopDeleted{ID: id})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit(ctx)
+	})
+}
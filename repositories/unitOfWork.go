@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"internship_project/events"
+	"internship_project/kafka_helpers"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// UnitOfWork runs the writes of several repositories in one transaction.
+// Repositories called with the context passed to Do begin a savepoint of
+// the shared transaction instead of a transaction of their own, so nothing
+// they write is committed unless the whole unit of work succeeds. The events
+// they publish are held back and written after the outermost unit of work
+// has committed, so that no event announces a change that was rolled back.
+type UnitOfWork interface {
+	Do(ctx context.Context, work func(ctx context.Context) error) error
+}
+
+type unitOfWork struct {
+	DB            *pgxpool.Pool
+	actorMessages bool
+}
+
+type txKey struct{}
+
+// NewUnitOfWork returns a UnitOfWork on db. With actorMessages, the
+// transaction of an outermost unit of work starts by writing the actor of its
+// context into the WAL for change data capture (cdc.actor_messages).
+func NewUnitOfWork(db *pgxpool.Pool, actorMessages bool) UnitOfWork {
+	if db == nil {
+		panic("UnitOfWork not created, pgxpool is nil")
+	}
+	return &unitOfWork{DB: db, actorMessages: actorMessages}
+}
+
+func (uow *unitOfWork) Do(ctx context.Context, work func(ctx context.Context) error) error {
+	_, nested := ctx.Value(txKey{}).(pgx.Tx)
+	tx, err := beginTx(ctx, uow.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if uow.actorMessages && !nested {
+		err = emitActor(ctx, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	batch := kafka_helpers.NewEventBatch()
+	err = work(kafka_helpers.WithEventBatch(withTx(ctx, tx), batch))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A nested unit of work only committed a savepoint, its events wait for
+	// the outer one.
+	if outer := kafka_helpers.EventBatchFrom(ctx); outer != nil {
+		outer.Merge(batch)
+		return nil
+	}
+	err = batch.Publish(ctx)
+	if err != nil {
+		return fmt.Errorf("The changes were saved, but publishing their events failed: %w", err)
+	}
+	return nil
+}
+
+func withTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// beginTx begins a savepoint of the transaction of the unit of work ctx
+// belongs to, or a new transaction when there is none.
+func beginTx(ctx context.Context, db *pgxpool.Pool) (pgx.Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx.Begin(ctx)
+	}
+	return db.Begin(ctx)
+}
+
+// emitActor writes the actor of ctx into the WAL of tx as a logical decoding
+// message, so that change data capture can name it in the events of the
+// transaction. Transactions without an actor write nothing.
+func emitActor(ctx context.Context, tx pgx.Tx) error {
+	actor, tenant := events.ActorFrom(ctx)
+	if actor == "" {
+		return nil
+	}
+	content, err := json.Marshal(events.ActorMessage{Actor: actor, Tenant: tenant})
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `select pg_logical_emit_message(true, $1::text, $2::text)`, events.ActorMessagePrefix, string(content))
+	return err
+}
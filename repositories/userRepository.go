@@ -3,36 +3,40 @@ package repositories
 import (
 	"context"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+	"internship_project/logging"
 	"internship_project/models"
 	"internship_project/persistence"
 	"internship_project/utils"
 )
 
 type UserRepository interface {
-	DoesUserExists(string) (bool, error)
-	GetAllUsers() ([]models.User, error)
-	GetUser(string) (models.User, error)
-	AddUser(models.User) error
-	UpdateUser(models.User) error
-	DeleteUser(string) error
+	DoesUserExists(context.Context, string) (bool, error)
+	GetAllUsers(context.Context) ([]models.User, error)
+	GetUser(context.Context, string) (models.User, error)
+	AddUser(context.Context, models.User) error
+	UpdateUser(context.Context, models.User) error
+	DeleteUser(context.Context, string) error
 }
 
 type userRepository struct {
-	DB *pgxpool.Pool
+	DB     *pgxpool.Pool
+	Logger *logrus.Entry
 }
 
-func NewUserRepo(db *pgxpool.Pool) UserRepository {
+func NewUserRepo(db *pgxpool.Pool, logger *logrus.Logger) UserRepository {
 	if db == nil {
 		panic("UserRepository not created, pgxpool is nil")
 	}
-	return &userRepository {
-		DB: db,
+	return &userRepository{
+		DB:     db,
+		Logger: logging.Component(logger, "userRepository"),
 	}
 }
 
-func (repository *userRepository) DoesUserExists(id string) (bool, error) {
+func (repository *userRepository) DoesUserExists(ctx context.Context, id string) (bool, error) {
 	var count int
-	err := repository.DB.QueryRow(context.Background(), "select count(*) from public.users where id = $1", id).Scan(&count)
+	err := repository.DB.QueryRow(ctx, "select count(*) from public.users where id = $1", id).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -44,9 +48,9 @@ func (repository *userRepository) DoesUserExists(id string) (bool, error) {
 
 }
 
-func (repository *userRepository) GetAllUsers() ([]models.User, error) {
+func (repository *userRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	users := []models.User{}
-	rows, err := repository.DB.Query(context.Background(), "select * from public.users")
+	rows, err := repository.DB.Query(ctx, "select * from public.users")
 	defer rows.Close()
 
 	if err != nil {
@@ -58,18 +62,18 @@ func (repository *userRepository) GetAllUsers() ([]models.User, error) {
 		user.Scan(&rows)
 
 		users = append(users, models.User{
-			ID:     user.Id,
+			ID:    user.Id,
 			Email: user.Email,
-			Name:   user.Name,
+			Name:  user.Name,
 		})
 	}
 	return users, nil
 }
 
-func (repository *userRepository) GetUser(id string) (models.User, error) {
+func (repository *userRepository) GetUser(ctx context.Context, id string) (models.User, error) {
 	var user models.User
 
-	rows, err := repository.DB.Query(context.Background(), `select * from public.users where id = $1`, id)
+	rows, err := repository.DB.Query(ctx, `select * from public.users where id = $1`, id)
 	defer rows.Close()
 
 	if err != nil {
@@ -84,20 +88,20 @@ func (repository *userRepository) GetUser(id string) (models.User, error) {
 	userPers.Scan(&rows)
 
 	user = models.User{
-		ID:     userPers.Id,
-		Email:  userPers.Email,
-		Name:   userPers.Name,
+		ID:    userPers.Id,
+		Email: userPers.Email,
+		Name:  userPers.Name,
 	}
 
 	return user, nil
 }
 
-func (repository *userRepository) AddUser(user models.User) error {
-	tx, err := repository.DB.Begin(context.Background())
+func (repository *userRepository) AddUser(ctx context.Context, user models.User) error {
+	tx, err := beginTx(ctx, repository.DB)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
 	userPers := persistence.Users{
 		Id:    user.ID,
@@ -110,15 +114,15 @@ func (repository *userRepository) AddUser(user models.User) error {
 		return err
 	}
 
-	return tx.Commit(context.Background())
+	return tx.Commit(ctx)
 }
 
-func (repository *userRepository) UpdateUser(user models.User) error {
-	tx, err := repository.DB.Begin(context.Background())
+func (repository *userRepository) UpdateUser(ctx context.Context, user models.User) error {
+	tx, err := beginTx(ctx, repository.DB)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
 	userPers := persistence.Users{
 		Id:    user.ID,
@@ -134,15 +138,15 @@ func (repository *userRepository) UpdateUser(user models.User) error {
 		return utils.NoDataError
 	}
 
-	return tx.Commit(context.Background())
+	return tx.Commit(ctx)
 }
 
-func (repository *userRepository) DeleteUser(id string) error {
-	tx, err := repository.DB.Begin(context.Background())
+func (repository *userRepository) DeleteUser(ctx context.Context, id string) error {
+	tx, err := beginTx(ctx, repository.DB)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
 	userPers := persistence.Users{}
 	userPers.Id = id
@@ -154,7 +158,5 @@ func (repository *userRepository) DeleteUser(id string) error {
 	if commandTag != 1 {
 		return utils.NoDataError
 	}
-	return tx.Commit(context.Background())
+	return tx.Commit(ctx)
 }
-
-
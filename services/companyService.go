@@ -1,37 +1,77 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"internship_project/models"
 	"internship_project/repositories"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type CompanyService struct {
 	Repository repositories.CompanyRepository
+	// SoftDelete archives deleted companies instead of removing them, so
+	// that they can be restored until RestoreGracePeriod has passed.
+	SoftDelete         bool
+	RestoreGracePeriod time.Duration
+	Logger             *logrus.Entry
+}
+
+func (service *CompanyService) GetAllCompanies(ctx context.Context) ([]models.Company, error) {
+	return service.Repository.GetAllCompanies(ctx)
 }
 
-func (service *CompanyService) GetAllCompanies() ([]models.Company, error) {
-	return service.Repository.GetAllCompanies()
+func (service *CompanyService) GetCompany(ctx context.Context, id string) (models.Company, error) {
+	return service.Repository.GetCompany(ctx, id)
 }
 
-func (service *CompanyService) GetCompany(id string) (models.Company, error) {
-	return service.Repository.GetCompany(id)
+func (service *CompanyService) AddNewCompany(ctx context.Context, newCompany *models.Company) error {
+	return service.Repository.AddCompany(ctx, newCompany)
 }
 
-func (service *CompanyService) AddNewCompany(newCompany *models.Company) error {
-	return service.Repository.AddCompany(newCompany)
+func (service *CompanyService) UpdateCompany(ctx context.Context, updateCompany models.Company) error {
+	return service.Repository.UpdateCompany(ctx, updateCompany)
+}
+
+func (service *CompanyService) DeleteCompany(ctx context.Context, id string) error {
+	if service.SoftDelete {
+		return service.Repository.ArchiveCompany(ctx, id)
+	}
+	return service.Repository.DeleteCompany(ctx, id)
 }
 
-func (service *CompanyService) UpdateCompany(updateCompany models.Company) error {
-	return service.Repository.UpdateCompany(updateCompany)
+func (service *CompanyService) RestoreCompany(ctx context.Context, id string) (models.Company, error) {
+	err := service.Repository.RestoreCompany(ctx, id, time.Now().Add(-service.RestoreGracePeriod))
+	if err != nil {
+		return models.Company{}, err
+	}
+	return service.Repository.GetCompany(ctx, id)
 }
 
-func (service *CompanyService) DeleteCompany(id string) error {
-	return service.Repository.DeleteCompany(id)
+// PurgeArchivedCompanies deletes for good the archived companies whose grace
+// period has passed and returns how many were deleted.
+func (service *CompanyService) PurgeArchivedCompanies(ctx context.Context) (int, error) {
+	ids, err := service.Repository.GetCompaniesArchivedBefore(ctx, time.Now().Add(-service.RestoreGracePeriod))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		err = service.Repository.DeleteCompany(ctx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).WithError(err).WithField("company_id", id).Error("Unable to purge archived company")
+			continue
+		}
+		purged++
+	}
+	return purged, nil
 }
 
-func (service *CompanyService) ChangeExternalRightApproveStatus(companyID string, idear string, status bool) error {
-	approvingCompany, err := service.Repository.GetCompany(companyID)
+func (service *CompanyService) ChangeExternalRightApproveStatus(ctx context.Context, companyID string, idear string, status bool) error {
+	approvingCompany, err := service.Repository.GetCompany(ctx, companyID)
 	if err != nil {
 		return err
 	}
@@ -40,5 +80,5 @@ func (service *CompanyService) ChangeExternalRightApproveStatus(companyID string
 		return errors.New("Your company does not have permission to approve sharing")
 	}
 
-	return service.Repository.ChangeExternalRightApproveStatus(idear, status)
+	return service.Repository.ChangeExternalRightApproveStatus(ctx, idear, status)
 }
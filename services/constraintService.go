@@ -1,30 +1,34 @@
 package services
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/repositories"
+
+	"github.com/sirupsen/logrus"
 )
 
 type ConstraintService struct {
 	Repository repositories.ConstraintRepository
+	Logger     *logrus.Entry
 }
 
-func (service *ConstraintService) GetAllConstraints() ([]models.AccessConstraint, error) {
-	return service.Repository.GetAllConstraints()
+func (service *ConstraintService) GetAllConstraints(ctx context.Context) ([]models.AccessConstraint, error) {
+	return service.Repository.GetAllConstraints(ctx)
 }
 
-func (service *ConstraintService) GetConstraint(id string) (models.AccessConstraint, error) {
-	return service.Repository.GetConstraint(id)
+func (service *ConstraintService) GetConstraint(ctx context.Context, id string) (models.AccessConstraint, error) {
+	return service.Repository.GetConstraint(ctx, id)
 }
 
-func (service *ConstraintService) AddNewConstraint(newConstraint *models.AccessConstraint) error {
-	return service.Repository.AddConstraint(newConstraint)
+func (service *ConstraintService) AddNewConstraint(ctx context.Context, newConstraint *models.AccessConstraint) error {
+	return service.Repository.AddConstraint(ctx, newConstraint)
 }
 
-func (service *ConstraintService) UpdateConstraint(updateConstraint models.AccessConstraint) error {
-	return service.Repository.UpdateConstraint(updateConstraint)
+func (service *ConstraintService) UpdateConstraint(ctx context.Context, updateConstraint models.AccessConstraint) error {
+	return service.Repository.UpdateConstraint(ctx, updateConstraint)
 }
 
-func (service *ConstraintService) DeleteConstraint(id string) error {
-	return service.Repository.DeleteConstraint(id)
+func (service *ConstraintService) DeleteConstraint(ctx context.Context, id string) error {
+	return service.Repository.DeleteConstraint(ctx, id)
 }
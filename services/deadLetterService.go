@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"internship_project/kafka_helpers"
+	"internship_project/models"
+	"internship_project/repositories"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	AuditDeadLetterReplay  = "dead_letter.replay"
+	AuditDeadLetterDiscard = "dead_letter.discard"
+)
+
+type DeadLetterService struct {
+	Queue           *kafka_helpers.DeadLetterQueue
+	Repository      repositories.DeadLetterRepository
+	AuditRepository repositories.AuditRepository
+	UnitOfWork      repositories.UnitOfWork
+	Logger          *logrus.Entry
+}
+
+// GetDeadLetters returns the dead letters with status, or every dead letter
+// if status is empty. Message values are left out, GetDeadLetter returns them.
+func (service *DeadLetterService) GetDeadLetters(ctx context.Context, status string) ([]models.DeadLetter, error) {
+	deadLetters, err := service.deadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []models.DeadLetter{}
+	for _, deadLetter := range deadLetters {
+		if status != "" && deadLetter.Status != status {
+			continue
+		}
+		deadLetter.Value = ""
+		filtered = append(filtered, deadLetter)
+	}
+	return filtered, nil
+}
+
+func (service *DeadLetterService) GetDeadLetter(ctx context.Context, id string) (models.DeadLetter, error) {
+	deadLetter, err := service.Queue.Message(ctx, id)
+	if err != nil {
+		return deadLetter, err
+	}
+
+	statuses, err := service.Repository.GetStatuses(ctx, service.Queue.Config.DeadLetterTopic)
+	if err != nil {
+		return deadLetter, err
+	}
+	deadLetter.Status = deadLetterStatus(statuses, deadLetter.ID)
+	return deadLetter, nil
+}
+
+// Replay writes the dead letter with id to the main topic again.
+func (service *DeadLetterService) Replay(ctx context.Context, actor string, id string) (models.DeadLetter, error) {
+	deadLetter, err := service.Queue.Message(ctx, id)
+	if err != nil {
+		return deadLetter, err
+	}
+
+	err = service.replay(ctx, actor, deadLetter, nil)
+	if err != nil {
+		return deadLetter, err
+	}
+	deadLetter.Status = models.DeadLetterReplayed
+	return deadLetter, nil
+}
+
+// ReplayMatching replays every pending dead letter that matches filter and
+// returns the ones that were replayed. It stops at the first dead letter that
+// cannot be replayed, the ones replayed before stay replayed.
+func (service *DeadLetterService) ReplayMatching(ctx context.Context, actor string, filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	deadLetters, err := service.deadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := []models.DeadLetter{}
+	for _, deadLetter := range deadLetters {
+		if deadLetter.Status != models.DeadLetterPending || !filter.Matches(deadLetter) {
+			continue
+		}
+
+		err = service.replay(ctx, actor, deadLetter, filter)
+		if err != nil {
+			return replayed, err
+		}
+		deadLetter.Status = models.DeadLetterReplayed
+		deadLetter.Value = ""
+		replayed = append(replayed, deadLetter)
+	}
+	return replayed, nil
+}
+
+// Discard marks the dead letter with id as never to be replayed, e.g.
+// because it can never be processed.
+func (service *DeadLetterService) Discard(ctx context.Context, actor string, id string, reason string) error {
+	deadLetter, err := service.Queue.Message(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return service.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		err := service.Repository.Resolve(ctx, service.Queue.Config.DeadLetterTopic, deadLetter.ID, models.DeadLetterDiscarded)
+		if err != nil {
+			return err
+		}
+
+		details := auditDetails(deadLetter)
+		if reason != "" {
+			details["reason"] = reason
+		}
+		return service.AuditRepository.AddEntry(ctx, &models.AuditEntry{
+			Actor:   actor,
+			Action:  AuditDeadLetterDiscard,
+			Target:  deadLetterTarget(service.Queue.Config.DeadLetterTopic, deadLetter.ID),
+			Details: details,
+		})
+	})
+}
+
+// replay resolves the dead letter, audits it and writes it to the main topic
+// in one unit of work, so that a dead letter that could not be written stays
+// pending.
+func (service *DeadLetterService) replay(ctx context.Context, actor string, deadLetter models.DeadLetter, filter interface{}) error {
+	if deadLetter.Value == "" {
+		return errors.New("This dead letter has no value to replay")
+	}
+
+	return service.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		err := service.Repository.Resolve(ctx, service.Queue.Config.DeadLetterTopic, deadLetter.ID, models.DeadLetterReplayed)
+		if err != nil {
+			return err
+		}
+
+		details := auditDetails(deadLetter)
+		if filter != nil {
+			details["filter"] = filter
+		}
+		err = service.AuditRepository.AddEntry(ctx, &models.AuditEntry{
+			Actor:   actor,
+			Action:  AuditDeadLetterReplay,
+			Target:  deadLetterTarget(service.Queue.Config.DeadLetterTopic, deadLetter.ID),
+			Details: details,
+		})
+		if err != nil {
+			return err
+		}
+
+		return service.Queue.Replay(ctx, deadLetter)
+	})
+}
+
+func (service *DeadLetterService) deadLetters(ctx context.Context) ([]models.DeadLetter, error) {
+	deadLetters, err := service.Queue.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := service.Repository.GetStatuses(ctx, service.Queue.Config.DeadLetterTopic)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deadLetters {
+		deadLetters[i].Status = deadLetterStatus(statuses, deadLetters[i].ID)
+	}
+	return deadLetters, nil
+}
+
+func deadLetterStatus(statuses map[string]string, id string) string {
+	if status, ok := statuses[id]; ok {
+		return status
+	}
+	return models.DeadLetterPending
+}
+
+func deadLetterTarget(topic string, id string) string {
+	return topic + "/" + id
+}
+
+func auditDetails(deadLetter models.DeadLetter) map[string]interface{} {
+	return map[string]interface{}{
+		"key":                deadLetter.Key,
+		"event_type":         deadLetter.EventType,
+		"error":              deadLetter.Error,
+		"attempts":           deadLetter.Attempts,
+		"original_topic":     deadLetter.OriginalTopic,
+		"original_partition": deadLetter.OriginalPartition,
+		"original_offset":    deadLetter.OriginalOffset,
+	}
+}
@@ -1,21 +1,29 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"internship_project/models"
 	"internship_project/repositories"
+	"internship_project/tracing"
+
+	"github.com/sirupsen/logrus"
 )
 
-//EmployeeService .
+// EmployeeService .
 type EmployeeService struct {
 	Repository repositories.EmployeeRepository
+	Logger     *logrus.Entry
 }
 
 // GetAllEmployees is used to return all employees
-func (service *EmployeeService) GetAllEmployees(employeeID string) ([]models.Employee, error) {
+func (service *EmployeeService) GetAllEmployees(ctx context.Context, employeeID string) ([]models.Employee, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "EmployeeService.GetAllEmployees")
+	defer span.End()
+
 	allEmployees := []models.Employee{}
 
-	employee, err := service.Repository.GetEmployeeByID(employeeID)
+	employee, err := service.Repository.GetEmployeeByID(ctx, employeeID)
 	if err != nil {
 		return allEmployees, err
 	}
@@ -24,30 +32,36 @@ func (service *EmployeeService) GetAllEmployees(employeeID string) ([]models.Emp
 		return allEmployees, errors.New("You have no permissions to preview other employees")
 	}
 
-	allEmployees, err = service.Repository.GetAllEmployees(employee.CompanyID)
+	allEmployees, err = service.Repository.GetAllEmployees(ctx, employee.CompanyID)
 
 	return allEmployees, nil
 }
 
 // AddNewEmployee is used to return all employees
-func (service *EmployeeService) AddNewEmployee(newEmployee *models.Employee) error {
-	return service.Repository.AddEmployee(newEmployee)
+func (service *EmployeeService) AddNewEmployee(ctx context.Context, newEmployee *models.Employee) error {
+	ctx, span := tracing.Tracer().Start(ctx, "EmployeeService.AddNewEmployee")
+	defer span.End()
+
+	return service.Repository.AddEmployee(ctx, newEmployee)
 }
 
 // GetEmployeeByID is used to find a specific employee
-func (service *EmployeeService) GetEmployeeByID(id string, idEmployee string) (models.Employee, error) {
-	employee, err := service.Repository.GetEmployeeByID(idEmployee)
+func (service *EmployeeService) GetEmployeeByID(ctx context.Context, id string, idEmployee string) (models.Employee, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "EmployeeService.GetEmployeeByID")
+	defer span.End()
+
+	employee, err := service.Repository.GetEmployeeByID(ctx, idEmployee)
 	if err != nil {
 		return models.Employee{}, err
 	}
 
-	employeeRequested, err := service.Repository.GetEmployeeByID(id)
+	employeeRequested, err := service.Repository.GetEmployeeByID(ctx, id)
 	if err != nil {
 		return models.Employee{}, err
 	}
 
 	if employee.CompanyID != employeeRequested.CompanyID {
-		err := service.Repository.CheckCompaniesSharingEmployeeData(employee.CompanyID, employeeRequested.CompanyID)
+		err := service.Repository.CheckCompaniesSharingEmployeeData(ctx, employee.CompanyID, employeeRequested.CompanyID)
 		if err != nil {
 			return models.Employee{}, err
 		}
@@ -57,11 +71,17 @@ func (service *EmployeeService) GetEmployeeByID(id string, idEmployee string) (m
 }
 
 // UpdateEmployee is used to update a specific employee
-func (service *EmployeeService) UpdateEmployee(updatedEmployee models.Employee) error {
-	return service.Repository.UpdateEmployee(updatedEmployee)
+func (service *EmployeeService) UpdateEmployee(ctx context.Context, updatedEmployee models.Employee) error {
+	ctx, span := tracing.Tracer().Start(ctx, "EmployeeService.UpdateEmployee")
+	defer span.End()
+
+	return service.Repository.UpdateEmployee(ctx, updatedEmployee)
 }
 
 // DeleteEmployee is used to update a specific employee
-func (service *EmployeeService) DeleteEmployee(id string) error {
-	return service.Repository.DeleteEmployee(id)
+func (service *EmployeeService) DeleteEmployee(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "EmployeeService.DeleteEmployee")
+	defer span.End()
+
+	return service.Repository.DeleteEmployee(ctx, id)
 }
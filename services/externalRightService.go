@@ -1,30 +1,34 @@
 package services
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/repositories"
+
+	"github.com/sirupsen/logrus"
 )
 
 type ExternalRightService struct {
 	Repository repositories.ExternalRightRepository
+	Logger     *logrus.Entry
 }
 
-func (service *ExternalRightService) GetAllEars() ([]models.ExternalRights, error) {
-	return service.Repository.GetAllEars()
+func (service *ExternalRightService) GetAllEars(ctx context.Context) ([]models.ExternalRights, error) {
+	return service.Repository.GetAllEars(ctx)
 }
 
-func (service *ExternalRightService) GetEar(id string) (models.ExternalRights, error) {
-	return service.Repository.GetEar(id)
+func (service *ExternalRightService) GetEar(ctx context.Context, id string) (models.ExternalRights, error) {
+	return service.Repository.GetEar(ctx, id)
 }
 
-func (service *ExternalRightService) AddNewEar(newEar *models.ExternalRights) error {
-	return service.Repository.AddEar(newEar)
+func (service *ExternalRightService) AddNewEar(ctx context.Context, newEar *models.ExternalRights) error {
+	return service.Repository.AddEar(ctx, newEar)
 }
 
-func (service *ExternalRightService) UpdateEar(updateEar models.ExternalRights) error {
-	return service.Repository.UpdateEar(updateEar)
+func (service *ExternalRightService) UpdateEar(ctx context.Context, updateEar models.ExternalRights) error {
+	return service.Repository.UpdateEar(ctx, updateEar)
 }
 
-func (service *ExternalRightService) DeleteEar(id string) error {
-	return service.Repository.DeleteEar(id)
+func (service *ExternalRightService) DeleteEar(ctx context.Context, id string) error {
+	return service.Repository.DeleteEar(ctx, id)
 }
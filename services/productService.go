@@ -1,21 +1,39 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"internship_project/elasticsearch_helpers"
+	"internship_project/events"
 	"internship_project/models"
 	"internship_project/repositories"
+	"internship_project/tracing"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
 type ProductService struct {
 	ProductRepository  repositories.ProductRepository
 	EmployeeRepository repositories.EmployeeRepository
+	SearchIndex        elasticsearch_helpers.SearchIndex
+	UnitOfWork         repositories.UnitOfWork
+	// ImportBatchSize is the number of rows an import inserts per
+	// statement, ImportMaxRows the number of rows an import may have.
+	ImportBatchSize int
+	ImportMaxRows   int
+	Logger          *logrus.Entry
 }
 
-func (service *ProductService) GetAllProducts(employeeID string) ([]models.Product, error) {
+func (service *ProductService) GetAllProducts(ctx context.Context, employeeID string) ([]models.Product, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.GetAllProducts")
+	defer span.End()
 
 	allProducts := []models.Product{}
 
-	employee, err := service.EmployeeRepository.GetEmployeeByID(employeeID)
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
 	if err != nil {
 		return allProducts, err
 	}
@@ -24,7 +42,7 @@ func (service *ProductService) GetAllProducts(employeeID string) ([]models.Produ
 		return allProducts, errors.New("You can't see products")
 	}
 
-	allProducts, err = service.ProductRepository.GetAllProducts(employee.CompanyID)
+	allProducts, err = service.ProductRepository.GetAllProducts(ctx, employee.CompanyID)
 
 	if err != nil {
 		return allProducts, err
@@ -33,10 +51,13 @@ func (service *ProductService) GetAllProducts(employeeID string) ([]models.Produ
 	return allProducts, nil
 }
 
-func (service *ProductService) GetProduct(productId string, employeeId string) (models.Product, error) {
+func (service *ProductService) GetProduct(ctx context.Context, productId string, employeeId string) (models.Product, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.GetProduct")
+	defer span.End()
+
 	product := models.Product{}
 
-	employee, err := service.EmployeeRepository.GetEmployeeByID(employeeId)
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeId)
 	if err != nil {
 		return product, err
 	}
@@ -45,7 +66,7 @@ func (service *ProductService) GetProduct(productId string, employeeId string) (
 		return product, errors.New("You can't see products")
 	}
 
-	product, err = service.ProductRepository.GetProduct(productId, employee.CompanyID)
+	product, err = service.ProductRepository.GetProduct(ctx, productId, employee.CompanyID)
 
 	if err != nil {
 		return product, err
@@ -54,8 +75,11 @@ func (service *ProductService) GetProduct(productId string, employeeId string) (
 	return product, nil
 }
 
-func (service *ProductService) AddNewProduct(product *models.Product, employeeID string) error {
-	employee, err := service.EmployeeRepository.GetEmployeeByID(employeeID)
+func (service *ProductService) AddNewProduct(ctx context.Context, product *models.Product, employeeID string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.AddNewProduct")
+	defer span.End()
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
 	if err != nil {
 		return err
 	}
@@ -64,15 +88,20 @@ func (service *ProductService) AddNewProduct(product *models.Product, employeeID
 		return errors.New("You can't create products")
 	}
 
+	ctx = events.WithActor(ctx, employee.ID, employee.CompanyID)
+
 	if employee.CompanyID != product.IDC {
 		return errors.New("You can't create products for other companies")
 	}
 
-	return service.ProductRepository.AddProduct(product)
+	return service.ProductRepository.AddProduct(ctx, product)
 }
 
-func (service *ProductService) UpdateProduct(updateProduct models.Product, employeeId string) error {
-	employee, err := service.EmployeeRepository.GetEmployeeByID(employeeId)
+func (service *ProductService) UpdateProduct(ctx context.Context, updateProduct models.Product, employeeId string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.UpdateProduct")
+	defer span.End()
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeId)
 	if err != nil {
 		return err
 	}
@@ -81,10 +110,12 @@ func (service *ProductService) UpdateProduct(updateProduct models.Product, emplo
 		return errors.New("You can't update products")
 	}
 
-	product, err := service.ProductRepository.GetProduct(updateProduct.ID, employee.CompanyID)
+	ctx = events.WithActor(ctx, employee.ID, employee.CompanyID)
+
+	product, err := service.ProductRepository.GetProduct(ctx, updateProduct.ID, employee.CompanyID)
 
 	if employee.CompanyID != product.IDC {
-		externalAccessRights, err := service.EmployeeRepository.GetEmployeeExternalPermissions(employee.CompanyID, product)
+		externalAccessRights, err := service.EmployeeRepository.GetEmployeeExternalPermissions(ctx, employee.CompanyID, product)
 		if err != nil {
 			return err
 		}
@@ -93,11 +124,14 @@ func (service *ProductService) UpdateProduct(updateProduct models.Product, emplo
 		}
 	}
 
-	return service.ProductRepository.UpdateProduct(updateProduct)
+	return service.ProductRepository.UpdateProduct(ctx, updateProduct)
 }
 
-func (service *ProductService) DeleteProduct(productId string, employeeId string) error {
-	employee, err := service.EmployeeRepository.GetEmployeeByID(employeeId)
+func (service *ProductService) DeleteProduct(ctx context.Context, productId string, employeeId string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.DeleteProduct")
+	defer span.End()
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeId)
 	if err != nil {
 		return err
 	}
@@ -106,10 +140,12 @@ func (service *ProductService) DeleteProduct(productId string, employeeId string
 		return errors.New("You can't delete products")
 	}
 
-	product, err := service.ProductRepository.GetProduct(productId, employee.CompanyID)
+	ctx = events.WithActor(ctx, employee.ID, employee.CompanyID)
+
+	product, err := service.ProductRepository.GetProduct(ctx, productId, employee.CompanyID)
 
 	if employee.CompanyID != product.IDC {
-		externalAccessRights, err := service.EmployeeRepository.GetEmployeeExternalPermissions(employee.CompanyID, product)
+		externalAccessRights, err := service.EmployeeRepository.GetEmployeeExternalPermissions(ctx, employee.CompanyID, product)
 		if err != nil {
 			return err
 		}
@@ -118,5 +154,197 @@ func (service *ProductService) DeleteProduct(productId string, employeeId string
 		}
 	}
 
-	return service.ProductRepository.DeleteProduct(productId)
+	return service.ProductRepository.DeleteProduct(ctx, productId)
+}
+
+// SearchProducts searches the products the employee may see, the same ones
+// GetAllProducts returns.
+func (service *ProductService) SearchProducts(ctx context.Context, request models.ProductSearchRequest, employeeID string) (models.ProductSearchResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.SearchProducts")
+	defer span.End()
+
+	result := models.ProductSearchResult{}
+
+	err := request.Normalize()
+	if err != nil {
+		return result, err
+	}
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return result, err
+	}
+
+	if !employee.R {
+		return result, errors.New("You can't see products")
+	}
+
+	constraints, err := service.ProductRepository.GetReadConstraints(ctx, employee.CompanyID)
+	if err != nil {
+		return result, err
+	}
+
+	return service.SearchIndex.SearchProducts(ctx, request, employee.CompanyID, constraints)
+}
+
+// SuggestProducts returns up to size products the employee may see whose
+// names have a word starting with prefix.
+func (service *ProductService) SuggestProducts(ctx context.Context, prefix string, size int, employeeID string) ([]models.ProductSuggestion, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.SuggestProducts")
+	defer span.End()
+
+	if strings.TrimSpace(prefix) == "" {
+		return []models.ProductSuggestion{}, nil
+	}
+	if size < 1 || size > 50 {
+		return nil, errors.New("The number of suggestions must be between 1 and 50")
+	}
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !employee.R {
+		return nil, errors.New("You can't see products")
+	}
+
+	constraints, err := service.ProductRepository.GetReadConstraints(ctx, employee.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SearchIndex.SuggestProducts(ctx, prefix, size, employee.CompanyID, constraints)
+}
+
+// errInvalidRows rolls back an import with invalid rows.
+var errInvalidRows = errors.New("The import has invalid rows")
+
+// ImportProducts creates a product for every row decoder reads. The rows are
+// validated and inserted ImportBatchSize at a time while they are read, all
+// in one transaction. Nothing is imported unless every row is valid, the
+// result lists the invalid ones. A dry run only validates the rows.
+func (service *ProductService) ImportProducts(ctx context.Context, decoder models.ProductDecoder, dryRun bool, employeeID string) (models.ProductImportResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.ImportProducts")
+	defer span.End()
+
+	result := models.ProductImportResult{DryRun: dryRun, Errors: []models.ProductRowError{}}
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return result, err
+	}
+
+	if !employee.C {
+		return result, errors.New("You can't create products")
+	}
+
+	ctx = events.WithActor(ctx, employee.ID, employee.CompanyID)
+
+	if dryRun {
+		err = service.readImport(decoder, employee.CompanyID, &result, func([]models.Product) error { return nil })
+		return result, err
+	}
+
+	err = service.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		err := service.readImport(decoder, employee.CompanyID, &result, func(products []models.Product) error {
+			err := service.ProductRepository.AddProducts(ctx, products, service.ImportBatchSize)
+			if err != nil {
+				return err
+			}
+			result.Imported += len(products)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if result.Failed > 0 {
+			return errInvalidRows
+		}
+		return nil
+	})
+	if err != nil {
+		result.Imported = 0
+		if errors.Is(err, errInvalidRows) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	service.Logger.WithContext(ctx).WithField("products", result.Imported).Info("Imported products")
+	return result, nil
+}
+
+// readImport decodes and validates the rows of decoder into result and calls
+// insert with every ImportBatchSize valid rows, until a row is invalid. The
+// rows after it are still validated for the report.
+func (service *ProductService) readImport(decoder models.ProductDecoder, companyID string, result *models.ProductImportResult, insert func([]models.Product) error) error {
+	batch := make([]models.Product, 0, service.ImportBatchSize)
+	flush := func() error {
+		products := batch
+		batch = make([]models.Product, 0, service.ImportBatchSize)
+		if len(products) == 0 || result.Failed > 0 {
+			return nil
+		}
+		return insert(products)
+	}
+
+	for {
+		product, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *models.ProductRowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return err
+		}
+
+		result.Rows++
+		if result.Rows > service.ImportMaxRows {
+			return fmt.Errorf("An import can have at most %d rows", service.ImportMaxRows)
+		}
+		if rowErr != nil {
+			result.AddError(*rowErr)
+			continue
+		}
+
+		if product.IDC == "" {
+			product.IDC = companyID
+		}
+		if product.IDC != companyID {
+			result.AddError(models.ProductRowError{Row: decoder.Row(), Message: "You can't create products for other companies"})
+			continue
+		}
+		err = product.Validate()
+		if err != nil {
+			result.AddError(models.ProductRowError{Row: decoder.Row(), Message: err.Error()})
+			continue
+		}
+		batch = append(batch, product)
+		if len(batch) == service.ImportBatchSize {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// ExportProducts calls write with every product the employee may see, the
+// same ones GetAllProducts returns.
+func (service *ProductService) ExportProducts(ctx context.Context, employeeID string, write func(models.Product) error) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ProductService.ExportProducts")
+	defer span.End()
+
+	employee, err := service.EmployeeRepository.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+
+	if !employee.R {
+		return errors.New("You can't see products")
+	}
+
+	return service.ProductRepository.ForEachReadableProduct(ctx, employee.CompanyID, write)
 }
@@ -1,47 +1,50 @@
 package services
 
 import (
+	"context"
 	"github.com/codingsince1985/geo-golang"
+	"github.com/sirupsen/logrus"
 	"internship_project/models"
 	"internship_project/repositories"
 )
 
 type ShopService struct {
 	Repository repositories.ShopRepository
-	Geocoder geo.Geocoder
+	Geocoder   geo.Geocoder
+	Logger     *logrus.Entry
 }
 
-func (service *ShopService) GetAllShops() ([]models.Shop, error) {
-	return service.Repository.GetAllShops()
+func (service *ShopService) GetAllShops(ctx context.Context) ([]models.Shop, error) {
+	return service.Repository.GetAllShops(ctx)
 }
 
-func (service *ShopService) SearchShopsByAddress(address string) ([]models.Shop, error) {
+func (service *ShopService) SearchShopsByAddress(ctx context.Context, address string) ([]models.Shop, error) {
 	location, err := service.Geocoder.Geocode(address)
 	if err != nil {
 		return nil, err
 	}
 
-	return service.Repository.GetShopsByLatLon(location.Lat, location.Lng)
+	return service.Repository.GetShopsByLatLon(ctx, location.Lat, location.Lng)
 }
 
-func (service *ShopService) GetShop(id string) (models.Shop, error) {
-	return service.Repository.GetShop(id)
+func (service *ShopService) GetShop(ctx context.Context, id string) (models.Shop, error) {
+	return service.Repository.GetShop(ctx, id)
 }
 
-func (service *ShopService) AddNewShop(newShop *models.Shop) error {
-	return service.Repository.AddShop(newShop)
+func (service *ShopService) AddNewShop(ctx context.Context, newShop *models.Shop) error {
+	return service.Repository.AddShop(ctx, newShop)
 }
 
-func (service *ShopService) UpdateShop(updateShop models.Shop) error {
-	return service.Repository.UpdateShop(updateShop)
+func (service *ShopService) UpdateShop(ctx context.Context, updateShop models.Shop) error {
+	return service.Repository.UpdateShop(ctx, updateShop)
 }
 
-func (service *ShopService) DeleteShop(id string) error {
-	return service.Repository.DeleteShop(id)
+func (service *ShopService) DeleteShop(ctx context.Context, id string) error {
+	return service.Repository.DeleteShop(ctx, id)
 }
 
-func (service *ShopService) GetAddress(id string) (*geo.Address, error) {
-	shop, err := service.Repository.GetShop(id)
+func (service *ShopService) GetAddress(ctx context.Context, id string) (*geo.Address, error) {
+	shop, err := service.Repository.GetShop(ctx, id)
 	if err != nil {
 		return nil, err
 	}
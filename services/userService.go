@@ -1,24 +1,29 @@
 package services
 
 import (
+	"context"
 	"internship_project/models"
 	"internship_project/repositories"
 	"internship_project/utils"
+
+	"github.com/sirupsen/logrus"
 )
 
 type UserService struct {
-	Repository repositories.UserRepository
+	Repository     repositories.UserRepository
+	GoogleClientID string
+	Logger         *logrus.Entry
 }
 
-func (service *UserService) GoogleSignIn(token string) (models.User, error) {
+func (service *UserService) GoogleSignIn(ctx context.Context, token string) (models.User, error) {
 	var user models.User
 
-	claims, err := utils.ValidateGoogleJWT(token)
+	claims, err := utils.ValidateGoogleJWT(token, service.GoogleClientID)
 	if err != nil {
 		return user, err
 	}
 
-	exists, err := service.Repository.DoesUserExists(claims.Sub)
+	exists, err := service.Repository.DoesUserExists(ctx, claims.Sub)
 	if err != nil {
 		return user, err
 	}
@@ -28,13 +33,15 @@ func (service *UserService) GoogleSignIn(token string) (models.User, error) {
 			Email: claims.Email,
 			Name:  claims.FirstName + " " + claims.LastName,
 		}
-		err = service.Repository.AddUser(user)
+		err = service.Repository.AddUser(ctx, user)
+		if err == nil {
+			service.Logger.WithContext(ctx).WithField("user_id", user.ID).Info("Registered new user")
+		}
 	} else {
-		user, err = service.Repository.GetUser(claims.Sub)
+		user, err = service.Repository.GetUser(ctx, claims.Sub)
 	}
 	if err != nil {
 		return user, err
 	}
 	return user, nil
 }
-
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// PgxLogger turns the query log of pgx into spans. pgx v4 has no tracing
+// hooks, but it logs every query and exec with the context of the caller and
+// the time it took once it completes, which is enough to record the span
+// afterwards.
+type PgxLogger struct{}
+
+// InstrumentConnConfig makes the connections created with connConfig report their
+// queries as spans.
+func InstrumentConnConfig(connConfig *pgx.ConnConfig) {
+	connConfig.Logger = PgxLogger{}
+	connConfig.LogLevel = pgx.LogLevelInfo
+}
+
+func (PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if msg != "Query" && msg != "Exec" {
+		return
+	}
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		// Queries outside of a traced request, e.g. health checks, would
+		// each start a trace of their own.
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if elapsed, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-elapsed)
+	}
+
+	attributes := []label.KeyValue{semconv.DBSystemPostgres}
+	if sql, ok := data["sql"].(string); ok {
+		attributes = append(attributes, semconv.DBStatementKey.String(sql))
+	}
+
+	_, span := Tracer().Start(ctx, "postgres."+msg,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...),
+	)
+
+	var err error
+	if level == pgx.LogLevelError {
+		err, _ = data["err"].(error)
+	}
+	if err != nil {
+		End(ctx, span, err)
+		return
+	}
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"internship_project/config"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagators"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"google.golang.org/grpc/credentials"
+)
+
+const instrumentationName = "internship_project"
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes the remaining spans and must be
+// called before the application exits.
+func Setup(conf config.TracingConfig) (func(context.Context) error, error) {
+	global.SetTextMapPropagator(otel.NewCompositeTextMapPropagator(propagators.TraceContext{}, propagators.Baggage{}))
+
+	exporter, err := newExporter(conf)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(conf.SamplePercent) / 100)),
+		}),
+		sdktrace.WithResource(resource.New(semconv.ServiceNameKey.String(conf.ServiceName))),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	global.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		processor.Shutdown()
+		return exporter.Shutdown(ctx)
+	}, nil
+}
+
+func newExporter(conf config.TracingConfig) (exporttrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return stdout.NewExporter(stdout.WithWriter(os.Stdout), stdout.WithoutMetricExport())
+	case "otlp":
+		options := []otlp.ExporterOption{otlp.WithAddress(conf.Endpoint)}
+		if conf.TLS {
+			options = append(options, otlp.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+		} else {
+			options = append(options, otlp.WithInsecure())
+		}
+		return otlp.NewExporter(options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", conf.Exporter)
+	}
+}
+
+// Tracer returns the tracer used for the spans created by this application.
+func Tracer() trace.Tracer {
+	return global.Tracer(instrumentationName)
+}
+
+// End marks the span as failed when err is not nil and ends it.
+func End(ctx context.Context, span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(ctx, err, trace.WithErrorStatus(codes.Error))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject writes the trace context of ctx into carrier.
+func Inject(ctx context.Context, carrier otel.TextMapCarrier) {
+	global.TextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the remote trace context found in carrier.
+func Extract(ctx context.Context, carrier otel.TextMapCarrier) context.Context {
+	return global.TextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"internship_project/config"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/codes"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans []*exporttrace.SpanData
+}
+
+func (exporter *recordingExporter) ExportSpans(ctx context.Context, spans []*exporttrace.SpanData) error {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.spans = append(exporter.spans, spans...)
+	return nil
+}
+
+func (exporter *recordingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (exporter *recordingExporter) byName(name string) *exporttrace.SpanData {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	for _, span := range exporter.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func recordSpans() *recordingExporter {
+	exporter := &recordingExporter{}
+	global.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(exporter),
+	))
+	return exporter
+}
+
+type mapCarrier map[string]string
+
+func (carrier mapCarrier) Get(key string) string {
+	return carrier[key]
+}
+
+func (carrier mapCarrier) Set(key string, value string) {
+	carrier[key] = value
+}
+
+func TestSetup(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("no exporter", func(t *testing.T) {
+		shutdown, err := Setup(config.TracingConfig{Exporter: "none"})
+
+		assert.NoError(err)
+		assert.NoError(shutdown(context.Background()))
+	})
+
+	t.Run("stdout exporter", func(t *testing.T) {
+		shutdown, err := Setup(config.TracingConfig{Exporter: "stdout", ServiceName: "test", SamplePercent: 100})
+
+		assert.NoError(err)
+		assert.NoError(shutdown(context.Background()))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(config.TracingConfig{Exporter: "zipkin"})
+
+		assert.Error(err)
+	})
+}
+
+func TestPropagation(t *testing.T) {
+	assert := assert.New(t)
+	recordSpans()
+	Setup(config.TracingConfig{Exporter: "none"})
+
+	ctx, span := Tracer().Start(context.Background(), "producer")
+	defer span.End()
+
+	carrier := mapCarrier{}
+	Inject(ctx, carrier)
+	assert.NotEmpty(carrier["traceparent"])
+
+	extracted := Extract(context.Background(), carrier)
+	_, child := Tracer().Start(extracted, "consumer")
+	defer child.End()
+
+	assert.Equal(span.SpanContext().TraceID, child.SpanContext().TraceID)
+}
+
+func TestPgxLogger(t *testing.T) {
+	assert := assert.New(t)
+	exporter := recordSpans()
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+
+	t.Run("query becomes a child span", func(t *testing.T) {
+		PgxLogger{}.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+			"sql":  "select * from products",
+			"time": 50 * time.Millisecond,
+		})
+
+		span := exporter.byName("postgres.Query")
+		if assert.NotNil(span) {
+			assert.Equal(parent.SpanContext().SpanID, span.ParentSpanID)
+			assert.True(span.EndTime.Sub(span.StartTime) >= 50*time.Millisecond)
+		}
+	})
+
+	t.Run("failed exec is marked as error", func(t *testing.T) {
+		PgxLogger{}.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+			"sql": "delete from products",
+			"err": errors.New("connection reset"),
+		})
+
+		span := exporter.byName("postgres.Exec")
+		if assert.NotNil(span) {
+			assert.Equal(codes.Error, span.StatusCode)
+		}
+	})
+
+	t.Run("queries outside a trace are ignored", func(t *testing.T) {
+		PgxLogger{}.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{
+			"sql": "select 1",
+		})
+
+		assert.Len(exporter.spans, 2)
+	})
+
+	parent.End()
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/lytics/confl"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -20,22 +19,8 @@ type GoogleClaims struct {
 	jwt.StandardClaims
 }
 
-type GoogleAuthCredentials struct {
-	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-}
-
-func loadGoogleCredentials() GoogleAuthCredentials {
-	var googleAuthCredentials GoogleAuthCredentials
-	if _, err := confl.DecodeFile("googleauth.conf", &googleAuthCredentials); err != nil {
-		panic(err)
-	}
-	return googleAuthCredentials
-}
-
-func ValidateGoogleJWT(tokenString string) (GoogleClaims, error) {
+func ValidateGoogleJWT(tokenString string, clientID string) (GoogleClaims, error) {
 	claimsStruct := GoogleClaims{}
-	googleAuthCredentials := loadGoogleCredentials()
 
 	token, err := jwt.ParseWithClaims(
 		tokenString,
@@ -65,7 +50,7 @@ func ValidateGoogleJWT(tokenString string) (GoogleClaims, error) {
 		return GoogleClaims{}, errors.New("iss is invalid")
 	}
 
-	if claims.Audience != googleAuthCredentials.ClientId {
+	if claims.Audience != clientID {
 		return GoogleClaims{}, errors.New("aud is invalid")
 	}
 
@@ -10,6 +10,11 @@ import (
 
 var (
 	NoDataError *pgconn.PgError = &pgconn.PgError{Code: `02000`, Message: `There is no entity with this ID`}
+
+	NotArchivedError          = errors.New("This company is not archived")
+	RestorePeriodExpiredError = errors.New("The grace period for restoring this company has expired")
+
+	AlreadyResolvedError = errors.New("This dead letter has already been replayed or discarded")
 )
 
 // GetErrorMsg is used for error handling
@@ -42,3 +42,13 @@ func CreateJWT(u models.User) (string, error) {
 	return tokenString, err
 }
 
+
+// JWTSubject returns the subject of a valid JWT, or an empty string.
+func JWTSubject(jwt_string string) string {
+	claims, err := ParseJWT(jwt_string)
+	if err != nil {
+		return ""
+	}
+	subject, _ := claims["sub"].(string)
+	return subject
+}
@@ -194,6 +194,7 @@ func CreateTables(db *pgxpool.Pool) {
 		id uuid NOT NULL,
 		"name" varchar(30) NOT NULL,
 		ismain bool NOT NULL,
+		deleted_at timestamptz NULL,
 		CONSTRAINT companies_pk PRIMARY KEY (id)
 	);`)
 
@@ -219,6 +220,7 @@ func CreateTables(db *pgxpool.Pool) {
 		price float4 NOT NULL,
 		quantity int4 NOT NULL,
 		idc uuid NOT NULL,
+		"version" int8 NOT NULL DEFAULT 1,
 		CONSTRAINT products_pk PRIMARY KEY (id)
 	);
 	ALTER TABLE products ADD CONSTRAINT products_fk FOREIGN KEY (idc) REFERENCES companies(id);
@@ -268,6 +270,37 @@ func CreateTables(db *pgxpool.Pool) {
 		ALTER TABLE access_constraints ADD CONSTRAINT access_constraints_operator_id FOREIGN KEY (operator_id) REFERENCES operators(id);
 		ALTER TABLE access_constraints ADD CONSTRAINT access_constraints_property_id FOREIGN KEY (property_id) REFERENCES properties(id);
 	`)
+
+	// Shops
+	db.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS shops (
+		id uuid NOT NULL,
+		"name" varchar NOT NULL,
+		idc uuid NOT NULL,
+		lat float8 NOT NULL,
+		lon float8 NOT NULL,
+		CONSTRAINT shops_pk PRIMARY KEY (id)
+	);
+	ALTER TABLE shops ADD CONSTRAINT shops_fk FOREIGN KEY (idc) REFERENCES companies(id);
+	`)
+
+	// Audit log and dead letters
+	db.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS audit_log (
+		id uuid NOT NULL,
+		actor varchar NOT NULL,
+		"action" varchar NOT NULL,
+		target varchar NOT NULL,
+		details jsonb NULL,
+		created_at timestamptz NOT NULL,
+		CONSTRAINT audit_log_pk PRIMARY KEY (id)
+	);
+	CREATE TABLE IF NOT EXISTS dead_letter_resolutions (
+		topic varchar NOT NULL,
+		message_id varchar NOT NULL,
+		status varchar NOT NULL,
+		resolved_at timestamptz NOT NULL,
+		CONSTRAINT dead_letter_resolutions_pk PRIMARY KEY (topic, message_id)
+	);
+	`)
 }
 
 func DropTables(db *pgxpool.Pool) {
@@ -277,7 +310,10 @@ func DropTables(db *pgxpool.Pool) {
 	db.Exec(context.Background(), "DROP TABLE IF EXISTS operators;")
 	db.Exec(context.Background(), "DROP TABLE IF EXISTS properties;")
 	db.Exec(context.Background(), "DROP TABLE IF EXISTS external_access_rights;")
+	db.Exec(context.Background(), "DROP TABLE IF EXISTS shops;")
 	db.Exec(context.Background(), "DROP TABLE IF EXISTS companies;")
+	db.Exec(context.Background(), "DROP TABLE IF EXISTS audit_log;")
+	db.Exec(context.Background(), "DROP TABLE IF EXISTS dead_letter_resolutions;")
 }
 
 func insertMockData(db *pgxpool.Pool) {